@@ -0,0 +1,57 @@
+//使用当前主密钥重新加密etcd中需要加密的资源
+//
+//	ETCDHOST=http://127.0.0.1:2379 ENCRYPT_KEYFILE=/deploy/data/encrypt.key encrypt-migrate [-rotate]
+//
+//-rotate会先生成新的主密钥,旧主密钥保留在密钥文件中用于解密
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/kv"
+)
+
+const (
+	etcdHostEnvKey = "ETCDHOST"
+)
+
+func main() {
+	rotate := flag.Bool("rotate", false, "rotate the master key before re-encrypting")
+	flag.Parse()
+
+	etcdHost := os.Getenv(etcdHostEnvKey)
+	if len(etcdHost) == 0 {
+		fmt.Fprintf(os.Stderr, "must provide Environment \"%v\"\n", etcdHostEnvKey)
+		os.Exit(1)
+	}
+	kv.Init(etcdHost)
+	err := kv.Store.TestConnection()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "connect etcd fail for %v\n", err)
+		os.Exit(1)
+	}
+
+	if *rotate {
+		kid, err := backend.RotateEncryptKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rotate master key fail for %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("rotate master key to \"%v\"\n", kid)
+	}
+
+	r, err := backend.MigrateEncryption()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate fail for %v\n", err)
+		os.Exit(1)
+	}
+
+	data, _ := json.MarshalIndent(r, "", "  ")
+	fmt.Println(string(data))
+	if len(r.Failed) != 0 {
+		os.Exit(1)
+	}
+}
@@ -3,7 +3,10 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"ufleet-deploy/pkg/app"
+	"ufleet-deploy/pkg/autorollback"
+	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource/cronjob"
 	"ufleet-deploy/pkg/resource/daemonset"
@@ -14,6 +17,8 @@ import (
 	"ufleet-deploy/pkg/resource/replicationcontroller"
 	"ufleet-deploy/pkg/resource/service"
 	"ufleet-deploy/pkg/resource/statefulset"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/pkg/usage"
 	"ufleet-deploy/pkg/user"
 
 	corev1 "k8s.io/api/core/v1"
@@ -56,7 +61,14 @@ func (this *AppController) NewApp() {
 	var opt app.CreateOption
 	opt.User = who
 
-	err = app.Controller.NewApp(group, workspace, appName, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = app.Controller.NewApp(group, workspace, appName, body, opt)
 	if err != nil {
 		this.audit(token, appName, true)
 		this.errReturn(err, 500)
@@ -169,7 +181,14 @@ func (this *AppController) UpdateApp() {
 		return
 	}
 
-	err = app.Controller.UpdateApp(group, workspace, appName, this.Ctx.Input.RequestBody, app.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = app.Controller.UpdateApp(group, workspace, appName, body, app.UpdateOption{})
 	if err != nil {
 		this.errReturn(err, 500)
 		return
@@ -647,3 +666,415 @@ func (this *AppController) GetAppServiceAndContainerPort() {
 	}
 	this.normalReturn(sc)
 }
+
+// GetAppReloadPolicy
+// @Title 应用
+// @Description   获取应用的配置重载策略
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/reloadpolicy [Get]
+func (this *AppController) GetAppReloadPolicy() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	_, err = app.Controller.Get(group, workspace, appName)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	rp, err := backend.GetAppReloadPolicy(group, workspace, appName)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(rp)
+}
+
+// UpdateAppReloadPolicy
+// @Title 应用
+// @Description   更新应用的配置重载策略,开启后应用中的ConfigMap/Secret更新时滚动重启引用它们的工作负载
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Param body body string true "重载策略"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/reloadpolicy [Put]
+func (this *AppController) UpdateAppReloadPolicy() {
+	token := this.Ctx.Request.Header.Get("token")
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit reload policy")
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var rp backend.ReloadPolicy
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &rp)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	_, err = app.Controller.Get(group, workspace, appName)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	rp.Group = group
+	rp.Workspace = workspace
+	rp.App = appName
+	err = backend.SetAppReloadPolicy(rp)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, appName, false)
+	this.normalReturn("ok")
+}
+
+// GetAppLogs
+// @Title 应用
+// @Description   获取应用中所有Pod合并后的日志,每行以[pod/container]开头,按时间排序,follow时持续输出
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Param container query string false "容器,默认所有容器"
+// @Param grep query string false "只保留匹配该正则表达式的行"
+// @Param follow query bool false "是否持续输出"
+// @Param tailLines query int false "每个容器显示的行数"
+// @Param sinceSeconds query int false "显示多少秒以来的日志"
+// @Param sinceTime query string false "显示该时间(RFC3339)以来的日志"
+// @Param timestamps query bool false "是否显示时间戳"
+// @Param previous query bool false "是否显示上一个容器的日志"
+// @Param limitBytes query int false "每个容器最多返回的字节数"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/logs [Get]
+func (this *AppController) GetAppLogs() {
+	this.appLogs(false)
+}
+
+// DownloadAppLogs
+// @Title 应用
+// @Description   下载应用中所有Pod的日志,zip包中每个容器一个文件
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Param container query string false "容器,默认所有容器"
+// @Param grep query string false "只保留匹配该正则表达式的行"
+// @Param tailLines query int false "每个容器的行数"
+// @Param sinceSeconds query int false "多少秒以来的日志"
+// @Param sinceTime query string false "该时间(RFC3339)以来的日志"
+// @Param timestamps query bool false "是否带时间戳"
+// @Param previous query bool false "是否为上一个容器的日志"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/logs/download [Get]
+func (this *AppController) DownloadAppLogs() {
+	this.appLogs(true)
+}
+
+func (this *AppController) appLogs(download bool) {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	ai, err := app.Controller.Get(group, workspace, appName)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	pods, err := getAppPods(group, workspace, ai.Info())
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.aggregatedLogReturn(group, workspace, appName, pods, download)
+}
+
+//应用中的Pod以及应用中工作负载的Pod,按Pod名去重
+func getAppPods(group, workspace string, a app.App) ([]*corev1.Pod, error) {
+	pods := make([]*corev1.Pod, 0)
+	found := make(map[string]bool)
+	for _, v := range a.Resources {
+		kind := workload.KindPod
+		if !strings.EqualFold(v.Kind, workload.KindPod) {
+			k, err := workload.ParseKind(v.Kind)
+			if err != nil {
+				continue
+			}
+			kind = k
+		}
+
+		ps, err := workload.GetPods(kind, group, workspace, v.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range ps {
+			if found[p.Name] {
+				continue
+			}
+			found[p.Name] = true
+			pods = append(pods, p)
+		}
+	}
+	return pods, nil
+}
+
+// GetAppUsage
+// @Title 应用
+// @Description   应用的资源使用历史,为应用中各工作负载和Pod之和
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Param start query string false "开始时间(RFC3339),默认结束时间前一小时"
+// @Param end query string false "结束时间(RFC3339),默认当前时间"
+// @Param step query int false "聚合的间隔(秒),默认为存储精度"
+// @Param agg query string false "聚合方式,avg/max/min,默认avg"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/usage [Get]
+func (this *AppController) GetAppUsage() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	opt, err := this.getUsageQueryOption()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ai, err := app.Controller.Get(group, workspace, appName)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	keys := make([]string, 0)
+	found := make(map[string]bool)
+	for _, v := range ai.Info().Resources {
+		kind := workload.KindPod
+		if !strings.EqualFold(v.Kind, workload.KindPod) {
+			k, err := workload.ParseKind(v.Kind)
+			if err != nil {
+				continue
+			}
+			kind = k
+		}
+		key := usage.SeriesKey(kind, group, workspace, v.Name)
+		if found[key] {
+			continue
+		}
+		found[key] = true
+		keys = append(keys, key)
+	}
+
+	us, err := usage.Query(appName, keys, *opt)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(us)
+}
+
+// GetAppAutoRollback
+// @Title 应用
+// @Description   获取应用的自动回滚策略,作用于应用下所有的Deployment和DaemonSet
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/autorollback [Get]
+func (this *AppController) GetAppAutoRollback() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	p, err := autorollback.Get(group, backend.AutoRollbackPolicy{Workspace: workspace, App: appName})
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(p)
+}
+
+// UpdateAppAutoRollback
+// @Title 应用
+// @Description   设置应用的自动回滚策略,工作负载自身的策略优先
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Param body body string true "自动回滚策略"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/autorollback [Put]
+func (this *AppController) UpdateAppAutoRollback() {
+	token := this.Ctx.Request.Header.Get("token")
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit auto rollback policy")
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var p backend.AutoRollbackPolicy
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &p)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	_, err = app.Controller.Get(group, workspace, appName)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	p.Workspace = workspace
+	p.App = appName
+	p.Kind = ""
+	p.Name = ""
+	err = autorollback.Set(group, p)
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, appName, false)
+	this.normalReturn("ok")
+}
+
+// DeleteAppAutoRollback
+// @Title 应用
+// @Description   删除应用的自动回滚策略
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/autorollback [Delete]
+func (this *AppController) DeleteAppAutoRollback() {
+	token := this.Ctx.Request.Header.Get("token")
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	err = autorollback.Delete(group, backend.AutoRollbackPolicy{Workspace: workspace, App: appName})
+	if err != nil {
+		this.audit(token, appName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, appName, false)
+	this.normalReturn("ok")
+}
+
+// GetAppAutoRollbackHistory
+// @Title 应用
+// @Description   获取按应用策略执行的自动回滚记录,最近的在前
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app path string true "栈名"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:app/group/:group/workspace/:workspace/autorollback/history [Get]
+func (this *AppController) GetAppAutoRollbackHistory() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	appName := this.Ctx.Input.Param(":app")
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	rs, err := autorollback.AppHistory(group, workspace, appName)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(rs)
+}
@@ -20,6 +20,8 @@ const (
 	operateObjectJob                   = "Job"
 	operateObjectCronJob               = "CronJob"
 	operateObjectHpa                   = "HorizontalPodAutoscaler"
+	operateObjectImage                 = "Image"
+	operateObjectWorkload              = "Workload"
 
 	operateTypeCreate        = "create"
 	operateTypeUpdate        = "update"
@@ -31,6 +33,17 @@ const (
 	operateTypeAddService    = "add service"
 	operateTypeStartHPA      = "start autoscale"
 	operateTypePauseOrResume = "pause/resume"
+	operateTypeSetImage      = "set image"
+	operateTypeTerminalStart = "open terminal"
+	operateTypeTerminalEnd   = "close terminal"
+	operateTypePortForward   = "port forward"
+	operateTypeCopyFrom      = "copy from"
+	operateTypeCopyTo        = "copy to"
+	operateTypeRestart       = "restart"
+	operateTypeCreateCanary  = "create canary"
+	operateTypeNextCanary    = "next canary step"
+	operateTypePromoteCanary = "promote canary"
+	operateTypeAbortCanary   = "abort canary"
 
 	operateTypeDeleteClusterApp = "deleteClusterObjects"
 )
@@ -79,6 +92,18 @@ var (
 			object:  operateObjectApp,
 			operate: operateTypeUpdate,
 		},
+		"UpdateAppReloadPolicy": audit{
+			object:  operateObjectApp,
+			operate: operateTypeUpdate,
+		},
+		"UpdateAppAutoRollback": audit{
+			object:  operateObjectApp,
+			operate: operateTypeUpdate,
+		},
+		"DeleteAppAutoRollback": audit{
+			object:  operateObjectApp,
+			operate: operateTypeDelete,
+		},
 
 		//Pod
 		"CreatePod": audit{
@@ -93,6 +118,18 @@ var (
 			object:  operateObjectPod,
 			operate: operateTypeDelete,
 		},
+		"PodPortForward": audit{
+			object:  operateObjectPod,
+			operate: operateTypePortForward,
+		},
+		"DownloadPodFile": audit{
+			object:  operateObjectPod,
+			operate: operateTypeCopyFrom,
+		},
+		"UploadPodFile": audit{
+			object:  operateObjectPod,
+			operate: operateTypeCopyTo,
+		},
 
 		//Service
 		"CreateService": audit{
@@ -121,6 +158,10 @@ var (
 			object:  operateObjectConfigMap,
 			operate: operateTypeUpdate,
 		},
+		"RestoreConfigMapHistory": audit{
+			object:  operateObjectConfigMap,
+			operate: operateTypeRollback,
+		},
 		"UpdateConfigMapCustom": audit{
 			object:  operateObjectConfigMap,
 			operate: operateTypeUpdate,
@@ -181,10 +222,30 @@ var (
 			object:  operateObjectSecret,
 			operate: operateTypeUpdate,
 		},
+		"UpdateSecretCustom": audit{
+			object:  operateObjectSecret,
+			operate: operateTypeUpdate,
+		},
+		"CreateSecretFromFiles": audit{
+			object:  operateObjectSecret,
+			operate: operateTypeCreate,
+		},
+		"UpdateSecretFromFiles": audit{
+			object:  operateObjectSecret,
+			operate: operateTypeUpdate,
+		},
+		"RestoreSecretHistory": audit{
+			object:  operateObjectSecret,
+			operate: operateTypeRollback,
+		},
 		"DeleteSecret": audit{
 			object:  operateObjectSecret,
 			operate: operateTypeDelete,
 		},
+		"UpdatePullSecretPolicy": audit{
+			object:  operateObjectSecret,
+			operate: operateTypeUpdate,
+		},
 
 		//ServiceAccount
 		"CreateServiceAccount": audit{
@@ -259,6 +320,22 @@ var (
 			object:  operateObjectDeployment,
 			operate: operateTypePauseOrResume,
 		},
+		"CreateDeploymentCanary": audit{
+			object:  operateObjectDeployment,
+			operate: operateTypeCreateCanary,
+		},
+		"NextDeploymentCanary": audit{
+			object:  operateObjectDeployment,
+			operate: operateTypeNextCanary,
+		},
+		"PromoteDeploymentCanary": audit{
+			object:  operateObjectDeployment,
+			operate: operateTypePromoteCanary,
+		},
+		"AbortDeploymentCanary": audit{
+			object:  operateObjectDeployment,
+			operate: operateTypeAbortCanary,
+		},
 		"DeleteDeploymentContainerSpecEnv": audit{
 			object:  operateObjectDeployment,
 			operate: operateTypeUpdate,
@@ -444,5 +521,67 @@ var (
 			object:  operateObjectHpa,
 			operate: operateTypeDelete,
 		},
+
+		"SetGroupImage": audit{
+			object:  operateObjectImage,
+			operate: operateTypeSetImage,
+		},
+
+		"UpdateWorkloadContainerProbe": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadContainerResources": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"AddWorkloadInitContainer": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadInitContainer": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"DeleteWorkloadInitContainer": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadNodeSelector": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadTolerations": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadAffinity": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateUsageRetention": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"UpdateWorkloadScaleSchedule": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"DeleteWorkloadScaleSchedule": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeDelete,
+		},
+		"UpdateWorkloadAutoRollback": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeUpdate,
+		},
+		"DeleteWorkloadAutoRollback": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeDelete,
+		},
+		"RestartWorkload": audit{
+			object:  operateObjectWorkload,
+			operate: operateTypeRestart,
+		},
 	}
 )
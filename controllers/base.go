@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"time"
 	uaudit "ufleet-deploy/pkg/audit"
+	"ufleet-deploy/pkg/resource"
 	user "ufleet-deploy/pkg/user"
 
 	"github.com/astaxie/beego"
@@ -161,43 +164,75 @@ func (this *baseController) abilityErrorReturn(err error) {
 	}
 }
 
-func (this *baseController) audit(token string, objectName string, meetError bool) {
-	var ad uaudit.AuditObj
-
-	ui := user.NewUserClient(token)
-	username, err := ui.GetUserName()
-	if err != nil {
-		beego.Error(fmt.Sprintf("audit fail for can not get user %v", err))
-		return
-	}
-
+//路由处理函数的名字,skip为到路由处理函数的调用层数
+func routerName(skip int) string {
 	fpcs := make([]uintptr, 4)
-	n := runtime.Callers(2, fpcs)
+	n := runtime.Callers(skip+2, fpcs)
 
 	if n == 0 {
-		beego.Error("audit fail for can not get router's name")
-		return
+		return ""
 	}
 
 	fun := runtime.FuncForPC(fpcs[0] - 1)
 	if fun == nil {
+		return ""
+	}
+
+	sl := strings.Split(fun.Name(), ".")
+	return sl[len(sl)-1]
+}
+
+func (this *baseController) audit(token string, objectName string, meetError bool) {
+	funName := routerName(1)
+	if funName == "" {
 		beego.Error("audit fail for can not get router's name")
-		//			return "n/a"
 		return
 	}
 
-	sl := strings.Split(fun.Name(), ".")
-	funName := sl[len(sl)-1]
 	audit, ok := auditMap[funName]
 	if !ok {
 		beego.Warn("ignore invalid audit router name ")
 		return
 	}
 
+	this.auditOperate(token, objectName, audit, meetError)
+}
+
+//工作负载的修改记录:操作取自路由的审计信息,备注取自comment参数
+//获取不到用户时不记录用户,不影响修改本身
+func (this *baseController) changeOption(token string) resource.UpdateOption {
+	var opt resource.UpdateOption
+	if a, ok := auditMap[routerName(1)]; ok {
+		opt.Operation = a.operate
+	}
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		beego.Warn(fmt.Sprintf("change record without user for can not get user %v", err))
+	} else {
+		opt.User = who
+	}
+
+	opt.Comment = this.GetString("comment")
+	return opt
+}
+
+//审计的对象和操作不由路由决定时使用,如终端的打开和关闭
+func (this *baseController) auditOperate(token string, objectName string, a audit, meetError bool) {
+	var ad uaudit.AuditObj
+
+	ui := user.NewUserClient(token)
+	username, err := ui.GetUserName()
+	if err != nil {
+		beego.Error(fmt.Sprintf("audit fail for can not get user %v", err))
+		return
+	}
+
 	//	ad.Time = time.Now().Format("2006-01-02 15:04:05")
 	ad.Time = time.Now()
-	ad.Object = audit.object
-	ad.Operate = audit.operate
+	ad.Object = a.object
+	ad.Operate = a.operate
 	ad.Operator = username
 	ad.ObjectName = objectName
 	if meetError {
@@ -209,3 +244,33 @@ func (this *baseController) audit(token string, objectName string, meetError boo
 	uaudit.Audit(ad)
 	return
 }
+
+//读取multipart中上传的所有文件,文件名作为key
+func (this *baseController) readUploadFiles(key string) (map[string][]byte, error) {
+	if this.Ctx.Request.MultipartForm == nil {
+		return nil, fmt.Errorf("must upload files with multipart/form-data")
+	}
+	fhs, err := this.GetFiles(key)
+	if err != nil {
+		return nil, fmt.Errorf("must upload files in field '%v'", key)
+	}
+
+	files := make(map[string][]byte)
+	for _, fh := range fhs {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		name := filepath.Base(fh.Filename)
+		if _, ok := files[name]; ok {
+			return nil, fmt.Errorf("duplicate file name '%v'", name)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/configmap"
 	"ufleet-deploy/pkg/user"
@@ -316,8 +317,25 @@ func (this *ConfigMapController) UpdateConfigMapCustom() {
 		return
 	}
 
+	v, err := pk.Controller.GetObject(group, workspace, configmap)
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+	pi, _ := pk.GetConfigMapInterface(v)
+	runtime, err := pi.GetRuntime()
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
 	cm := corev1.ConfigMap{}
 	cm.Name = configmap
+	//保留集群中的标签和注解,如重载策略
+	cm.Labels = runtime.Labels
+	cm.Annotations = runtime.Annotations
 	//	cm.Data = co.Data
 	cm.Data = data
 	cm.Kind = "ConfigMap"
@@ -330,8 +348,17 @@ func (this *ConfigMapController) UpdateConfigMapCustom() {
 		return
 	}
 
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
 	var opt resource.UpdateOption
 	opt.Comment = co.Comment
+	opt.User = who
 
 	err = pk.Controller.UpdateObject(group, workspace, configmap, bytedata, opt)
 	if err != nil {
@@ -341,7 +368,14 @@ func (this *ConfigMapController) UpdateConfigMapCustom() {
 	}
 
 	this.audit(token, configmap, false)
-	this.normalReturn("ok")
+
+	rs, err := pk.Reload(group, workspace, configmap, opt)
+	if err != nil {
+		err = fmt.Errorf("configmap has updated, but reload fail for %v", err)
+		this.errReturn(err, 500)
+		return
+	}
+	this.normalReturn(rs)
 }
 
 // DeleteConfigMap
@@ -408,7 +442,18 @@ func (this *ConfigMapController) UpdateConfigMap() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, configmap, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.User = who
+
+	err = pk.Controller.UpdateObject(group, workspace, configmap, this.Ctx.Input.RequestBody, opt)
 	if err != nil {
 		this.audit(token, configmap, true)
 		this.errReturn(err, 500)
@@ -416,7 +461,14 @@ func (this *ConfigMapController) UpdateConfigMap() {
 	}
 
 	this.audit(token, configmap, false)
-	this.normalReturn("ok")
+
+	rs, err := pk.Reload(group, workspace, configmap, opt)
+	if err != nil {
+		err = fmt.Errorf("configmap has updated, but reload fail for %v", err)
+		this.errReturn(err, 500)
+		return
+	}
+	this.normalReturn(rs)
 }
 
 // GetConfigMapTemplate
@@ -529,3 +581,134 @@ func (this *ConfigMapController) GetConfigMapReferenceObject() {
 
 	this.normalReturn(es)
 }
+
+// ListConfigMapHistory
+// @Title ConfigMap
+// @Description   ConfigMap的历史版本
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param configmap path string true "配置"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:configmap/group/:group/workspace/:workspace/history [Get]
+func (this *ConfigMapController) ListConfigMapHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	configmap := this.Ctx.Input.Param(":configmap")
+
+	rs, err := pk.ListHistory(group, workspace, configmap)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(rs)
+}
+
+// DiffConfigMapHistory
+// @Title ConfigMap
+// @Description   比较ConfigMap的两个历史版本,to为空时与当前数据比较
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param configmap path string true "配置"
+// @Param from query string true "起始版本"
+// @Param to query string false "目标版本"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:configmap/group/:group/workspace/:workspace/history/diff [Get]
+func (this *ConfigMapController) DiffConfigMapHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	configmap := this.Ctx.Input.Param(":configmap")
+
+	from, err := strconv.ParseInt(this.GetString("from"), 10, 64)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	var to int64
+	if toStr := this.GetString("to"); toStr != "" {
+		to, err = strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			this.errReturn(err, 500)
+			return
+		}
+	}
+
+	cs, err := pk.DiffRevision(group, workspace, configmap, from, to)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(cs)
+}
+
+// RestoreConfigMapHistory
+// @Title ConfigMap
+// @Description   将ConfigMap恢复到指定的历史版本
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param configmap path string true "配置"
+// @Param version path string true "版本"
+// @Param comment query string false "注释"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:configmap/group/:group/workspace/:workspace/history/:version/restore [Put]
+func (this *ConfigMapController) RestoreConfigMapHistory() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	configmap := this.Ctx.Input.Param(":configmap")
+
+	version, err := strconv.ParseInt(this.Ctx.Input.Param(":version"), 10, 64)
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.User = who
+	opt.Comment = this.GetString("comment")
+
+	rs, err := pk.Restore(group, workspace, configmap, version, opt)
+	if err != nil {
+		this.audit(token, configmap, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, configmap, false)
+	this.normalReturn(rs)
+}
@@ -207,7 +207,14 @@ func (this *CronJobController) CreateCronJob() {
 
 	var opt resource.CreateOption
 	opt.User = who
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -248,7 +255,14 @@ func (this *CronJobController) UpdateCronJob() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, cronjob, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, cronjob, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, cronjob, body, resource.UpdateOption{})
 	if err != nil {
 		this.audit(token, cronjob, true)
 		this.errReturn(err, 500)
@@ -8,6 +8,7 @@ import (
 	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/daemonset"
+	"ufleet-deploy/pkg/resource/workload"
 	"ufleet-deploy/pkg/user"
 
 	corev1 "k8s.io/api/core/v1"
@@ -157,7 +158,14 @@ func (this *DaemonSetController) CreateDaemonSet() {
 	var opt resource.CreateOption
 	opt.User = who
 
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -199,7 +207,14 @@ func (this *DaemonSetController) UpdateDaemonSet() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, daemonset, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, daemonset, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, body, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -289,7 +304,7 @@ func (this *DaemonSetController) UpdateDaemonSetCustom() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -438,18 +453,26 @@ func (this *DaemonSetController) GetDaemonSetRevisions() {
 		this.errReturn(err, 500)
 		return
 	}
+
+	infos, err := workload.ListRevisions(workload.KindDaemonSet, group, workspace, daemonset)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	//按版本从新到旧返回,附带每个版本的修改记录
 	drs := make([]struct {
-		Revision int    `json:"revision"`
+		workload.RevisionInfo
 		Describe string `json:"describe"`
 	}, 0)
 
-	for k, v := range rm {
+	for _, info := range infos {
 		dr := struct {
-			Revision int    `json:"revision"`
+			workload.RevisionInfo
 			Describe string `json:"describe"`
 		}{}
-		dr.Revision = int(k)
-		dr.Describe = v
+		dr.RevisionInfo = info
+		dr.Describe = rm[info.Revision]
 		drs = append(drs, dr)
 
 	}
@@ -504,6 +527,13 @@ func (this *DaemonSetController) RollBackDaemonSet() {
 
 	}
 
+	opt := this.changeOption(token)
+	opt.Operation = fmt.Sprintf("%v to revision %v", opt.Operation, toRevision)
+	err = workload.RecordChange(workload.KindDaemonSet, group, workspace, daemonset, opt)
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", daemonset, err)
+	}
+
 	this.audit(token, daemonset, false)
 	this.normalReturn(*result)
 }
@@ -640,7 +670,7 @@ func (this *DaemonSetController) AddDaemonSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -731,7 +761,7 @@ func (this *DaemonSetController) DeleteDaemonSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -820,7 +850,7 @@ func (this *DaemonSetController) UpdateDaemonSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -940,7 +970,7 @@ func (this *DaemonSetController) AddDaemonSetContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -1006,7 +1036,7 @@ func (this *DaemonSetController) DeleteDaemonSetContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, daemonset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, daemonset, true)
 		this.errReturn(err, 500)
@@ -1053,3 +1083,41 @@ func (this *DaemonSetController) GetDaemonSetServices() {
 
 	this.normalReturn(services)
 }
+
+// GetDaemonSetRevisionDiff
+// @Title DaemonSet
+// @Description   比较DaemonSet两个版本的Pod模板,返回统一格式diff和镜像,环境变量,资源的变化,以及各版本的修改记录
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param daemonset path string true "守护进程"
+// @Param from query int true "旧版本"
+// @Param to query int false "新版本,为空时与当前的Pod模板比较"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:daemonset/group/:group/workspace/:workspace/revisions/diff [Get]
+func (this *DaemonSetController) GetDaemonSetRevisionDiff() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	daemonset := this.Ctx.Input.Param(":daemonset")
+
+	from, to, err := this.getRevisionDiffParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	d, err := workload.DiffRevisions(workload.KindDaemonSet, group, workspace, daemonset, from, to)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(d)
+}
@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"strconv"
 	"ufleet-deploy/models"
+	"ufleet-deploy/pkg/autoscaler"
+	"ufleet-deploy/pkg/canary"
+	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/deployment"
+	"ufleet-deploy/pkg/resource/workload"
 	"ufleet-deploy/pkg/user"
 
 	corev1 "k8s.io/api/core/v1"
@@ -157,7 +161,14 @@ func (this *DeploymentController) CreateDeployment() {
 	var opt resource.CreateOption
 	opt.User = who
 
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -197,7 +208,14 @@ func (this *DeploymentController) UpdateDeployment() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, deployment, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, deployment, body, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -286,7 +304,7 @@ func (this *DeploymentController) UpdateDeploymentCustom() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -413,6 +431,11 @@ func (this *DeploymentController) ScaleDeployment() {
 		return
 	}
 
+	err = workload.RecordChange(workload.KindDeployment, group, workspace, deployment, this.changeOption(token))
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", deployment, err)
+	}
+
 	this.audit(token, deployment, false)
 	this.normalReturn("ok")
 }
@@ -474,6 +497,11 @@ func (this *DeploymentController) ScaleDeploymentIncrement() {
 		return
 	}
 
+	err = workload.RecordChange(workload.KindDeployment, group, workspace, deployment, this.changeOption(token))
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", deployment, err)
+	}
+
 	this.audit(token, deployment, false)
 	this.normalReturn("ok")
 }
@@ -615,18 +643,26 @@ func (this *DeploymentController) GetDeploymentRevisions() {
 		this.errReturn(err, 500)
 		return
 	}
+
+	infos, err := workload.ListRevisions(workload.KindDeployment, group, workspace, deployment)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	//按版本从新到旧返回,附带每个版本的修改记录
 	drs := make([]struct {
-		Revision int    `json:"revision"`
+		workload.RevisionInfo
 		Describe string `json:"describe"`
 	}, 0)
 
-	for k, v := range rm {
+	for _, info := range infos {
 		dr := struct {
-			Revision int    `json:"revision"`
+			workload.RevisionInfo
 			Describe string `json:"describe"`
 		}{}
-		dr.Revision = int(k)
-		dr.Describe = v
+		dr.RevisionInfo = info
+		dr.Describe = rm[info.Revision]
 		drs = append(drs, dr)
 
 	}
@@ -681,6 +717,13 @@ func (this *DeploymentController) RollBackDeployment() {
 
 	}
 
+	opt := this.changeOption(token)
+	opt.Operation = fmt.Sprintf("%v to revision %v", opt.Operation, toRevision)
+	err = workload.RecordChange(workload.KindDeployment, group, workspace, deployment, opt)
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", deployment, err)
+	}
+
 	this.audit(token, deployment, false)
 	this.normalReturn(*result)
 }
@@ -723,6 +766,36 @@ func (this *DeploymentController) GetHPA() {
 	this.normalReturn(*result)
 }
 
+// GetHPADecisions
+// @Title Deployment
+// @Description  获取Deployment按磁盘/网络弹性伸缩的判断记录,最近的在前
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/hpa/decisions [Get]
+func (this *DeploymentController) GetHPADecisions() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	_, err := pk.Controller.GetObject(group, workspace, deployment)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(autoscaler.GetDecisions(group, workspace, deployment))
+}
+
 // GetAllHpa
 // @Title Deployment
 // @Description  获取 all deployed Deployment hpa
@@ -826,14 +899,16 @@ func (this *DeploymentController) StartHPA() {
 		return
 	}
 
-	v, err := pk.Controller.GetObject(group, workspace, deployment)
-	if err != nil {
-		this.audit(token, deployment, true)
-		this.errReturn(err, 500)
-		return
+	if opt.Type != pk.AutoScaleTypeNone {
+		err = canary.CheckScalable(group, workspace, deployment)
+		if err != nil {
+			this.audit(token, deployment, true)
+			this.errReturn(err, 500)
+			return
+		}
 	}
-	pi, _ := pk.GetDeploymentInterface(v)
-	err = pi.StartAutoScale(opt)
+
+	err = pk.StartAutoScale(group, workspace, deployment, opt)
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1005,7 +1080,7 @@ func (this *DeploymentController) AddDeploymentContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1073,7 +1148,7 @@ func (this *DeploymentController) DeleteDeploymentContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1154,7 +1229,7 @@ func (this *DeploymentController) UpdateDeploymentContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1280,7 +1355,7 @@ func (this *DeploymentController) AddDeploymentContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1349,7 +1424,7 @@ func (this *DeploymentController) DeleteDeploymentContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, deployment, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, deployment, true)
 		this.errReturn(err, 500)
@@ -1398,3 +1473,222 @@ func (this *DeploymentController) GetDeploymentServices() {
 	this.normalReturn(services)
 
 }
+
+// GetDeploymentRevisionDiff
+// @Title Deployment
+// @Description   比较Deployment两个版本的Pod模板,返回统一格式diff和镜像,环境变量,资源的变化,以及各版本的修改记录
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Param from query int true "旧版本"
+// @Param to query int false "新版本,为空时与当前的Pod模板比较"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/revisions/diff [Get]
+func (this *DeploymentController) GetDeploymentRevisionDiff() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	from, to, err := this.getRevisionDiffParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	d, err := workload.DiffRevisions(workload.KindDeployment, group, workspace, deployment, from, to)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(d)
+}
+
+// GetDeploymentCanary
+// @Title Deployment
+// @Description   获取Deployment最近一次金丝雀发布
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/canary [Get]
+func (this *DeploymentController) GetDeploymentCanary() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	c, err := canary.Get(group, workspace, deployment)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(c)
+}
+
+// CreateDeploymentCanary
+// @Title Deployment
+// @Description   用新的Pod模板创建金丝雀版本,按步骤逐步切换流量,步骤之间暂停等待手动推进或者到期后根据分析结果自动推进
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Param body body string true "金丝雀发布参数"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/canary [Post]
+func (this *DeploymentController) CreateDeploymentCanary() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit canary option")
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt canary.CreateOption
+	err := json.Unmarshal(this.Ctx.Input.RequestBody, &opt)
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+	opt.User = this.changeOption(token).User
+
+	c, err := canary.Create(group, workspace, deployment, opt)
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, deployment, false)
+	this.normalReturn(c)
+}
+
+// NextDeploymentCanary
+// @Title Deployment
+// @Description   金丝雀发布进入下一步,已经是最后一步时推广金丝雀版本
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Param comment query string false "修改说明"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/canary/next [Put]
+func (this *DeploymentController) NextDeploymentCanary() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	c, err := canary.Next(group, workspace, deployment, this.changeOption(token))
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, deployment, false)
+	this.normalReturn(c)
+}
+
+// PromoteDeploymentCanary
+// @Title Deployment
+// @Description   把金丝雀版本的Pod模板合并到稳定版本,稳定版本升级完成后删除金丝雀版本
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Param comment query string false "修改说明"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/canary/promote [Put]
+func (this *DeploymentController) PromoteDeploymentCanary() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	c, err := canary.Promote(group, workspace, deployment, this.changeOption(token))
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, deployment, false)
+	this.normalReturn(c)
+}
+
+// AbortDeploymentCanary
+// @Title Deployment
+// @Description   中止金丝雀发布,删除金丝雀版本并恢复稳定版本的流量
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param deployment path string true "部署"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:deployment/group/:group/workspace/:workspace/canary/abort [Put]
+func (this *DeploymentController) AbortDeploymentCanary() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	deployment := this.Ctx.Input.Param(":deployment")
+
+	c, err := canary.Abort(group, workspace, deployment, this.changeOption(token).User)
+	if err != nil {
+		this.audit(token, deployment, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, deployment, false)
+	this.normalReturn(c)
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"ufleet-deploy/pkg/resource/workload"
+)
+
+type ImageController struct {
+	baseController
+}
+
+// ListGroupImages
+// @Title Image
+// @Description   组中使用的镜像
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param app query string false "应用"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group [Get]
+func (this *ImageController) ListGroupImages() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	app := this.GetString("app")
+
+	ius, err := workload.ImageInventory(group, "", app)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ius)
+}
+
+// ListGroupWorkspaceImages
+// @Title Image
+// @Description   工作区中使用的镜像
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param app query string false "应用"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/workspace/:workspace [Get]
+func (this *ImageController) ListGroupWorkspaceImages() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	app := this.GetString("app")
+
+	ius, err := workload.ImageInventory(group, workspace, app)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ius)
+}
+
+// SetGroupImage
+// @Title Image
+// @Description   批量修改工作负载的镜像
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param body body string true "镜像修改选项"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/setimage [Put]
+func (this *ImageController) SetGroupImage() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit set image option")
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt workload.SetImageOption
+	err := json.Unmarshal(this.Ctx.Input.RequestBody, &opt)
+	if err != nil {
+		err = fmt.Errorf("try to unmarshal data \"%v\" fail for %v", string(this.Ctx.Input.RequestBody), err)
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	co := this.changeOption(token)
+	opt.User = co.User
+	opt.Operation = co.Operation
+	if opt.Comment == "" {
+		opt.Comment = co.Comment
+	}
+
+	rs, err := workload.SetImage(group, opt)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	for _, v := range rs {
+		name := fmt.Sprintf("%v/%v/%v", v.Kind, v.Workspace, v.Name)
+		this.audit(token, name, v.Error != "")
+	}
+
+	this.normalReturn(rs)
+}
@@ -204,7 +204,14 @@ func (this *JobController) CreateJob() {
 	var opt resource.CreateOption
 	opt.User = who
 
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -246,7 +253,14 @@ func (this *JobController) UpdateJob() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, job, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, job, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, job, body, resource.UpdateOption{})
 	if err != nil {
 		this.audit(token, job, true)
 		this.errReturn(err, 500)
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"time"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource/workload"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	logStreamBufferSize = 32 * 1024
+)
+
+//从请求参数中解析日志选项
+//follow,tailLines,sinceSeconds,sinceTime(RFC3339),timestamps,previous,limitBytes
+func (this *baseController) getLogOption() (*cluster.LogOption, error) {
+	var opt cluster.LogOption
+	var err error
+
+	opt.Follow, err = this.GetBool("follow", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid follow: %v", err)
+	}
+	opt.Timestamps, err = this.GetBool("timestamps", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timestamps: %v", err)
+	}
+	opt.Previous, err = this.GetBool("previous", false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid previous: %v", err)
+	}
+	opt.DisplayTailLine, err = this.GetInt64("tailLines", 0)
+	if err != nil || opt.DisplayTailLine < 0 {
+		return nil, fmt.Errorf("invalid tailLines '%v'", this.GetString("tailLines"))
+	}
+	opt.SinceSeconds, err = this.GetInt64("sinceSeconds", 0)
+	if err != nil || opt.SinceSeconds < 0 {
+		return nil, fmt.Errorf("invalid sinceSeconds '%v'", this.GetString("sinceSeconds"))
+	}
+	opt.LimitBytes, err = this.GetInt64("limitBytes", 0)
+	if err != nil || opt.LimitBytes < 0 {
+		return nil, fmt.Errorf("invalid limitBytes '%v'", this.GetString("limitBytes"))
+	}
+
+	if st := this.GetString("sinceTime"); st != "" {
+		if opt.SinceSeconds > 0 {
+			return nil, fmt.Errorf("sinceSeconds and sinceTime can't be set at the same time")
+		}
+		t, err := time.Parse(time.RFC3339, st)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sinceTime '%v', must be RFC3339 format", st)
+		}
+		opt.SinceTime = &t
+	}
+	return &opt, nil
+}
+
+//以chunked方式将日志流写回,每次读取后立即flush
+//客户端断开时关闭日志流
+func (this *baseController) streamReturn(rc io.ReadCloser) {
+	defer rc.Close()
+
+	w := this.Ctx.ResponseWriter
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(200)
+	w.Flush()
+
+	done := make(chan struct{})
+	defer close(done)
+	if cn := w.CloseNotify(); cn != nil {
+		go func() {
+			select {
+			case <-cn:
+				rc.Close()
+			case <-done:
+			}
+		}()
+	}
+
+	buf := make([]byte, logStreamBufferSize)
+	for {
+		n, err := rc.Read(buf)
+		if n > 0 {
+			_, werr := w.Write(buf[:n])
+			if werr != nil {
+				return
+			}
+			w.Flush()
+		}
+		if err != nil {
+			if err != io.EOF {
+				log.DebugPrint("log stream closed: %v", err)
+			}
+			return
+		}
+	}
+}
+
+//多Pod日志的选项,在getLogOption的基础上增加container和grep
+func (this *baseController) getAggregateLogOption() (*workload.AggregateLogOption, error) {
+	opt, err := this.getLogOption()
+	if err != nil {
+		return nil, err
+	}
+	var aopt workload.AggregateLogOption
+	aopt.LogOption = *opt
+	aopt.Container = this.GetString("container")
+	aopt.Grep = this.GetString("grep")
+	return &aopt, nil
+}
+
+//返回多个Pod合并后的日志,download为true时返回zip包,每个容器一个文件
+func (this *baseController) aggregatedLogReturn(group, workspace, name string, pods []*corev1.Pod, download bool) {
+	opt, err := this.getAggregateLogOption()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	targets := workload.LogTargets(pods, opt.Container)
+	if len(targets) == 0 {
+		this.errReturn(fmt.Errorf("no container found in '%v'", name), 500)
+		return
+	}
+
+	if !download {
+		rc, err := workload.OpenAggregatedLogs(group, workspace, targets, *opt)
+		if err != nil {
+			this.errReturn(err, 500)
+			return
+		}
+		this.streamReturn(rc)
+		return
+	}
+
+	rc, err := workload.OpenLogsZip(group, workspace, targets, *opt)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	defer rc.Close()
+	w := this.Ctx.ResponseWriter
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v-logs.zip\"", name))
+	w.WriteHeader(200)
+	_, err = io.Copy(w, rc)
+	if err != nil {
+		log.DebugPrint("write logs zip fail: %v", err)
+	}
+}
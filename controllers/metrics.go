@@ -0,0 +1,46 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+	"ufleet-deploy/pkg/metrics"
+	"ufleet-deploy/pkg/usage"
+)
+
+//时间序列对齐的间隔,参数step单位为秒
+func (this *baseController) getMetricsStep() (time.Duration, error) {
+	step, err := this.GetInt64("step", 0)
+	if err != nil || step < 0 {
+		return 0, fmt.Errorf("invalid step '%v'", this.GetString("step"))
+	}
+	if step == 0 {
+		return metrics.DefaultStep, nil
+	}
+	return time.Duration(step) * time.Second, nil
+}
+
+//资源使用历史的查询参数,start/end为RFC3339格式,默认查询最近一小时
+func (this *baseController) getUsageQueryOption() (*usage.QueryOption, error) {
+	var opt usage.QueryOption
+	if s := this.GetString("start"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start '%v'", s)
+		}
+		opt.Start = t
+	}
+	if s := this.GetString("end"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end '%v'", s)
+		}
+		opt.End = t
+	}
+	step, err := this.GetInt64("step", 0)
+	if err != nil || step < 0 {
+		return nil, fmt.Errorf("invalid step '%v'", this.GetString("step"))
+	}
+	opt.Step = time.Duration(step) * time.Second
+	opt.Aggregation = this.GetString("agg")
+	return &opt, nil
+}
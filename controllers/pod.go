@@ -1,10 +1,20 @@
 package controllers
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/pod"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/pkg/usage"
 	"ufleet-deploy/pkg/user"
 	//	"ufleet-deploy/util/user"
 	corev1 "k8s.io/api/core/v1"
@@ -491,6 +501,58 @@ func (this *PodController) GetPodLog() {
 	this.normalReturn(logs)
 }
 
+// StreamPodLog
+// @Title Pod
+// @Description   以流的方式获取容器日志
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param container path string true "容器"
+// @Param follow query bool false "是否持续输出"
+// @Param tailLines query int false "最后的行数"
+// @Param sinceSeconds query int false "最近的秒数"
+// @Param sinceTime query string false "起始时间,RFC3339格式"
+// @Param timestamps query bool false "是否显示时间戳"
+// @Param previous query bool false "是否获取上一次终止的容器的日志"
+// @Param limitBytes query int false "最大字节数"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/container/:container/log/stream [Get]
+func (this *PodController) StreamPodLog() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+	c := this.Ctx.Input.Param(":container")
+
+	opt, err := this.getLogOption()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	v, err := pk.Controller.GetObject(group, workspace, pod)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	pi, _ := pk.GetPodInterface(v)
+
+	rc, err := pi.LogStream(c, *opt)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.streamReturn(rc)
+}
+
 // GetPodContainers
 // @Title Pod
 // @Description   Pod container stat
@@ -530,6 +592,91 @@ func (this *PodController) GetPodStat() {
 	this.normalReturn(logs)
 }
 
+// GetPodStats
+// @Title Pod
+// @Description   Pod中所有容器的资源使用时间序列,total为各容器之和
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param step query int false "对齐的间隔(秒),默认15"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/stat [Get]
+func (this *PodController) GetPodStats() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+
+	step, err := this.getMetricsStep()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	v, err := pk.Controller.GetObject(group, workspace, pod)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	pi, _ := pk.GetPodInterface(v)
+
+	ps, err := pi.StatPod(step)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ps)
+}
+
+// GetPodUsage
+// @Title Pod
+// @Description   Pod的资源使用历史
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param start query string false "开始时间(RFC3339),默认结束时间前一小时"
+// @Param end query string false "结束时间(RFC3339),默认当前时间"
+// @Param step query int false "聚合的间隔(秒),默认为存储精度"
+// @Param agg query string false "聚合方式,avg/max/min,默认avg"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/usage [Get]
+func (this *PodController) GetPodUsage() {
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+
+	opt, err := this.getUsageQueryOption()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	key := usage.SeriesKey(workload.KindPod, group, workspace, pod)
+	us, err := usage.Query(pod, []string{key}, *opt)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(us)
+}
+
 // GetPodContainers
 // @Title Pod
 // @Description   Pod container event
@@ -567,18 +714,26 @@ func (this *PodController) GetPodEvent() {
 	this.normalReturn(es)
 }
 
-// GetPodContainers
+// GetPodTerminal
 // @Title Pod
-// @Description   Pod container terminal
-// @Param Token header string true 'Token'
+// @Description   通过WebSocket打开容器的终端,浏览器可以用token参数代替请求头
+// @Param Token header string false 'Token'
+// @Param token query string false "Token"
 // @Param group path string true "组名"
 // @Param workspace path string true "工作区"
 // @Param pod path string true "容器组"
 // @Param container path string true "容器"
-// @Success 201 {string} create success!
+// @Param shell query string false "shell(sh,bash,ash,dash,zsh,ksh),默认优先使用bash"
+// @Success 101 {string} switching protocols
 // @Failure 500
 // @router /:pod/group/:group/workspace/:workspace/container/:container/terminal [Get]
 func (this *PodController) GetPodTerminal() {
+	token := this.Ctx.Request.Header.Get("token")
+	if token == "" {
+		//浏览器的WebSocket不能设置请求头
+		token = this.GetString("token")
+		this.Ctx.Request.Header.Set("token", token)
+	}
 	err := this.checkRouteControllerAbility()
 	if err != nil {
 		this.abilityErrorReturn(err)
@@ -589,22 +744,300 @@ func (this *PodController) GetPodTerminal() {
 	workspace := this.Ctx.Input.Param(":workspace")
 	pod := this.Ctx.Input.Param(":pod")
 	c := this.Ctx.Input.Param(":container")
+	objectName := pod + "/" + c
+
+	err = cluster.CheckWorkspacePermission(group, workspace, token)
+	if err != nil {
+		this.errReturn(err, 403)
+		return
+	}
+
+	command, err := terminalCommand(this.GetString("shell"))
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
 
 	v, err := pk.Controller.GetObject(group, workspace, pod)
 	if err != nil {
 		this.errReturn(err, 500)
 		return
 	}
+	pi, _ := pk.GetPodInterface(v)
+
+	conn, err := terminalUpgrader.Upgrade(this.Ctx.ResponseWriter, this.Ctx.Request, nil)
+	if err != nil {
+		//Upgrade已经返回了错误
+		log.DebugPrint("upgrade terminal of %v to websocket fail for %v", objectName, err)
+		return
+	}
+	//连接已被接管,不再输出
+	this.Ctx.ResponseWriter.Started = true
+
+	ts := newTerminalSession(conn)
+	this.auditOperate(token, objectName, audit{object: operateObjectPod, operate: operateTypeTerminalStart}, false)
+
+	var opt cluster.ExecOption
+	opt.Container = c
+	opt.Command = command
+	opt.Stdin = ts
+	opt.Stdout = ts
+	opt.TTY = true
+	opt.SizeQueue = ts
+	err = pi.Terminal(opt)
+
+	ts.Close(err)
+	this.auditOperate(token, objectName, audit{object: operateObjectPod, operate: operateTypeTerminalEnd}, err != nil)
+}
 
+// PodPortForward
+// @Title Pod
+// @Description   通过WebSocket转发到Pod的端口,二进制消息为TCP数据,浏览器可以用token参数代替请求头
+// @Param Token header string false 'Token'
+// @Param token query string false "Token"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param port path int true "Pod端口"
+// @Success 101 {string} switching protocols
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/portforward/:port [Get]
+func (this *PodController) PodPortForward() {
+	token := this.Ctx.Request.Header.Get("token")
+	if token == "" {
+		//浏览器的WebSocket不能设置请求头
+		token = this.GetString("token")
+		this.Ctx.Request.Header.Set("token", token)
+	}
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+	objectName := pod + ":" + this.Ctx.Input.Param(":port")
+
+	port, err := strconv.Atoi(this.Ctx.Input.Param(":port"))
+	if err != nil || port <= 0 || port > 65535 {
+		err = fmt.Errorf("invalid port '%v'", this.Ctx.Input.Param(":port"))
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = cluster.CheckWorkspacePermission(group, workspace, token)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 403)
+		return
+	}
+
+	v, err := pk.Controller.GetObject(group, workspace, pod)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
 	pi, _ := pk.GetPodInterface(v)
 
-	logs, err := pi.Terminal(c)
+	conn, err := terminalUpgrader.Upgrade(this.Ctx.ResponseWriter, this.Ctx.Request, nil)
+	if err != nil {
+		//Upgrade已经返回了错误
+		log.DebugPrint("upgrade port forward of %v to websocket fail for %v", objectName, err)
+		this.audit(token, objectName, true)
+		return
+	}
+	//连接已被接管,不再输出
+	this.Ctx.ResponseWriter.Started = true
+	this.audit(token, objectName, false)
+
+	ws := newWebsocketStream(conn)
+	err = pi.PortForward(port, ws)
+	if err != nil {
+		log.DebugPrint("port forward %v/%v/%v fail for %v", group, workspace, objectName, err)
+	}
+	ws.Close(err)
+}
+
+// DownloadPodFile
+// @Title Pod
+// @Description   以tar包下载容器中的文件或目录,容器中需要有tar命令
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param container path string true "容器"
+// @Param path query string true "文件或目录的绝对路径"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/container/:container/file [Get]
+func (this *PodController) DownloadPodFile() {
+	token := this.Ctx.Request.Header.Get("token")
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+	c := this.Ctx.Input.Param(":container")
+	src := this.GetString("path")
+	objectName := fmt.Sprintf("%v/%v: download %v", pod, c, src)
+
+	if src == "" {
+		err = fmt.Errorf("must offer path")
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = cluster.CheckWorkspacePermission(group, workspace, token)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 403)
+		return
+	}
+
+	v, err := pk.Controller.GetObject(group, workspace, pod)
 	if err != nil {
+		this.audit(token, objectName, true)
 		this.errReturn(err, 500)
 		return
 	}
+	pi, _ := pk.GetPodInterface(v)
 
-	this.normalReturn(logs)
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(pi.CopyFrom(c, src, pw))
+	}()
+
+	//有输出之前出错时,仍然可以返回错误信息
+	br := bufio.NewReader(pr)
+	_, err = br.Peek(1)
+	if err != nil {
+		pr.Close()
+		if err == io.EOF {
+			err = fmt.Errorf("'%v' is empty", src)
+		}
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+	this.audit(token, objectName, false)
+
+	name := path.Base(path.Clean(src))
+	if name == "/" || name == "." {
+		name = "root"
+	}
+	w := this.Ctx.ResponseWriter
+	w.Header().Set("Content-Type", "application/x-tar")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v.tar\"", name))
+	w.WriteHeader(200)
+	_, err = io.Copy(w, br)
+	pr.Close()
+	if err != nil {
+		log.DebugPrint("download %v/%v/%v fail for %v", group, workspace, objectName, err)
+	}
+}
+
+// UploadPodFile
+// @Title Pod
+// @Description   上传文件到容器的目录中,目录不存在时创建.以multipart上传多个文件,或者以tar包作为请求体上传目录.容器中需要有sh和tar命令
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param pod path string true "容器组"
+// @Param container path string true "容器"
+// @Param path query string true "目标目录的绝对路径"
+// @Param files formData file false "文件"
+// @Param body body string false "tar包"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:pod/group/:group/workspace/:workspace/container/:container/file [Post]
+func (this *PodController) UploadPodFile() {
+	token := this.Ctx.Request.Header.Get("token")
+	err := this.checkRouteControllerAbility()
+	if err != nil {
+		this.abilityErrorReturn(err)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	pod := this.Ctx.Input.Param(":pod")
+	c := this.Ctx.Input.Param(":container")
+	dest := this.GetString("path")
+	objectName := fmt.Sprintf("%v/%v: upload to %v", pod, c, dest)
+
+	if dest == "" {
+		err = fmt.Errorf("must offer path")
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = cluster.CheckWorkspacePermission(group, workspace, token)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 403)
+		return
+	}
+
+	var archive []byte
+	if this.Ctx.Request.MultipartForm != nil {
+		files, err := this.readUploadFiles("files")
+		if err != nil {
+			this.audit(token, objectName, true)
+			this.errReturn(err, 500)
+			return
+		}
+		archive, err = pk.TarFiles(files)
+		if err != nil {
+			this.audit(token, objectName, true)
+			this.errReturn(err, 500)
+			return
+		}
+	} else {
+		archive = this.Ctx.Input.RequestBody
+	}
+	if len(archive) == 0 {
+		err = fmt.Errorf("must upload files or tar archive")
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	//审计中记录上传的文件名
+	names, err := pk.TarNames(archive)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+	objectName = fmt.Sprintf("%v: %v", objectName, strings.Join(names, ","))
+
+	v, err := pk.Controller.GetObject(group, workspace, pod)
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+	pi, _ := pk.GetPodInterface(v)
+
+	err = pi.CopyTo(c, dest, bytes.NewReader(archive))
+	if err != nil {
+		this.audit(token, objectName, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, objectName, false)
+	this.normalReturn("ok")
 }
 
 // GetPodContainerSpec
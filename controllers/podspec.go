@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"fmt"
+	"strings"
 	"ufleet-deploy/pkg/log"
 
 	corev1 "k8s.io/api/core/v1"
@@ -443,3 +444,124 @@ func deleteVolumeAndContaienrVolumeMounts(podSpec corev1.PodSpec, volume string)
 
 	return newPodSpec, nil
 }
+
+type ContainerProbe struct {
+	Liveness  *corev1.Probe `json:"liveness"`
+	Readiness *corev1.Probe `json:"readiness"`
+}
+
+func getPodSpecContainerIndex(podSpec corev1.PodSpec, container string) (int, error) {
+	for k, v := range podSpec.Containers {
+		if v.Name == container {
+			return k, nil
+		}
+	}
+	return 0, fmt.Errorf("container not found")
+}
+
+func getPodSpecContainerProbe(podSpec corev1.PodSpec, container string) (*ContainerProbe, error) {
+	k, err := getPodSpecContainerIndex(podSpec, container)
+	if err != nil {
+		return nil, err
+	}
+
+	var cp ContainerProbe
+	cp.Liveness = podSpec.Containers[k].LivenessProbe
+	cp.Readiness = podSpec.Containers[k].ReadinessProbe
+	return &cp, nil
+}
+
+//探针为nil表示删除该探针
+func updatePodSpecContainerProbe(podSpec corev1.PodSpec, container string, probe ContainerProbe) (corev1.PodSpec, error) {
+	k, err := getPodSpecContainerIndex(podSpec, container)
+	if err != nil {
+		return corev1.PodSpec{}, err
+	}
+
+	newPodSpec := podSpec
+	newPodSpec.Containers = make([]corev1.Container, 0)
+	newPodSpec.Containers = append(newPodSpec.Containers, podSpec.Containers...)
+
+	newPodSpec.Containers[k].LivenessProbe = probe.Liveness
+	newPodSpec.Containers[k].ReadinessProbe = probe.Readiness
+	return newPodSpec, nil
+}
+
+func updatePodSpecContainerResources(podSpec corev1.PodSpec, container string, res corev1.ResourceRequirements) (corev1.PodSpec, error) {
+	k, err := getPodSpecContainerIndex(podSpec, container)
+	if err != nil {
+		return corev1.PodSpec{}, err
+	}
+
+	newPodSpec := podSpec
+	newPodSpec.Containers = make([]corev1.Container, 0)
+	newPodSpec.Containers = append(newPodSpec.Containers, podSpec.Containers...)
+
+	newPodSpec.Containers[k].Resources = res
+	return newPodSpec, nil
+}
+
+func addPodSpecInitContainer(podSpec corev1.PodSpec, container corev1.Container) (corev1.PodSpec, error) {
+	if strings.TrimSpace(container.Name) == "" {
+		return corev1.PodSpec{}, fmt.Errorf("init container must have name")
+	}
+	for _, v := range podSpec.InitContainers {
+		if v.Name == container.Name {
+			return corev1.PodSpec{}, fmt.Errorf("init container '%v' has exist in pod spec", container.Name)
+		}
+	}
+	for _, v := range podSpec.Containers {
+		if v.Name == container.Name {
+			return corev1.PodSpec{}, fmt.Errorf("container '%v' has exist in pod spec", container.Name)
+		}
+	}
+
+	newPodSpec := podSpec
+	newPodSpec.InitContainers = make([]corev1.Container, 0)
+	newPodSpec.InitContainers = append(newPodSpec.InitContainers, podSpec.InitContainers...)
+	newPodSpec.InitContainers = append(newPodSpec.InitContainers, container)
+	return newPodSpec, nil
+}
+
+func updatePodSpecInitContainer(podSpec corev1.PodSpec, name string, container corev1.Container) (corev1.PodSpec, error) {
+	var found bool
+	var k int
+	for k = range podSpec.InitContainers {
+		if podSpec.InitContainers[k].Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return corev1.PodSpec{}, fmt.Errorf("init container '%v' not found", name)
+	}
+	if container.Name != name {
+		return corev1.PodSpec{}, fmt.Errorf("init container name not match")
+	}
+
+	newPodSpec := podSpec
+	newPodSpec.InitContainers = make([]corev1.Container, 0)
+	newPodSpec.InitContainers = append(newPodSpec.InitContainers, podSpec.InitContainers...)
+	newPodSpec.InitContainers[k] = container
+	return newPodSpec, nil
+}
+
+func deletePodSpecInitContainer(podSpec corev1.PodSpec, name string) (corev1.PodSpec, error) {
+	var found bool
+	var k int
+	for k = range podSpec.InitContainers {
+		if podSpec.InitContainers[k].Name == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return corev1.PodSpec{}, fmt.Errorf("init container '%v' not found", name)
+	}
+
+	newPodSpec := podSpec
+	newPodSpec.InitContainers = make([]corev1.Container, 0)
+	newPodSpec.InitContainers = append(newPodSpec.InitContainers, podSpec.InitContainers[:k]...)
+	newPodSpec.InitContainers = append(newPodSpec.InitContainers, podSpec.InitContainers[k+1:]...)
+	return newPodSpec, nil
+}
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+//将WebSocket作为字节流使用,每个二进制消息为一段数据
+type websocketStream struct {
+	conn   *websocket.Conn
+	reader io.Reader
+	wlock  sync.Mutex
+	once   sync.Once
+}
+
+func newWebsocketStream(conn *websocket.Conn) *websocketStream {
+	return &websocketStream{conn: conn}
+}
+
+//连接断开时返回EOF
+func (s *websocketStream) Read(p []byte) (int, error) {
+	for {
+		if s.reader == nil {
+			mt, r, err := s.conn.NextReader()
+			if err != nil {
+				return 0, io.EOF
+			}
+			if mt != websocket.BinaryMessage && mt != websocket.TextMessage {
+				continue
+			}
+			s.reader = r
+		}
+		n, err := s.reader.Read(p)
+		if err == io.EOF {
+			s.reader = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (s *websocketStream) Write(p []byte) (int, error) {
+	s.wlock.Lock()
+	defer s.wlock.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+	err := s.conn.WriteMessage(websocket.BinaryMessage, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//关闭连接,reason不为空时作为关闭原因
+func (s *websocketStream) Close(reason error) {
+	s.once.Do(func() {
+		code := websocket.CloseNormalClosure
+		text := ""
+		if reason != nil {
+			code = websocket.CloseInternalServerErr
+			text = reason.Error()
+			//关闭原因最多123字节
+			if len(text) > 123 {
+				text = text[:123]
+			}
+		}
+		s.wlock.Lock()
+		s.conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+		s.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, text))
+		s.wlock.Unlock()
+		s.conn.Close()
+	})
+}
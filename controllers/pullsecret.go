@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/resource/pullsecret"
+	"ufleet-deploy/pkg/user"
+)
+
+//组开启了自动注入时,为模板中使用组镜像仓库的工作负载添加imagePullSecrets
+//没有开启或不需要注入时返回原模板
+func injectPullSecrets(token, group, workspace string, data []byte) ([]byte, error) {
+	if data == nil {
+		return data, nil
+	}
+	enabled, err := pullsecret.Enabled(group)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return data, nil
+	}
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		return nil, err
+	}
+	regs, err := ui.GetRegistrysFromGroup(group)
+	if err != nil {
+		return nil, fmt.Errorf("get registries of group '%v' fail for %v", group, err)
+	}
+	return pullsecret.Inject(group, workspace, who, regs, data)
+}
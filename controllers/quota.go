@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"ufleet-deploy/pkg/quota"
+)
+
+type QuotaController struct {
+	baseController
+}
+
+// GetWorkspaceQuotaUsage
+// @Title Quota
+// @Description   工作区配额及使用情况
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/workspace/:workspace [Get]
+func (this *QuotaController) GetWorkspaceQuotaUsage() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+
+	u, err := quota.GetUsage(group, workspace)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(u)
+}
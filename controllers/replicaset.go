@@ -7,6 +7,7 @@ import (
 	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/replicaset"
+	"ufleet-deploy/pkg/resource/workload"
 	"ufleet-deploy/pkg/user"
 
 	corev1 "k8s.io/api/core/v1"
@@ -209,7 +210,14 @@ func (this *ReplicaSetController) CreateReplicaSet() {
 	var opt resource.CreateOption
 	opt.User = who
 
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -271,7 +279,14 @@ func (this *ReplicaSetController) UpdateReplicaSet() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, replicaset, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, body, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -329,6 +344,11 @@ func (this *ReplicaSetController) ScaleReplicaSet() {
 		return
 	}
 
+	err = workload.RecordChange(workload.KindReplicaSet, group, workspace, replicaset, this.changeOption(token))
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", replicaset, err)
+	}
+
 	this.audit(token, replicaset, false)
 	this.normalReturn("ok")
 
@@ -585,7 +605,7 @@ func (this *ReplicaSetController) AddReplicaSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -689,7 +709,7 @@ func (this *ReplicaSetController) DeleteReplicaSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -794,7 +814,7 @@ func (this *ReplicaSetController) UpdateReplicaSetContainerSpecEnv() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -926,7 +946,7 @@ func (this *ReplicaSetController) AddReplicaSetContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -1005,7 +1025,7 @@ func (this *ReplicaSetController) DeleteReplicaSetContainerSpecVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicaset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicaset, true)
 		this.errReturn(err, 500)
@@ -7,6 +7,7 @@ import (
 	"ufleet-deploy/pkg/log"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/replicationcontroller"
+	"ufleet-deploy/pkg/resource/workload"
 	"ufleet-deploy/pkg/user"
 
 	corev1 "k8s.io/api/core/v1"
@@ -209,7 +210,14 @@ func (this *ReplicationControllerController) CreateReplicationController() {
 	var opt resource.CreateOption
 	opt.User = who
 
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.audit(token, "", true)
 		this.errReturn(err, 500)
@@ -251,7 +259,14 @@ func (this *ReplicationControllerController) UpdateReplicationController() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, replicationcontroller, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, replicationcontroller, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, body, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -309,6 +324,11 @@ func (this *ReplicationControllerController) ScaleReplicationController() {
 		return
 	}
 
+	err = workload.RecordChange(workload.KindReplicationController, group, workspace, replicationcontroller, this.changeOption(token))
+	if err != nil {
+		log.ErrorPrint("record change of %v fail: %v", replicationcontroller, err)
+	}
+
 	this.audit(token, replicationcontroller, false)
 	this.normalReturn("ok")
 
@@ -553,7 +573,7 @@ func (this *ReplicationControllerController) AddReplicationControllerContainerSp
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -643,7 +663,7 @@ func (this *ReplicationControllerController) DeleteReplicationControllerContaine
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -734,7 +754,7 @@ func (this *ReplicationControllerController) UpdateReplicationControllerContaine
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -856,7 +876,7 @@ func (this *ReplicationControllerController) AddReplicationControllerVolume() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -923,7 +943,7 @@ func (this *ReplicationControllerController) DeleteReplicationControllerVolume()
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, replicationcontroller, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, replicationcontroller, true)
 		this.errReturn(err, 500)
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/search"
+)
+
+type SearchController struct {
+	baseController
+}
+
+type SearchOption struct {
+	Query  string   `json:"query"`  //查询语句,如 "image:nginx* kind:Deployment"
+	Groups []string `json:"groups"` //只在这些组中查找,为空表示调用者有权限的所有组
+}
+
+// Search
+// @Title Search
+// @Description   跨组搜索资源
+// @Param Token header string true 'Token'
+// @Param body body string true "查询条件"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /query [Post]
+func (this *SearchController) Search() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit search query")
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt SearchOption
+	err := json.Unmarshal(this.Ctx.Input.RequestBody, &opt)
+	if err != nil {
+		err = fmt.Errorf("try to unmarshal data \"%v\" fail for %v", string(this.Ctx.Input.RequestBody), err)
+		this.errReturn(err, 500)
+		return
+	}
+
+	es, err := search.Search(opt.Query, workspaceVisibility(token, opt.Groups))
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(es)
+}
+
+//可见的工作区由调用者的token决定,groups只能缩小范围
+//同一次搜索中每个工作区只检查一次权限
+func workspaceVisibility(token string, groups []string) func(group, workspace string) bool {
+	narrowed := make(map[string]bool)
+	for _, v := range groups {
+		narrowed[v] = true
+	}
+	checked := make(map[string]bool)
+	return func(group, workspace string) bool {
+		if len(narrowed) != 0 && !narrowed[group] {
+			return false
+		}
+		key := group + "/" + workspace
+		ok, found := checked[key]
+		if !found {
+			ok = cluster.CheckWorkspacePermission(group, workspace, token) == nil
+			checked[key] = ok
+		}
+		return ok
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"ufleet-deploy/models"
+	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/secret"
 	"ufleet-deploy/pkg/user"
@@ -197,13 +199,10 @@ type SecretCustomOption struct {
 	Data           string `json:"data,omitempty"`
 	Registry       string `json:"registry,omitempty"`
 	ServiceAccount string `json:"serviceaccount"`
-}
 
-type DockerRegistryAccount struct {
-	User     string `json:"username"`
-	Password string `json:"password"`
-	Email    string `json:"email'`
-	Auth     string `json:"auth"`
+	TLS       *pk.TLSOption       `json:"tls,omitempty"`
+	BasicAuth *pk.BasicAuthOption `json:"basicauth,omitempty"`
+	SSHAuth   *pk.SSHAuthOption   `json:"sshauth,omitempty"`
 }
 
 // CreateSecretCustom
@@ -250,6 +249,36 @@ func (this *SecretController) CreateSecretCustom() {
 		return
 	}
 
+	cm, err := buildSecretCustom(ui, group, co)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	bytedata, err := json.Marshal(cm)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.CreateOption
+	opt.Comment = co.Comment
+	opt.User = who
+	err = pk.Controller.CreateObject(group, workspace, bytedata, opt)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, "", false)
+	this.normalReturn("ok")
+}
+
+//根据类型构建Secret,dockercfg类型未提供数据时使用组的镜像仓库账号
+func buildSecretCustom(ui user.UserInterface, group string, co SecretCustomOption) (*corev1.Secret, error) {
 	cm := corev1.Secret{}
 	cm.Name = co.Name
 	cm.Kind = "Secret"
@@ -261,33 +290,17 @@ func (this *SecretController) CreateSecretCustom() {
 		if co.Data == "" {
 			reg, err := ui.GetRegistry(group, co.Registry)
 			if err != nil {
-				this.audit(token, "", true)
-				this.errReturn(err, 500)
-				return
+				return nil, err
 			}
 
-			account := make(map[string]DockerRegistryAccount)
-			ra := DockerRegistryAccount{
-				User:     reg.User,
-				Password: reg.Password,
-				Email:    reg.Email,
-			}
-
-			originAuthString := reg.User + ":" + reg.Password
-			ra.Auth = base64.StdEncoding.EncodeToString([]byte(originAuthString))
-
-			account[reg.Address] = ra
-
-			dockercfg, err := json.Marshal(account)
+			dockercfg, err := pk.NewDockercfgData(reg.Address, reg.User, reg.Password, reg.Email)
 			if err != nil {
-				this.audit(token, "", true)
-				this.errReturn(err, 500)
-				return
-
+				return nil, err
 			}
 
 			cm.Data = make(map[string][]byte)
-			cm.Data[corev1.DockerConfigKey] = []byte(dockercfg)
+			cm.Data[corev1.DockerConfigKey] = dockercfg
+			pk.SetRegistry(&cm, reg.ID, reg.Name)
 
 		} else {
 			/*
@@ -304,9 +317,7 @@ func (this *SecretController) CreateSecretCustom() {
 			data := make(map[string]string)
 			err := yaml.Unmarshal([]byte(co.Data), &data)
 			if err != nil {
-				this.audit(token, "", true)
-				this.errReturn(err, 500)
-				return
+				return nil, err
 			}
 
 			cm.Data = make(map[string][]byte)
@@ -318,9 +329,7 @@ func (this *SecretController) CreateSecretCustom() {
 
 				d, err := base64.StdEncoding.DecodeString(v)
 				if err != nil {
-					this.audit(token, "", true)
-					this.errReturn(err, 500)
-					return
+					return nil, err
 				}
 
 				cm.Data[k] = d
@@ -328,12 +337,28 @@ func (this *SecretController) CreateSecretCustom() {
 
 		}
 
+	case corev1.SecretTypeTLS:
+		if co.TLS == nil {
+			return nil, fmt.Errorf("secret type '%v' must offer tls data", co.Type)
+		}
+		return pk.NewTLSSecret(co.Name, *co.TLS)
+
+	case corev1.SecretTypeBasicAuth:
+		if co.BasicAuth == nil {
+			return nil, fmt.Errorf("secret type '%v' must offer basicauth data", co.Type)
+		}
+		return pk.NewBasicAuthSecret(co.Name, *co.BasicAuth)
+
+	case corev1.SecretTypeSSHAuth:
+		if co.SSHAuth == nil {
+			return nil, fmt.Errorf("secret type '%v' must offer sshauth data", co.Type)
+		}
+		return pk.NewSSHAuthSecret(co.Name, *co.SSHAuth)
+
 	case corev1.SecretTypeServiceAccountToken:
 		if strings.TrimSpace(co.ServiceAccount) == "" {
 			err := fmt.Errorf("secret type '%v' must offer service account name", co.Type)
-			this.audit(token, "", true)
-			this.errReturn(err, 500)
-			return
+			return nil, err
 
 		}
 		cm.Annotations = make(map[string]string)
@@ -343,44 +368,274 @@ func (this *SecretController) CreateSecretCustom() {
 	default:
 		if strings.TrimSpace(co.Data) == "" {
 			err := fmt.Errorf("must offer secret data")
-			this.audit(token, "", true)
-			this.errReturn(err, 500)
-			return
+			return nil, err
 		}
 
 		data := make(map[string]string)
 		err := yaml.Unmarshal([]byte(co.Data), &data)
 		if err != nil {
-			this.audit(token, "", true)
-			this.errReturn(err, 500)
-			return
+			return nil, err
 		}
 
 		cm.Type = corev1.SecretType(co.Type)
 		cm.StringData = data
 	}
+	return &cm, nil
+}
+
+//使用构建的Secret更新,保留原有的标签和注解
+//merge为true时保留未提供的key
+func (this *SecretController) updateSecretBuilt(group, workspace, secret string, built *corev1.Secret, merge bool, opt resource.UpdateOption) error {
+	v, err := pk.Controller.GetObject(group, workspace, secret)
+	if err != nil {
+		return err
+	}
+	pi, _ := pk.GetSecretInterface(v)
+	runtime, err := pi.GetRuntime()
+	if err != nil {
+		return err
+	}
+
+	if built.Type != runtime.Type {
+		return fmt.Errorf("secret type can't be changed from '%v' to '%v'", runtime.Type, built.Type)
+	}
+
+	built.Labels = runtime.Labels
+	annotations := runtime.Annotations
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	for k, v := range built.Annotations {
+		annotations[k] = v
+	}
+	built.Annotations = annotations
+
+	if merge {
+		for k, v := range runtime.Data {
+			if _, ok := built.Data[k]; !ok {
+				built.Data[k] = v
+			}
+		}
+	}
+
+	bytedata, err := json.Marshal(built)
+	if err != nil {
+		return err
+	}
+	return pk.Controller.UpdateObject(group, workspace, secret, bytedata, opt)
+}
+
+// UpdateSecretCustom
+// @Title Secret
+// @Description  按类型更新私秘凭据,类型不能改变
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param secret path string true "私秘凭据"
+// @Param body body string true "资源描述"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:secret/group/:group/workspace/:workspace/custom [Put]
+func (this *SecretController) UpdateSecretCustom() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	secret := this.Ctx.Input.Param(":secret")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit resource json/yaml data")
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var co SecretCustomOption
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &co)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+	co.Name = secret
+
+	cm, err := buildSecretCustom(ui, group, co)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.Comment = co.Comment
+	opt.User = who
+	err = this.updateSecretBuilt(group, workspace, secret, cm, false, opt)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, secret, false)
+
+	rs, err := pk.Reload(group, workspace, secret, opt)
+	if err != nil {
+		err = fmt.Errorf("secret has updated, but reload fail for %v", err)
+		this.errReturn(err, 500)
+		return
+	}
+	this.normalReturn(rs)
+}
+
+// CreateSecretFromFiles
+// @Title Secret
+// @Description  上传多个文件创建Opaque类型的私秘凭据,文件名作为key
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param name formData string true "私秘凭据"
+// @Param comment formData string false "注释"
+// @Param files formData file true "文件"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/workspace/:workspace/files [Post]
+func (this *SecretController) CreateSecretFromFiles() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	name := this.GetString("name")
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, name, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	files, err := this.readUploadFiles("files")
+	if err != nil {
+		this.audit(token, name, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	cm, err := pk.NewOpaqueSecretFromFiles(name, files)
+	if err != nil {
+		this.audit(token, name, true)
+		this.errReturn(err, 500)
+		return
+	}
 
 	bytedata, err := json.Marshal(cm)
 	if err != nil {
-		this.audit(token, "", true)
+		this.audit(token, name, true)
 		this.errReturn(err, 500)
 		return
 	}
 
 	var opt resource.CreateOption
-	opt.Comment = co.Comment
+	opt.Comment = this.GetString("comment")
 	opt.User = who
 	err = pk.Controller.CreateObject(group, workspace, bytedata, opt)
 	if err != nil {
-		this.audit(token, "", true)
+		this.audit(token, name, true)
 		this.errReturn(err, 500)
 		return
 	}
 
-	this.audit(token, "", false)
+	this.audit(token, name, false)
 	this.normalReturn("ok")
 }
 
+// UpdateSecretFromFiles
+// @Title Secret
+// @Description  上传多个文件更新Opaque类型的私秘凭据,文件名作为key
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param secret path string true "私秘凭据"
+// @Param comment formData string false "注释"
+// @Param merge formData bool false "是否保留未上传的key"
+// @Param files formData file true "文件"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:secret/group/:group/workspace/:workspace/files [Put]
+func (this *SecretController) UpdateSecretFromFiles() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	secret := this.Ctx.Input.Param(":secret")
+	merge, _ := this.GetBool("merge")
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	files, err := this.readUploadFiles("files")
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	cm, err := pk.NewOpaqueSecretFromFiles(secret, files)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.Comment = this.GetString("comment")
+	opt.User = who
+	err = this.updateSecretBuilt(group, workspace, secret, cm, merge, opt)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, secret, false)
+
+	rs, err := pk.Reload(group, workspace, secret, opt)
+	if err != nil {
+		err = fmt.Errorf("secret has updated, but reload fail for %v", err)
+		this.errReturn(err, 500)
+		return
+	}
+	this.normalReturn(rs)
+}
+
 // UpdateSecret
 // @Title Secret
 // @Description  更新secret
@@ -412,7 +667,18 @@ func (this *SecretController) UpdateSecret() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, secret, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.User = who
+
+	err = pk.Controller.UpdateObject(group, workspace, secret, this.Ctx.Input.RequestBody, opt)
 	if err != nil {
 		this.errReturn(err, 500)
 		this.audit(token, secret, true)
@@ -420,7 +686,14 @@ func (this *SecretController) UpdateSecret() {
 	}
 
 	this.audit(token, secret, false)
-	this.normalReturn("ok")
+
+	rs, err := pk.Reload(group, workspace, secret, opt)
+	if err != nil {
+		err = fmt.Errorf("secret has updated, but reload fail for %v", err)
+		this.errReturn(err, 500)
+		return
+	}
+	this.normalReturn(rs)
 }
 
 // DeleteSecret
@@ -463,6 +736,7 @@ func (this *SecretController) DeleteSecret() {
 // @Param group path string true "组名"
 // @Param workspace path string true "工作区"
 // @Param secret path string true "私秘凭据"
+// @Param redact query bool false "是否隐藏数据"
 // @Success 201 {string} create success!
 // @Failure 500
 // @router /:secret/group/:group/workspace/:workspace/template [Get]
@@ -484,7 +758,13 @@ func (this *SecretController) GetSecretTemplate() {
 	}
 	pi, _ := pk.GetSecretInterface(v)
 
-	t, err := pi.GetTemplate()
+	redact, _ := this.GetBool("redact")
+	var t string
+	if redact {
+		t, err = pi.GetRedactedTemplate()
+	} else {
+		t, err = pi.GetTemplate()
+	}
 	if err != nil {
 		this.errReturn(err, 500)
 		return
@@ -564,3 +844,206 @@ func (this *SecretController) GetSecretReferenceObject() {
 
 	this.normalReturn(es)
 }
+
+// ListSecretHistory
+// @Title Secret
+// @Description   Secret的历史版本,数据被隐藏
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param secret path string true "私秘凭据"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:secret/group/:group/workspace/:workspace/history [Get]
+func (this *SecretController) ListSecretHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	secret := this.Ctx.Input.Param(":secret")
+
+	rs, err := pk.ListHistory(group, workspace, secret)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(rs)
+}
+
+// DiffSecretHistory
+// @Title Secret
+// @Description   比较Secret的两个历史版本,to为空时与当前数据比较
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param secret path string true "私秘凭据"
+// @Param from query string true "起始版本"
+// @Param to query string false "目标版本"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:secret/group/:group/workspace/:workspace/history/diff [Get]
+func (this *SecretController) DiffSecretHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	secret := this.Ctx.Input.Param(":secret")
+
+	from, err := strconv.ParseInt(this.GetString("from"), 10, 64)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	var to int64
+	if toStr := this.GetString("to"); toStr != "" {
+		to, err = strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			this.errReturn(err, 500)
+			return
+		}
+	}
+
+	cs, err := pk.DiffRevision(group, workspace, secret, from, to)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(cs)
+}
+
+// RestoreSecretHistory
+// @Title Secret
+// @Description   将Secret恢复到指定的历史版本
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param secret path string true "私秘凭据"
+// @Param version path string true "版本"
+// @Param comment query string false "注释"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:secret/group/:group/workspace/:workspace/history/:version/restore [Put]
+func (this *SecretController) RestoreSecretHistory() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+	workspace := this.Ctx.Input.Param(":workspace")
+	secret := this.Ctx.Input.Param(":secret")
+
+	version, err := strconv.ParseInt(this.Ctx.Input.Param(":version"), 10, 64)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	ui := user.NewUserClient(token)
+	who, err := ui.GetUserName()
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt resource.UpdateOption
+	opt.User = who
+	opt.Comment = this.GetString("comment")
+
+	rs, err := pk.Restore(group, workspace, secret, version, opt)
+	if err != nil {
+		this.audit(token, secret, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, secret, false)
+	this.normalReturn(rs)
+}
+
+// GetPullSecretPolicy
+// @Title Secret
+// @Description  获取组的镜像拉取凭据注入策略
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/pullsecretpolicy [Get]
+func (this *SecretController) GetPullSecretPolicy() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+
+	pp, err := backend.GetPullSecretPolicy(group)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(pp)
+}
+
+// UpdatePullSecretPolicy
+// @Title Secret
+// @Description  更新组的镜像拉取凭据注入策略,开启后创建/更新工作负载时为使用组镜像仓库的镜像自动添加imagePullSecrets
+// @Param Token header string true 'Token'
+// @Param group path string true "组名"
+// @Param body body string true "注入策略"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /group/:group/pullsecretpolicy [Put]
+func (this *SecretController) UpdatePullSecretPolicy() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	group := this.Ctx.Input.Param(":group")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit pull secret policy")
+		this.audit(token, group, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var pp backend.PullSecretPolicy
+	err := json.Unmarshal(this.Ctx.Input.RequestBody, &pp)
+	if err != nil {
+		this.audit(token, group, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	pp.Group = group
+	err = backend.SetPullSecretPolicy(pp)
+	if err != nil {
+		this.audit(token, group, true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, group, false)
+	this.normalReturn("ok")
+}
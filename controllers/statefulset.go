@@ -200,7 +200,14 @@ func (this *StatefulSetController) CreateStatefulSet() {
 
 	var opt resource.CreateOption
 	opt.User = who
-	err = pk.Controller.CreateObject(group, workspace, this.Ctx.Input.RequestBody, opt)
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.CreateObject(group, workspace, body, opt)
 	if err != nil {
 		this.errReturn(err, 500)
 		this.audit(token, "", true)
@@ -229,7 +236,7 @@ func (this *StatefulSetController) UpdateStatefulSet() {
 		return
 	}
 
-	//token := this.Ctx.Request.Header.Get("token")
+	token := this.Ctx.Request.Header.Get("token")
 	group := this.Ctx.Input.Param(":group")
 	workspace := this.Ctx.Input.Param(":workspace")
 	statefulset := this.Ctx.Input.Param(":statefulset")
@@ -240,7 +247,13 @@ func (this *StatefulSetController) UpdateStatefulSet() {
 		return
 	}
 
-	err := pk.Controller.UpdateObject(group, workspace, statefulset, this.Ctx.Input.RequestBody, resource.UpdateOption{})
+	body, err := injectPullSecrets(token, group, workspace, this.Ctx.Input.RequestBody)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = pk.Controller.UpdateObject(group, workspace, statefulset, body, this.changeOption(token))
 	if err != nil {
 		this.errReturn(err, 500)
 		return
@@ -328,7 +341,7 @@ func (this *StatefulSetController) UpdateStatefulSetCustom() {
 		return
 	}
 
-	err = pk.Controller.UpdateObject(group, workspace, statefulset, byteContent, resource.UpdateOption{})
+	err = pk.Controller.UpdateObject(group, workspace, statefulset, byteContent, this.changeOption(token))
 	if err != nil {
 		this.audit(token, statefulset, true)
 		this.errReturn(err, 500)
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/log"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+const (
+	terminalPingPeriod   = 30 * time.Second
+	terminalWriteTimeout = 10 * time.Second
+
+	terminalOpStdin  = "stdin"
+	terminalOpResize = "resize"
+	terminalOpExit   = "exit"
+)
+
+var (
+	//可以选择的shell,不指定时优先使用bash
+	terminalShells = map[string]bool{
+		"sh":   true,
+		"bash": true,
+		"ash":  true,
+		"dash": true,
+		"zsh":  true,
+		"ksh":  true,
+	}
+	terminalDefaultCommand = []string{"/bin/sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
+	terminalUpgrader = websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		//通过token认证,允许跨域
+		CheckOrigin: func(r *http.Request) bool { return true },
+	}
+)
+
+func terminalCommand(shell string) ([]string, error) {
+	if shell == "" {
+		return terminalDefaultCommand, nil
+	}
+	if !terminalShells[shell] {
+		return nil, fmt.Errorf("unsupported shell '%v'", shell)
+	}
+	return []string{shell}, nil
+}
+
+//客户端发送的消息
+//{"op":"stdin","data":"ls\r"}
+//{"op":"resize","rows":24,"cols":80}
+//服务端以二进制消息发送终端的输出,终端退出时发送{"op":"exit","data":"原因"}
+type terminalMessage struct {
+	Op   string `json:"op"`
+	Data string `json:"data,omitempty"`
+	Rows uint16 `json:"rows,omitempty"`
+	Cols uint16 `json:"cols,omitempty"`
+}
+
+//连接WebSocket和容器的终端,作为终端的stdin/stdout以及终端大小的来源
+type terminalSession struct {
+	conn     *websocket.Conn
+	sizeChan chan remotecommand.TerminalSize
+	done     chan struct{}
+	once     sync.Once
+	wlock    sync.Mutex
+	pending  []byte
+}
+
+func newTerminalSession(conn *websocket.Conn) *terminalSession {
+	ts := terminalSession{
+		conn:     conn,
+		sizeChan: make(chan remotecommand.TerminalSize, 1),
+		done:     make(chan struct{}),
+	}
+	go ts.keepalive()
+	return &ts
+}
+
+func (ts *terminalSession) keepalive() {
+	ticker := time.NewTicker(terminalPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := ts.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(terminalWriteTimeout))
+			if err != nil {
+				return
+			}
+		case <-ts.done:
+			return
+		}
+	}
+}
+
+//只保留最新的终端大小
+func (ts *terminalSession) resize(rows, cols uint16) {
+	size := remotecommand.TerminalSize{Height: rows, Width: cols}
+	for {
+		select {
+		case ts.sizeChan <- size:
+			return
+		default:
+		}
+		select {
+		case <-ts.sizeChan:
+		default:
+		}
+	}
+}
+
+//remotecommand.TerminalSizeQueue,返回nil时停止同步终端大小
+func (ts *terminalSession) Next() *remotecommand.TerminalSize {
+	select {
+	case size := <-ts.sizeChan:
+		return &size
+	case <-ts.done:
+		return nil
+	}
+}
+
+//终端的stdin,连接断开时返回EOF使终端退出
+func (ts *terminalSession) Read(p []byte) (int, error) {
+	for len(ts.pending) == 0 {
+		_, data, err := ts.conn.ReadMessage()
+		if err != nil {
+			return 0, io.EOF
+		}
+		var msg terminalMessage
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			log.DebugPrint("ignore invalid terminal message: %v", err)
+			continue
+		}
+		switch msg.Op {
+		case terminalOpStdin:
+			ts.pending = []byte(msg.Data)
+		case terminalOpResize:
+			if msg.Rows > 0 && msg.Cols > 0 {
+				ts.resize(msg.Rows, msg.Cols)
+			}
+		default:
+			log.DebugPrint("ignore unknown terminal message op '%v'", msg.Op)
+		}
+	}
+	n := copy(p, ts.pending)
+	ts.pending = ts.pending[n:]
+	return n, nil
+}
+
+//终端的stdout
+func (ts *terminalSession) Write(p []byte) (int, error) {
+	ts.wlock.Lock()
+	defer ts.wlock.Unlock()
+	ts.conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+	err := ts.conn.WriteMessage(websocket.BinaryMessage, p)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+//通知客户端终端已退出并关闭连接
+func (ts *terminalSession) Close(reason error) {
+	ts.once.Do(func() {
+		close(ts.done)
+
+		msg := terminalMessage{Op: terminalOpExit}
+		if reason != nil {
+			msg.Data = reason.Error()
+		}
+		ts.wlock.Lock()
+		ts.conn.SetWriteDeadline(time.Now().Add(terminalWriteTimeout))
+		ts.conn.WriteJSON(msg)
+		ts.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+		ts.wlock.Unlock()
+		ts.conn.Close()
+	})
+}
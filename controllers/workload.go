@@ -0,0 +1,1431 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"ufleet-deploy/pkg/autorollback"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/metrics"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/pkg/schedule"
+	"ufleet-deploy/pkg/usage"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+//对所有带Pod模板的资源(Deployment,DaemonSet,StatefulSet,ReplicaSet,
+//ReplicationController,Job,CronJob)提供统一的Pod模板编辑接口
+type WorkloadController struct {
+	baseController
+}
+
+type workloadParam struct {
+	kind      string
+	name      string
+	group     string
+	workspace string
+}
+
+func (this *WorkloadController) getWorkloadParam() (*workloadParam, error) {
+	kind, err := workload.ParseKind(this.Ctx.Input.Param(":kind"))
+	if err != nil {
+		return nil, err
+	}
+
+	var wp workloadParam
+	wp.kind = kind
+	wp.name = this.Ctx.Input.Param(":name")
+	wp.group = this.Ctx.Input.Param(":group")
+	wp.workspace = this.Ctx.Input.Param(":workspace")
+	return &wp, nil
+}
+
+func (wp *workloadParam) objectName() string {
+	return fmt.Sprintf("%v/%v", wp.kind, wp.name)
+}
+
+func (wp *workloadParam) getPodSpec() (*corev1.PodSpec, error) {
+	tpl, err := workload.GetPodTemplate(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		return nil, err
+	}
+	return &tpl.Spec, nil
+}
+
+func (wp *workloadParam) updatePodSpec(opt resource.UpdateOption, fn func(podSpec corev1.PodSpec) (corev1.PodSpec, error)) error {
+	return workload.UpdatePodTemplate(wp.kind, wp.group, wp.workspace, wp.name, func(tpl *corev1.PodTemplateSpec) error {
+		newPodSpec, err := fn(tpl.Spec)
+		if err != nil {
+			return err
+		}
+		tpl.Spec = newPodSpec
+		return nil
+	}, opt)
+}
+
+// GetWorkloadContainerProbe
+// @Title Workload
+// @Description   获取容器的存活/就绪探针
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "容器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/container/:container/probe [Get]
+func (this *WorkloadController) GetWorkloadContainerProbe() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	cp, err := getPodSpecContainerProbe(*podSpec, container)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(cp)
+}
+
+// UpdateWorkloadContainerProbe
+// @Title Workload
+// @Description   更新容器的存活/就绪探针,探针为空表示删除
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "容器"
+// @Param body body string true "探针"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/container/:container/probe [Put]
+func (this *WorkloadController) UpdateWorkloadContainerProbe() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit probe")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var probe ContainerProbe
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &probe)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		return updatePodSpecContainerProbe(podSpec, container, probe)
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadContainerResources
+// @Title Workload
+// @Description   获取容器的资源请求/限制
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "容器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/container/:container/resources [Get]
+func (this *WorkloadController) GetWorkloadContainerResources() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	k, err := getPodSpecContainerIndex(*podSpec, container)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(podSpec.Containers[k].Resources)
+}
+
+// UpdateWorkloadContainerResources
+// @Title Workload
+// @Description   更新容器的资源请求/限制
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "容器"
+// @Param body body string true "资源请求/限制"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/container/:container/resources [Put]
+func (this *WorkloadController) UpdateWorkloadContainerResources() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit resources")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var res corev1.ResourceRequirements
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &res)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		return updatePodSpecContainerResources(podSpec, container, res)
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadInitContainers
+// @Title Workload
+// @Description   获取初始化容器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/initcontainers [Get]
+func (this *WorkloadController) GetWorkloadInitContainers() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ics := make([]corev1.Container, 0)
+	ics = append(ics, podSpec.InitContainers...)
+	this.normalReturn(ics)
+}
+
+// AddWorkloadInitContainer
+// @Title Workload
+// @Description   添加初始化容器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "初始化容器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/initcontainers [Post]
+func (this *WorkloadController) AddWorkloadInitContainer() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit init container")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var c corev1.Container
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &c)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		return addPodSpecInitContainer(podSpec, c)
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// UpdateWorkloadInitContainer
+// @Title Workload
+// @Description   更新初始化容器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "初始化容器"
+// @Param body body string true "初始化容器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/initcontainer/:container [Put]
+func (this *WorkloadController) UpdateWorkloadInitContainer() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit init container")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var c corev1.Container
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &c)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		return updatePodSpecInitContainer(podSpec, container, c)
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// DeleteWorkloadInitContainer
+// @Title Workload
+// @Description   删除初始化容器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container path string true "初始化容器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/initcontainer/:container [Delete]
+func (this *WorkloadController) DeleteWorkloadInitContainer() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+	container := this.Ctx.Input.Param(":container")
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		return deletePodSpecInitContainer(podSpec, container)
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadNodeSelector
+// @Title Workload
+// @Description   获取节点选择器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/nodeselector [Get]
+func (this *WorkloadController) GetWorkloadNodeSelector() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ns := make(map[string]string)
+	for k, v := range podSpec.NodeSelector {
+		ns[k] = v
+	}
+	this.normalReturn(ns)
+}
+
+// UpdateWorkloadNodeSelector
+// @Title Workload
+// @Description   更新节点选择器
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "节点选择器"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/nodeselector [Put]
+func (this *WorkloadController) UpdateWorkloadNodeSelector() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit node selector")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	ns := make(map[string]string)
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &ns)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		podSpec.NodeSelector = ns
+		return podSpec, nil
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadTolerations
+// @Title Workload
+// @Description   获取容忍
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/tolerations [Get]
+func (this *WorkloadController) GetWorkloadTolerations() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ts := make([]corev1.Toleration, 0)
+	ts = append(ts, podSpec.Tolerations...)
+	this.normalReturn(ts)
+}
+
+// UpdateWorkloadTolerations
+// @Title Workload
+// @Description   更新容忍
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "容忍"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/tolerations [Put]
+func (this *WorkloadController) UpdateWorkloadTolerations() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit tolerations")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	ts := make([]corev1.Toleration, 0)
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &ts)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		podSpec.Tolerations = ts
+		return podSpec, nil
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadAffinity
+// @Title Workload
+// @Description   获取亲和性
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/affinity [Get]
+func (this *WorkloadController) GetWorkloadAffinity() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	podSpec, err := wp.getPodSpec()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	affinity := podSpec.Affinity
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	}
+	this.normalReturn(affinity)
+}
+
+// UpdateWorkloadAffinity
+// @Title Workload
+// @Description   更新亲和性
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "亲和性"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/affinity [Put]
+func (this *WorkloadController) UpdateWorkloadAffinity() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.audit(token, "", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit affinity")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var affinity corev1.Affinity
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &affinity)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = wp.updatePodSpec(this.changeOption(token), func(podSpec corev1.PodSpec) (corev1.PodSpec, error) {
+		podSpec.Affinity = &affinity
+		if affinity.NodeAffinity == nil && affinity.PodAffinity == nil && affinity.PodAntiAffinity == nil {
+			podSpec.Affinity = nil
+		}
+		return podSpec, nil
+	})
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadLogs
+// @Title Workload
+// @Description   获取工作负载所有Pod合并后的日志,每行以[pod/container]开头,按时间排序,follow时持续输出
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container query string false "容器,默认所有容器"
+// @Param grep query string false "只保留匹配该正则表达式的行"
+// @Param follow query bool false "是否持续输出"
+// @Param tailLines query int false "每个容器显示的行数"
+// @Param sinceSeconds query int false "显示多少秒以来的日志"
+// @Param sinceTime query string false "显示该时间(RFC3339)以来的日志"
+// @Param timestamps query bool false "是否显示时间戳"
+// @Param previous query bool false "是否显示上一个容器的日志"
+// @Param limitBytes query int false "每个容器最多返回的字节数"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/logs [Get]
+func (this *WorkloadController) GetWorkloadLogs() {
+	this.workloadLogs(false)
+}
+
+// DownloadWorkloadLogs
+// @Title Workload
+// @Description   下载工作负载所有Pod的日志,zip包中每个容器一个文件
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param container query string false "容器,默认所有容器"
+// @Param grep query string false "只保留匹配该正则表达式的行"
+// @Param tailLines query int false "每个容器的行数"
+// @Param sinceSeconds query int false "多少秒以来的日志"
+// @Param sinceTime query string false "该时间(RFC3339)以来的日志"
+// @Param timestamps query bool false "是否带时间戳"
+// @Param previous query bool false "是否为上一个容器的日志"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/logs/download [Get]
+func (this *WorkloadController) DownloadWorkloadLogs() {
+	this.workloadLogs(true)
+}
+
+func (this *WorkloadController) workloadLogs(download bool) {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	pods, err := workload.GetPods(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.aggregatedLogReturn(wp.group, wp.workspace, wp.name, pods, download)
+}
+
+// GetWorkloadStats
+// @Title Workload
+// @Description   工作负载所有运行中Pod的资源使用时间序列,total为各Pod之和
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param step query int false "对齐的间隔(秒),默认15"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/stat [Get]
+func (this *WorkloadController) GetWorkloadStats() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	step, err := this.getMetricsStep()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ws, err := metrics.WorkloadSeriesOf(wp.kind, wp.group, wp.workspace, wp.name, step)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ws)
+}
+
+// GetWorkloadUsage
+// @Title Workload
+// @Description   工作负载的资源使用历史,为各Pod之和
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param start query string false "开始时间(RFC3339),默认结束时间前一小时"
+// @Param end query string false "结束时间(RFC3339),默认当前时间"
+// @Param step query int false "聚合的间隔(秒),默认为存储精度"
+// @Param agg query string false "聚合方式,avg/max/min,默认avg"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/usage [Get]
+func (this *WorkloadController) GetWorkloadUsage() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	opt, err := this.getUsageQueryOption()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	key := usage.SeriesKey(wp.kind, wp.group, wp.workspace, wp.name)
+	us, err := usage.Query(wp.name, []string{key}, *opt)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(us)
+}
+
+// GetUsageRetention
+// @Title Workload
+// @Description   获取资源使用历史的保留时间(小时)
+// @Param Token header string true 'Token'
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /usage/retention [Get]
+func (this *WorkloadController) GetUsageRetention() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	ur, err := backend.GetUsageRetention()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ur)
+}
+
+// UpdateUsageRetention
+// @Title Workload
+// @Description   更新资源使用历史的保留时间(小时),精度越粗保留时间需越长
+// @Param Token header string true 'Token'
+// @Param body body string true "保留时间"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /usage/retention [Put]
+func (this *WorkloadController) UpdateUsageRetention() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit usage retention")
+		this.audit(token, "usage retention", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var ur backend.UsageRetention
+	err := json.Unmarshal(this.Ctx.Input.RequestBody, &ur)
+	if err != nil {
+		this.audit(token, "usage retention", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = backend.SetUsageRetention(ur)
+	if err != nil {
+		this.audit(token, "usage retention", true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, "usage retention", false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadScaleSchedule
+// @Title Workload
+// @Description   获取工作负载的定时伸缩策略,只支持Deployment,StatefulSet,ReplicaSet,ReplicationController
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/scaleschedule [Get]
+func (this *WorkloadController) GetWorkloadScaleSchedule() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	ss, err := schedule.Get(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(ss)
+}
+
+// UpdateWorkloadScaleSchedule
+// @Title Workload
+// @Description   设置工作负载的定时伸缩策略,规则为cron表达式(分 时 日 月 周)和副本数;Deployment开启弹性伸缩时,副本数限制在弹性伸缩的范围内,规则中的minreplicas/maxreplicas会修改该范围
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "定时伸缩策略"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/scaleschedule [Put]
+func (this *WorkloadController) UpdateWorkloadScaleSchedule() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit scale schedule")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var ss backend.ScaleSchedule
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &ss)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+	ss.Kind = wp.kind
+	ss.Workspace = wp.workspace
+	ss.Name = wp.name
+
+	_, err = workload.GetReplicas(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = schedule.Set(wp.group, ss)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// DeleteWorkloadScaleSchedule
+// @Title Workload
+// @Description   删除工作负载的定时伸缩策略
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/scaleschedule [Delete]
+func (this *WorkloadController) DeleteWorkloadScaleSchedule() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = schedule.Delete(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadScaleScheduleHistory
+// @Title Workload
+// @Description   获取工作负载定时伸缩的执行记录,最近的在前
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/scaleschedule/history [Get]
+func (this *WorkloadController) GetWorkloadScaleScheduleHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	es, err := schedule.History(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(es)
+}
+
+// GetWorkloadRolloutStatus
+// @Title Workload
+// @Description   获取Deployment,DaemonSet,StatefulSet的滚动升级状态,wait=true时等待升级完成,失败或者超时
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param wait query bool false "是否等待升级结束"
+// @Param timeout query int false "等待超时,秒,默认300"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/rollout/status [Get]
+func (this *WorkloadController) GetWorkloadRolloutStatus() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	wait, err := this.GetBool("wait", false)
+	if err != nil {
+		this.errReturn(fmt.Errorf("invalid wait '%v'", this.GetString("wait")), 500)
+		return
+	}
+	timeout, err := this.GetInt64("timeout", workload.DefaultRolloutTimeout)
+	if err != nil || timeout <= 0 {
+		this.errReturn(fmt.Errorf("invalid timeout '%v'", this.GetString("timeout")), 500)
+		return
+	}
+
+	var s *workload.RolloutStatus
+	if wait {
+		s, err = workload.WaitRollout(wp.kind, wp.group, wp.workspace, wp.name, time.Duration(timeout)*time.Second)
+	} else {
+		s, err = workload.GetRolloutStatus(wp.kind, wp.group, wp.workspace, wp.name)
+	}
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(s)
+}
+
+//from必须指定,to为空时与集群中当前的Pod模板比较
+func (this *baseController) getRevisionDiffParam() (int64, int64, error) {
+	from, err := this.GetInt64("from", 0)
+	if err != nil || from <= 0 {
+		return 0, 0, fmt.Errorf("invalid from revision '%v'", this.GetString("from"))
+	}
+	to, err := this.GetInt64("to", 0)
+	if err != nil || to < 0 {
+		return 0, 0, fmt.Errorf("invalid to revision '%v'", this.GetString("to"))
+	}
+	return from, to, nil
+}
+
+func (wp *workloadParam) autoRollbackPolicy() backend.AutoRollbackPolicy {
+	return backend.AutoRollbackPolicy{Kind: wp.kind, Workspace: wp.workspace, Name: wp.name}
+}
+
+// GetWorkloadAutoRollback
+// @Title Workload
+// @Description   获取Deployment,DaemonSet的自动回滚策略
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/autorollback [Get]
+func (this *WorkloadController) GetWorkloadAutoRollback() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	p, err := autorollback.Get(wp.group, wp.autoRollbackPolicy())
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(p)
+}
+
+// UpdateWorkloadAutoRollback
+// @Title Workload
+// @Description   设置Deployment,DaemonSet的自动回滚策略,超过进度期限或者新版本CrashLoopBackOff的Pod超过maxcrashlooppods时回滚到上一个正常的版本
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param body body string true "自动回滚策略"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/autorollback [Put]
+func (this *WorkloadController) UpdateWorkloadAutoRollback() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	if this.Ctx.Input.RequestBody == nil {
+		err := fmt.Errorf("must commit auto rollback policy")
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	var p backend.AutoRollbackPolicy
+	err = json.Unmarshal(this.Ctx.Input.RequestBody, &p)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+	p.Kind = wp.kind
+	p.Workspace = wp.workspace
+	p.Name = wp.name
+	p.App = ""
+
+	_, err = workload.GetObject(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = autorollback.Set(wp.group, p)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// DeleteWorkloadAutoRollback
+// @Title Workload
+// @Description   删除工作负载的自动回滚策略,之后使用应用的策略
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/autorollback [Delete]
+func (this *WorkloadController) DeleteWorkloadAutoRollback() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	err = autorollback.Delete(wp.group, wp.autoRollbackPolicy())
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn("ok")
+}
+
+// GetWorkloadAutoRollbackHistory
+// @Title Workload
+// @Description   获取工作负载的自动回滚记录,最近的在前
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/autorollback/history [Get]
+func (this *WorkloadController) GetWorkloadAutoRollbackHistory() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	rs, err := autorollback.History(wp.group, wp.kind, wp.workspace, wp.name)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(rs)
+}
+
+// RestartWorkload
+// @Title Workload
+// @Description   滚动重启Deployment,DaemonSet,StatefulSet,ReplicaSet,ReplicationController的Pod,前三者在Pod模板中写入重启时间,后两者按maxunavailable分批删除Pod
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Param comment query string false "修改说明"
+// @Param body body string false "重启参数"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/restart [Put]
+func (this *WorkloadController) RestartWorkload() {
+	token := this.Ctx.Request.Header.Get("token")
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	var opt workload.RestartOption
+	if len(this.Ctx.Input.RequestBody) > 0 {
+		err = json.Unmarshal(this.Ctx.Input.RequestBody, &opt)
+		if err != nil {
+			this.audit(token, wp.objectName(), true)
+			this.errReturn(err, 500)
+			return
+		}
+	}
+	ropt := this.changeOption(token)
+	opt.User = ropt.User
+	opt.Operation = ropt.Operation
+	if opt.Comment == "" {
+		opt.Comment = ropt.Comment
+	}
+
+	p, err := workload.Restart(wp.kind, wp.group, wp.workspace, wp.name, opt)
+	if err != nil {
+		this.audit(token, wp.objectName(), true)
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.audit(token, wp.objectName(), false)
+	this.normalReturn(p)
+}
+
+// GetWorkloadRestart
+// @Title Workload
+// @Description   获取工作负载最近一次滚动重启的进度
+// @Param Token header string true 'Token'
+// @Param kind path string true "资源类型"
+// @Param name path string true "资源名"
+// @Param group path string true "组名"
+// @Param workspace path string true "工作区"
+// @Success 201 {string} create success!
+// @Failure 500
+// @router /:kind/:name/group/:group/workspace/:workspace/restart [Get]
+func (this *WorkloadController) GetWorkloadRestart() {
+	aerr := this.checkRouteControllerAbility()
+	if aerr != nil {
+		this.abilityErrorReturn(aerr)
+		return
+	}
+
+	wp, err := this.getWorkloadParam()
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	p, err := workload.GetRestartProgress(wp.kind, wp.group, wp.workspace, wp.name)
+	if err != nil {
+		this.errReturn(err, 500)
+		return
+	}
+
+	this.normalReturn(p)
+}
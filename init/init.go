@@ -5,10 +5,14 @@ import (
 	"os"
 	"time"
 	"ufleet-deploy/pkg/app"
+	"ufleet-deploy/pkg/autorollback"
+	"ufleet-deploy/pkg/autoscaler"
 	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/canary"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/kv"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
 	"ufleet-deploy/pkg/resource/configmap"
 	"ufleet-deploy/pkg/resource/cronjob"
 	"ufleet-deploy/pkg/resource/daemonset"
@@ -24,6 +28,9 @@ import (
 	"ufleet-deploy/pkg/resource/service"
 	"ufleet-deploy/pkg/resource/serviceaccount"
 	"ufleet-deploy/pkg/resource/statefulset"
+	"ufleet-deploy/pkg/schedule"
+	"ufleet-deploy/pkg/search"
+	"ufleet-deploy/pkg/usage"
 	"ufleet-deploy/pkg/user"
 )
 
@@ -61,6 +68,17 @@ func init() {
 	hpa.Init()
 
 	user.Init()
+	metrics.Init()
+	//需要在metrics后初始化
+	usage.Init()
+	autoscaler.Init()
+	schedule.Init()
+	autorollback.Init()
+	canary.Init()
+
+	//需要在各resource后,cluster前初始化,以便收到集群资源的创建事件
+	log.DebugPrint("init search index")
+	search.Init()
 
 	//需要在pod/service等resource后初始化
 	//因为初始化就构建k8s的对象到内存中
@@ -0,0 +1,598 @@
+package autorollback
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	uaudit "ufleet-deploy/pkg/audit"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	pkds "ufleet-deploy/pkg/resource/daemonset"
+	pkd "ufleet-deploy/pkg/resource/deployment"
+	"ufleet-deploy/pkg/resource/workload"
+
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+const (
+	intervalEnvKey  = "AUTOROLLBACK_INTERVAL"
+	defaultInterval = 30 * time.Second
+	leaderName      = "autorollback"
+
+	//同一工作负载两次自动回滚的最小间隔,按etcd中的记录计算,切换leader后仍然有效
+	rollbackCooldown = 10 * time.Minute
+
+	crashLoopBackOff = "CrashLoopBackOff"
+
+	auditOperator = "system"
+	auditOperate  = "auto rollback"
+	eventReason   = "AutoRollback"
+)
+
+var (
+	//支持回滚的工作负载
+	Kinds = []string{workload.KindDeployment, workload.KindDaemonSet}
+
+	//策略和记录按组保存,修改时加锁
+	locker sync.Mutex
+	leader *kv.Leader
+)
+
+func IsSupportedKind(kind string) bool {
+	for _, v := range Kinds {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.ErrorPrint("invalid %v '%v', use default %v", key, v, def)
+		return def
+	}
+	return d
+}
+
+//定时检查开启了自动回滚的工作负载的滚动升级进度
+//多副本部署时只有leader执行
+func Init() {
+	leader = kv.NewLeader(leaderName)
+	go loop(durationEnv(intervalEnvKey, defaultInterval))
+}
+
+func isAppPolicy(p backend.AutoRollbackPolicy) bool {
+	return p.Kind == "" && p.Name == ""
+}
+
+func samePolicy(a, b backend.AutoRollbackPolicy) bool {
+	if isAppPolicy(a) != isAppPolicy(b) || a.Workspace != b.Workspace {
+		return false
+	}
+	if isAppPolicy(a) {
+		return a.App == b.App
+	}
+	return a.Kind == b.Kind && a.Name == b.Name
+}
+
+func validate(p backend.AutoRollbackPolicy) error {
+	if isAppPolicy(p) {
+		if p.App == "" {
+			return fmt.Errorf("must specify app or workload")
+		}
+	} else if !IsSupportedKind(p.Kind) {
+		return fmt.Errorf("kind '%v' doesn't support auto rollback", p.Kind)
+	}
+	if p.MaxCrashLoopPods < 0 {
+		return fmt.Errorf("maxcrashlooppods must not be negative")
+	}
+	return nil
+}
+
+//没有设置时返回关闭的策略
+func Get(group string, p backend.AutoRollbackPolicy) (*backend.AutoRollbackPolicy, error) {
+	ps, err := backend.GetAutoRollbackPolicies(group)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range ps {
+		if samePolicy(v, p) {
+			return &v, nil
+		}
+	}
+	p.Enabled = false
+	p.MaxCrashLoopPods = 0
+	return &p, nil
+}
+
+func Set(group string, p backend.AutoRollbackPolicy) error {
+	err := validate(p)
+	if err != nil {
+		return err
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+	ps, err := backend.GetAutoRollbackPolicies(group)
+	if err != nil {
+		return err
+	}
+	found := false
+	for k, v := range ps {
+		if samePolicy(v, p) {
+			ps[k] = p
+			found = true
+			break
+		}
+	}
+	if !found {
+		ps = append(ps, p)
+	}
+	return backend.SetAutoRollbackPolicies(group, ps)
+}
+
+func Delete(group string, p backend.AutoRollbackPolicy) error {
+	locker.Lock()
+	defer locker.Unlock()
+	ps, err := backend.GetAutoRollbackPolicies(group)
+	if err != nil {
+		return err
+	}
+	result := make([]backend.AutoRollbackPolicy, 0, len(ps))
+	for _, v := range ps {
+		if !samePolicy(v, p) {
+			result = append(result, v)
+		}
+	}
+	return backend.SetAutoRollbackPolicies(group, result)
+}
+
+//工作负载的自动回滚记录,最近的在前
+func History(group, kind, workspace, name string) ([]backend.AutoRollbackRecord, error) {
+	rs, err := backend.GetAutoRollbackHistory(group)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backend.AutoRollbackRecord, 0)
+	for i := len(rs) - 1; i >= 0; i-- {
+		v := rs[i]
+		if v.Kind == kind && v.Workspace == workspace && v.Name == name {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+//应用的自动回滚记录,最近的在前
+func AppHistory(group, workspace, app string) ([]backend.AutoRollbackRecord, error) {
+	rs, err := backend.GetAutoRollbackHistory(group)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backend.AutoRollbackRecord, 0)
+	for i := len(rs) - 1; i >= 0; i-- {
+		v := rs[i]
+		if v.Workspace == workspace && v.App == app {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if !leader.IsLeader() {
+			continue
+		}
+		groups := make(map[string]bool)
+		for _, kind := range Kinds {
+			rc, err := resource.GetResourceController(kind)
+			if err != nil {
+				continue
+			}
+			for _, g := range rc.ListGroups() {
+				groups[g] = true
+			}
+		}
+		for g := range groups {
+			checkGroup(g, now)
+		}
+	}
+}
+
+type target struct {
+	kind      string
+	workspace string
+	name      string
+	policy    backend.AutoRollbackPolicy
+}
+
+func targetKey(kind, workspace, name string) string {
+	return kind + "/" + workspace + "/" + name
+}
+
+//工作负载自身的策略优先,关闭的策略也会覆盖应用的策略
+func targets(group string, ps []backend.AutoRollbackPolicy) []target {
+	ts := make(map[string]target)
+	for _, p := range ps {
+		if !isAppPolicy(p) || !p.Enabled {
+			continue
+		}
+		for _, kind := range Kinds {
+			rc, err := resource.GetResourceController(kind)
+			if err != nil {
+				continue
+			}
+			objs, err := rc.ListGroupObject(group)
+			if err != nil {
+				continue
+			}
+			for _, o := range objs {
+				m := o.Metadata()
+				if m.Workspace != p.Workspace || m.App != p.App {
+					continue
+				}
+				ts[targetKey(kind, m.Workspace, m.Name)] = target{kind: kind, workspace: m.Workspace, name: m.Name, policy: p}
+			}
+		}
+	}
+	for _, p := range ps {
+		if isAppPolicy(p) {
+			continue
+		}
+		key := targetKey(p.Kind, p.Workspace, p.Name)
+		if !p.Enabled {
+			delete(ts, key)
+			continue
+		}
+		ts[key] = target{kind: p.Kind, workspace: p.Workspace, name: p.Name, policy: p}
+	}
+
+	result := make([]target, 0, len(ts))
+	for _, t := range ts {
+		result = append(result, t)
+	}
+	return result
+}
+
+func checkGroup(group string, now time.Time) {
+	ps, err := backend.GetAutoRollbackPolicies(group)
+	if err != nil {
+		log.ErrorPrint("get auto rollback policies of group '%v' fail: %v", group, err)
+		return
+	}
+	if len(ps) == 0 {
+		return
+	}
+	hs, err := backend.GetAutoRollbackHistory(group)
+	if err != nil {
+		log.ErrorPrint("get auto rollback history of group '%v' fail: %v", group, err)
+		return
+	}
+
+	done, err := backend.GetAutoRollbackRollouts(group)
+	if err != nil {
+		log.ErrorPrint("get finished rollouts of group '%v' fail: %v", group, err)
+		return
+	}
+
+	rs := make([]backend.AutoRollbackRecord, 0)
+	newDone := make(map[string]int64)
+	for _, t := range targets(group, ps) {
+		key := targetKey(t.kind, t.workspace, t.name)
+		if v, ok := done[key]; ok {
+			newDone[key] = v
+		}
+		r := check(group, t, hs, newDone, now)
+		if r != nil {
+			rs = append(rs, *r)
+		}
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+	//只保留仍开启自动回滚的工作负载
+	if !sameRollouts(done, newDone) {
+		err = backend.SetAutoRollbackRollouts(group, newDone)
+		if err != nil {
+			log.ErrorPrint("set finished rollouts of group '%v' fail: %v", group, err)
+		}
+	}
+	if len(rs) == 0 {
+		return
+	}
+	err = backend.AppendAutoRollbackHistory(group, rs...)
+	if err != nil {
+		log.ErrorPrint("append auto rollback history of group '%v' fail: %v", group, err)
+	}
+}
+
+func sameRollouts(a, b map[string]int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+//工作负载最近的一条记录,没有时返回nil
+func lastRecord(t target, hs []backend.AutoRollbackRecord) *backend.AutoRollbackRecord {
+	for i := len(hs) - 1; i >= 0; i-- {
+		h := hs[i]
+		if h.Kind == t.kind && h.Workspace == t.workspace && h.Name == t.name {
+			return &h
+		}
+	}
+	return nil
+}
+
+//回滚会把目标版本的版本号改为当时最大的版本号加1,即FromRevision+1
+func createdByRollback(t target, revision int64, hs []backend.AutoRollbackRecord) bool {
+	for _, h := range hs {
+		if h.Success && h.Kind == t.kind && h.Workspace == t.workspace && h.Name == t.name && h.FromRevision+1 == revision {
+			return true
+		}
+	}
+	return false
+}
+
+//当前版本的滚动升级失败时回滚到上一个正常的版本,没有回滚时返回nil
+//当前版本完成过滚动升级后不再回滚,之后的异常不是升级引起的;
+//由自动回滚产生的版本也不再回滚,避免一直向前回滚
+//done为完成过滚动升级的版本,当前版本完成时更新
+func check(group string, t target, hs []backend.AutoRollbackRecord, done map[string]int64, now time.Time) *backend.AutoRollbackRecord {
+	key := targetKey(t.kind, t.workspace, t.name)
+	last := lastRecord(t, hs)
+	if last != nil && now.Sub(time.Unix(last.Time, 0)) < rollbackCooldown {
+		return nil
+	}
+
+	s, err := workload.GetRolloutStatus(t.kind, group, t.workspace, t.name)
+	if err != nil {
+		return nil
+	}
+	revs, err := workload.ListRevisions(t.kind, group, t.workspace, t.name)
+	if err != nil || len(revs) == 0 {
+		return nil
+	}
+	current := revs[0].Revision
+	if s.Done {
+		done[key] = current
+		return nil
+	}
+	finished, known := done[key]
+	if known && finished == current {
+		return nil
+	}
+	if createdByRollback(t, current, hs) {
+		return nil
+	}
+
+	//没有记录完成过的版本时无法判断是否在升级中,只按超过进度期限回滚
+	reason := ""
+	if s.Failed {
+		reason = s.Message
+	} else if known && t.policy.MaxCrashLoopPods > 0 {
+		n, err := crashLoopNewPods(t.kind, group, t.workspace, t.name)
+		if err != nil {
+			return nil
+		}
+		if n > t.policy.MaxCrashLoopPods {
+			reason = fmt.Sprintf("%v pods of the new revision are in %v, more than %v", n, crashLoopBackOff, t.policy.MaxCrashLoopPods)
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	r := backend.AutoRollbackRecord{
+		Time:      now.Unix(),
+		Kind:      t.kind,
+		Workspace: t.workspace,
+		Name:      t.name,
+		Reason:    reason,
+	}
+	if isAppPolicy(t.policy) {
+		r.App = t.policy.App
+	}
+
+	r.FromRevision = current
+	r.ToRevision = lastGoodRevision(t, revs, hs, finished)
+	if r.ToRevision == 0 {
+		r.Message = "no good revision to roll back to"
+		return &r
+	}
+
+	msg, err := rollback(group, t, r.ToRevision)
+	if err != nil {
+		r.Message = err.Error()
+		return &r
+	}
+	r.Success = true
+	r.Message = msg
+	notify(group, t, r)
+	return &r
+}
+
+//优先回滚到最近完成过滚动升级的版本
+//否则跳过当前版本和之前自动回滚过的失败版本
+func lastGoodRevision(t target, revs []workload.RevisionInfo, hs []backend.AutoRollbackRecord, finished int64) int64 {
+	failed := make(map[int64]bool)
+	failed[revs[0].Revision] = true
+	for _, h := range hs {
+		if h.Success && h.Kind == t.kind && h.Workspace == t.workspace && h.Name == t.name {
+			failed[h.FromRevision] = true
+		}
+	}
+	for _, v := range revs {
+		if v.Revision == finished && !failed[v.Revision] {
+			return v.Revision
+		}
+	}
+	for _, v := range revs {
+		if !failed[v.Revision] {
+			return v.Revision
+		}
+	}
+	return 0
+}
+
+//新版本的Pod中处于CrashLoopBackOff的个数
+func crashLoopNewPods(kind, group, workspace, name string) (int, error) {
+	var pods []*corev1.Pod
+	var isNew func(p *corev1.Pod) bool
+	switch kind {
+	case workload.KindDeployment:
+		h, err := cluster.NewDeploymentHandler(group, workspace)
+		if err != nil {
+			return 0, err
+		}
+		_, rs, err := h.GetCurrentRevisionAndReplicaSet(workspace, name)
+		if err != nil || rs == nil {
+			return 0, err
+		}
+		hash := rs.Labels[extensionsv1beta1.DefaultDeploymentUniqueLabelKey]
+		isNew = func(p *corev1.Pod) bool {
+			return p.Labels[extensionsv1beta1.DefaultDeploymentUniqueLabelKey] == hash
+		}
+		pods, err = h.GetPods(workspace, name)
+		if err != nil {
+			return 0, err
+		}
+	case workload.KindDaemonSet:
+		h, err := cluster.NewDaemonSetHandler(group, workspace)
+		if err != nil {
+			return 0, err
+		}
+		ds, err := h.Get(workspace, name)
+		if err != nil {
+			return 0, err
+		}
+		generation := strconv.FormatInt(ds.Spec.TemplateGeneration, 10)
+		isNew = func(p *corev1.Pod) bool {
+			return p.Labels[extensionsv1beta1.DaemonSetTemplateGenerationKey] == generation
+		}
+		pods, err = h.GetPods(workspace, name)
+		if err != nil {
+			return 0, err
+		}
+	default:
+		return 0, fmt.Errorf("kind '%v' doesn't support auto rollback", kind)
+	}
+
+	n := 0
+	for _, p := range pods {
+		if isNew(p) && isCrashLooping(p) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func isCrashLooping(p *corev1.Pod) bool {
+	for _, cs := range p.Status.InitContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == crashLoopBackOff {
+			return true
+		}
+	}
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == crashLoopBackOff {
+			return true
+		}
+	}
+	return false
+}
+
+//通过资源自身的Rollback回滚,并在工作负载上留下修改记录
+func rollback(group string, t target, revision int64) (string, error) {
+	var result *string
+	switch t.kind {
+	case workload.KindDeployment:
+		o, err := pkd.Controller.GetObject(group, t.workspace, t.name)
+		if err != nil {
+			return "", err
+		}
+		di, err := pkd.GetDeploymentInterface(o)
+		if err != nil {
+			return "", err
+		}
+		result, err = di.Rollback(revision)
+		if err != nil {
+			return "", err
+		}
+	case workload.KindDaemonSet:
+		o, err := pkds.Controller.GetObject(group, t.workspace, t.name)
+		if err != nil {
+			return "", err
+		}
+		di, err := pkds.GetDaemonSetInterface(o)
+		if err != nil {
+			return "", err
+		}
+		result, err = di.Rollback(revision)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("kind '%v' doesn't support auto rollback", t.kind)
+	}
+
+	msg := ""
+	if result != nil {
+		msg = *result
+	}
+	return msg, nil
+}
+
+//回滚成功后记录修改,审计和事件,失败只打印日志
+func notify(group string, t target, r backend.AutoRollbackRecord) {
+	opt := resource.UpdateOption{
+		User:      auditOperator,
+		Operation: fmt.Sprintf("%v to revision %v", auditOperate, r.ToRevision),
+		Comment:   r.Reason,
+	}
+	err := workload.RecordChange(t.kind, group, t.workspace, t.name, opt)
+	if err != nil {
+		log.ErrorPrint("record change of %v %v/%v fail: %v", t.kind, t.workspace, t.name, err)
+	}
+
+	uaudit.Audit(uaudit.AuditObj{
+		Time:       time.Now(),
+		Operator:   auditOperator,
+		Operate:    auditOperate,
+		Object:     t.kind,
+		ObjectName: t.name,
+		Level:      uaudit.AuditLevelInfo,
+	})
+
+	h, err := cluster.NewEventHandler(group, t.workspace)
+	if err != nil {
+		log.ErrorPrint(err)
+		return
+	}
+	ref := corev1.ObjectReference{Kind: t.kind, Namespace: t.workspace, Name: t.name}
+	msg := fmt.Sprintf("rolled back from revision %v to revision %v: %v", r.FromRevision, r.ToRevision, r.Reason)
+	err = h.Create(t.workspace, ref, corev1.EventTypeWarning, eventReason, msg)
+	if err != nil {
+		log.ErrorPrint("create event of %v %v/%v fail: %v", t.kind, t.workspace, t.name, err)
+	}
+	log.DebugPrint("%v %v/%v %v", t.kind, t.workspace, t.name, msg)
+}
@@ -0,0 +1,398 @@
+package autoscaler
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/canary"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
+	pk "ufleet-deploy/pkg/resource/deployment"
+	"ufleet-deploy/pkg/resource/workload"
+)
+
+const (
+	intervalEnvKey                = "AUTOSCALER_INTERVAL"
+	upscaleStabilizationEnvKey    = "AUTOSCALER_UPSCALE_STABILIZATION"
+	downscaleStabilizationEnvKey  = "AUTOSCALER_DOWNSCALE_STABILIZATION"
+	upscaleCooldownEnvKey         = "AUTOSCALER_UPSCALE_COOLDOWN"
+	downscaleCooldownEnvKey       = "AUTOSCALER_DOWNSCALE_COOLDOWN"
+	defaultInterval               = 30 * time.Second
+	defaultUpscaleStabilization   = 0
+	defaultDownscaleStabilization = 5 * time.Minute
+	defaultUpscaleCooldown        = time.Minute
+	defaultDownscaleCooldown      = 5 * time.Minute
+
+	maxDecisions = 100
+	leaderName   = "autoscaler"
+)
+
+//伸缩的时间参数
+type settings struct {
+	interval               time.Duration
+	upscaleStabilization   time.Duration //扩容取该时间内建议副本数的最小值
+	downscaleStabilization time.Duration //缩容取该时间内建议副本数的最大值
+	upscaleCooldown        time.Duration //上次伸缩后该时间内不再扩容
+	downscaleCooldown      time.Duration //上次伸缩后该时间内不再缩容
+}
+
+//一次伸缩判断的记录
+type Decision struct {
+	Time        time.Time `json:"time"`
+	Type        string    `json:"type"`
+	Metric      float64   `json:"metric"` //每个Pod的平均值,KB/s
+	Min         int       `json:"min"`
+	Max         int       `json:"max"`
+	OldReplicas int       `json:"oldreplicas"`
+	NewReplicas int       `json:"newreplicas"`
+	Scaled      bool      `json:"scaled"`
+	Reason      string    `json:"reason"`
+}
+
+type recommendation struct {
+	time     time.Time
+	replicas int
+}
+
+//每个Deployment的伸缩状态
+type state struct {
+	recommendations []recommendation
+	lastScale       time.Time
+	decisions       []Decision
+}
+
+var (
+	config settings
+	leader *kv.Leader
+	locker sync.Mutex
+	states = make(map[string]*state)
+)
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.ErrorPrint("invalid %v '%v', use default %v", key, v, def)
+		return def
+	}
+	return d
+}
+
+//定时检查所有使用磁盘或网络伸缩的Deployment
+//多副本部署时只有leader执行
+func Init() {
+	config = settings{
+		interval:               durationEnv(intervalEnvKey, defaultInterval),
+		upscaleStabilization:   durationEnv(upscaleStabilizationEnvKey, defaultUpscaleStabilization),
+		downscaleStabilization: durationEnv(downscaleStabilizationEnvKey, defaultDownscaleStabilization),
+		upscaleCooldown:        durationEnv(upscaleCooldownEnvKey, defaultUpscaleCooldown),
+		downscaleCooldown:      durationEnv(downscaleCooldownEnvKey, defaultDownscaleCooldown),
+	}
+	if config.interval <= 0 {
+		config.interval = defaultInterval
+	}
+	leader = kv.NewLeader(leaderName)
+	go loop()
+	log.DebugPrint("autoscaler interval: %v", config.interval)
+}
+
+func stateKey(group, workspace, name string) string {
+	return group + "/" + workspace + "/" + name
+}
+
+//Deployment的伸缩记录,最近的在前
+func GetDecisions(group, workspace, name string) []Decision {
+	locker.Lock()
+	defer locker.Unlock()
+
+	ds := make([]Decision, 0)
+	s, ok := states[stateKey(group, workspace, name)]
+	if !ok {
+		return ds
+	}
+	for i := len(s.decisions) - 1; i >= 0; i-- {
+		ds = append(ds, s.decisions[i])
+	}
+	return ds
+}
+
+func loop() {
+	ticker := time.NewTicker(config.interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if !leader.IsLeader() {
+			continue
+		}
+		evaluateAll(time.Now())
+	}
+}
+
+func evaluateAll(now time.Time) {
+	active := make(map[string]bool)
+	for _, g := range pk.Controller.ListGroups() {
+		objs, err := pk.Controller.ListGroupObject(g)
+		if err != nil {
+			continue
+		}
+		for _, o := range objs {
+			di, err := pk.GetDeploymentInterface(o)
+			if err != nil {
+				continue
+			}
+			d := di.Info()
+			opt := d.AutoScaler
+			if !opt.Deployed || opt.InCluster() {
+				continue
+			}
+			active[stateKey(d.Group, d.Workspace, d.Name)] = true
+			evaluate(now, di, opt)
+		}
+	}
+
+	//清理不再由autoscaler伸缩的Deployment
+	locker.Lock()
+	for k := range states {
+		if !active[k] {
+			delete(states, k)
+		}
+	}
+	locker.Unlock()
+}
+
+func metricOf(typ string) string {
+	if typ == pk.AutoScaleTypeDisk {
+		return metrics.MetricDisk
+	}
+	return metrics.MetricNetwork
+}
+
+//磁盘和网络的阈值为每个Pod的平均速率,单位为KB/s
+//磁盘为读写之和,网络为收发之和
+func metricValue(d *pk.Deployment, typ string) (float64, error) {
+	ws, err := metrics.WorkloadSeriesOf(workload.KindDeployment, d.Group, d.Workspace, d.Name, metrics.DefaultStep)
+	if err != nil {
+		return 0, err
+	}
+	if len(ws.Pods) == 0 || len(ws.Total.Samples) == 0 {
+		return 0, fmt.Errorf("no metrics of running pods")
+	}
+	//来源没有该指标时采样为0,不能据此缩容
+	metric := metricOf(typ)
+	for _, p := range ws.Pods {
+		for _, c := range p.Containers {
+			if !metrics.HasMetric(c, metric) {
+				return 0, fmt.Errorf("no %v metrics of '%v' from provider '%v'", metric, c.Name, c.Provider)
+			}
+		}
+	}
+
+	//只使用最近一个检查周期内的采样
+	last := ws.Total.Samples[len(ws.Total.Samples)-1].Time
+	var sum float64
+	var n int
+	for _, v := range ws.Total.Samples {
+		if last.Sub(v.Time) > config.interval {
+			continue
+		}
+		switch typ {
+		case pk.AutoScaleTypeDisk:
+			sum += v.DiskRead + v.DiskWrite
+		case pk.AutoScaleTypeNet:
+			sum += v.RxRate + v.TxRate
+		}
+		n++
+	}
+	return sum / float64(n) / float64(len(ws.Pods)) / 1024, nil
+}
+
+func thresholds(opt pk.HPA) (int, int) {
+	if opt.Type == pk.AutoScaleTypeDisk {
+		return opt.MinDisk, opt.MaxDisk
+	}
+	return opt.MinNetFlow, opt.MaxNetFlow
+}
+
+//超出阈值区间时按与目标值(区间中间值)的比例计算副本数
+func recommend(current int, value float64, min, max int, opt pk.HPA) (int, string) {
+	desired := current
+	reason := "metric within thresholds"
+	target := float64(min+max) / 2
+	switch {
+	case value > float64(max):
+		if target > 0 {
+			desired = int(math.Ceil(float64(current) * value / target))
+		} else {
+			desired = current + 1
+		}
+		reason = fmt.Sprintf("metric above max %v", max)
+	case value < float64(min):
+		if target > 0 {
+			desired = int(math.Ceil(float64(current) * value / target))
+		}
+		reason = fmt.Sprintf("metric below min %v", min)
+	}
+
+	if desired < opt.MinReplicas {
+		desired = opt.MinReplicas
+		reason += fmt.Sprintf(", limited by minReplicas %v", opt.MinReplicas)
+	}
+	if desired > opt.MaxReplicas {
+		desired = opt.MaxReplicas
+		reason += fmt.Sprintf(", limited by maxReplicas %v", opt.MaxReplicas)
+	}
+	return desired, reason
+}
+
+func evaluate(now time.Time, di pk.DeploymentInterface, opt pk.HPA) {
+	d := di.Info()
+	key := stateKey(d.Group, d.Workspace, d.Name)
+
+	r, err := di.GetRuntime()
+	if err != nil {
+		log.ErrorPrint("autoscaler: get deployment %v fail: %v", key, err)
+		return
+	}
+	current := 1
+	if r.Deployment.Spec.Replicas != nil {
+		current = int(*r.Deployment.Spec.Replicas)
+	}
+
+	min, max := thresholds(opt)
+	dec := Decision{
+		Time:        now,
+		Type:        opt.Type,
+		Min:         min,
+		Max:         max,
+		OldReplicas: current,
+		NewReplicas: current,
+	}
+
+	err = canary.CheckScalable(d.Group, d.Workspace, d.Name)
+	if err != nil {
+		dec.Reason = err.Error()
+		record(key, dec)
+		return
+	}
+
+	value, err := metricValue(d, opt.Type)
+	if err != nil {
+		dec.Reason = fmt.Sprintf("get metrics fail: %v", err)
+		record(key, dec)
+		return
+	}
+	dec.Metric = value
+
+	desired, reason := recommend(current, value, min, max, opt)
+
+	locker.Lock()
+	s, ok := states[key]
+	if !ok {
+		s = &state{}
+		states[key] = s
+	}
+	stabilized := s.stabilize(now, desired, current)
+	lastScale := s.lastScale
+	locker.Unlock()
+
+	if stabilized != desired {
+		reason += fmt.Sprintf(", stabilized from %v to %v", desired, stabilized)
+	}
+	desired = stabilized
+	dec.Reason = reason
+	if desired == current {
+		record(key, dec)
+		return
+	}
+
+	cooldown := config.downscaleCooldown
+	if desired > current {
+		cooldown = config.upscaleCooldown
+	}
+	if !lastScale.IsZero() && now.Sub(lastScale) < cooldown {
+		dec.Reason += fmt.Sprintf(", in cooldown until %v", lastScale.Add(cooldown).Format(time.RFC3339))
+		record(key, dec)
+		return
+	}
+
+	err = di.Scale(desired)
+	if err != nil {
+		dec.Reason += fmt.Sprintf(", scale fail: %v", err)
+		record(key, dec)
+		return
+	}
+	dec.NewReplicas = desired
+	dec.Scaled = true
+	log.DebugPrint("autoscaler: scale deployment %v from %v to %v: %v", key, current, desired, dec.Reason)
+
+	locker.Lock()
+	s.lastScale = now
+	locker.Unlock()
+	record(key, dec)
+}
+
+//记录建议的副本数,并按稳定窗口取值:
+//扩容取窗口内的最小值,缩容取窗口内的最大值,避免指标抖动造成频繁伸缩
+func (s *state) stabilize(now time.Time, desired, current int) int {
+	window := config.upscaleStabilization
+	if config.downscaleStabilization > window {
+		window = config.downscaleStabilization
+	}
+	rs := make([]recommendation, 0, len(s.recommendations)+1)
+	for _, v := range s.recommendations {
+		if now.Sub(v.time) <= window {
+			rs = append(rs, v)
+		}
+	}
+	rs = append(rs, recommendation{time: now, replicas: desired})
+	s.recommendations = rs
+
+	result := desired
+	if desired > current {
+		for _, v := range rs {
+			if now.Sub(v.time) <= config.upscaleStabilization && v.replicas < result {
+				result = v.replicas
+			}
+		}
+		if result < current {
+			result = current
+		}
+	} else if desired < current {
+		for _, v := range rs {
+			if now.Sub(v.time) <= config.downscaleStabilization && v.replicas > result {
+				result = v.replicas
+			}
+		}
+		if result > current {
+			result = current
+		}
+	}
+	return result
+}
+
+//只保留最近的记录,与上一条原因相同的未伸缩结果不重复记录
+func record(key string, dec Decision) {
+	locker.Lock()
+	defer locker.Unlock()
+
+	s, ok := states[key]
+	if !ok {
+		s = &state{}
+		states[key] = s
+	}
+	if n := len(s.decisions); n > 0 && !dec.Scaled {
+		last := s.decisions[n-1]
+		if !last.Scaled && last.Reason == dec.Reason && last.OldReplicas == dec.OldReplicas {
+			return
+		}
+	}
+	s.decisions = append(s.decisions, dec)
+	if len(s.decisions) > maxDecisions {
+		s.decisions = s.decisions[len(s.decisions)-maxDecisions:]
+	}
+}
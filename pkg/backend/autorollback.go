@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdAutoRollbackKey        = "/ufleet/deploy/autorollback"
+	etcdAutoRollbackHistoryKey = "/ufleet/deploy/autorollbackhistory"
+	etcdAutoRollbackRolloutKey = "/ufleet/deploy/autorollbackrollout"
+
+	//每个组最多保留的自动回滚记录数
+	MaxAutoRollbackHistory = 200
+)
+
+//自动回滚策略,Kind和Name为空时是应用的策略,作用于应用下所有的Deployment和DaemonSet
+//工作负载自身的策略优先于应用的策略
+type AutoRollbackPolicy struct {
+	Workspace        string `json:"workspace"`
+	Kind             string `json:"kind"`
+	Name             string `json:"name"`
+	App              string `json:"app"`
+	Enabled          bool   `json:"enabled"`
+	MaxCrashLoopPods int    `json:"maxcrashlooppods"` //新版本CrashLoopBackOff的Pod数超过该值时回滚,0表示只在超过进度期限时回滚
+}
+
+//一次自动回滚的记录
+type AutoRollbackRecord struct {
+	Time         int64  `json:"time"`
+	Kind         string `json:"kind"`
+	Workspace    string `json:"workspace"`
+	Name         string `json:"name"`
+	App          string `json:"app"` //使用的是应用的策略时为应用名
+	FromRevision int64  `json:"fromrevision"`
+	ToRevision   int64  `json:"torevision"`
+	Reason       string `json:"reason"`
+	Success      bool   `json:"success"`
+	Message      string `json:"message"`
+}
+
+func autoRollbackKey(group string) string {
+	return etcdAutoRollbackKey + "/" + group
+}
+
+func autoRollbackHistoryKey(group string) string {
+	return etcdAutoRollbackHistoryKey + "/" + group
+}
+
+func autoRollbackRolloutKey(group string) string {
+	return etcdAutoRollbackRolloutKey + "/" + group
+}
+
+//组下所有的自动回滚策略
+func GetAutoRollbackPolicies(group string) ([]AutoRollbackPolicy, error) {
+	ps := make([]AutoRollbackPolicy, 0)
+	node, err := kv.Store.GetNode(autoRollbackKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return ps, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &ps)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return ps, nil
+}
+
+func SetAutoRollbackPolicies(group string, ps []AutoRollbackPolicy) error {
+	return kv.Store.UpdateNode(autoRollbackKey(group), ps)
+}
+
+//按时间从旧到新返回
+func GetAutoRollbackHistory(group string) ([]AutoRollbackRecord, error) {
+	rs := make([]AutoRollbackRecord, 0)
+	node, err := kv.Store.GetNode(autoRollbackHistoryKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return rs, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &rs)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return rs, nil
+}
+
+//追加记录,超过MaxAutoRollbackHistory时删除最旧的记录
+//调用者需要保证同一组不会并发追加
+func AppendAutoRollbackHistory(group string, records ...AutoRollbackRecord) error {
+	rs, err := GetAutoRollbackHistory(group)
+	if err != nil {
+		return err
+	}
+	rs = append(rs, records...)
+	if len(rs) > MaxAutoRollbackHistory {
+		rs = rs[len(rs)-MaxAutoRollbackHistory:]
+	}
+	return kv.Store.UpdateNode(autoRollbackHistoryKey(group), rs)
+}
+
+//组下开启了自动回滚的工作负载最近一次完成滚动升级的版本,键为kind/workspace/name
+func GetAutoRollbackRollouts(group string) (map[string]int64, error) {
+	rs := make(map[string]int64)
+	node, err := kv.Store.GetNode(autoRollbackRolloutKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return rs, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &rs)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return rs, nil
+}
+
+func SetAutoRollbackRollouts(group string, rs map[string]int64) error {
+	return kv.Store.UpdateNode(autoRollbackRolloutKey(group), rs)
+}
@@ -107,6 +107,8 @@ var (
 var (
 	locker   sync.Mutex
 	noticers = make(map[string]ResourceEventHander)
+	//旁路观察者,接收所有类型资源的事件
+	observers = make(map[string]ResourceEventObserver)
 )
 
 //type EventHandler func(ResourceEvent, controller interface{})
@@ -124,6 +126,30 @@ func RegisterEventHandler(kind string, fn ResourceEventHander) { //fn EventHandl
 
 }
 
+type ResourceEventObserver interface {
+	ObserveEvent(kind string, re ResourceEvent)
+}
+
+//注册观察者,观察者会收到所有资源类型的事件
+func RegisterEventObserver(name string, o ResourceEventObserver) {
+	locker.Lock()
+	defer locker.Unlock()
+	observers[name] = o
+}
+
+func notifyEventObservers(kind string, re ResourceEvent) {
+	locker.Lock()
+	obs := make([]ResourceEventObserver, 0, len(observers))
+	for _, v := range observers {
+		obs = append(obs, v)
+	}
+	locker.Unlock()
+
+	for _, v := range obs {
+		v.ObserveEvent(kind, re)
+	}
+}
+
 func fetchEvent(eventKey string) (string, string, error) {
 	s := strings.TrimPrefix(eventKey, etcdUfleetKey+"/")
 	slice := strings.SplitN(s, "/", 2)
@@ -179,7 +205,13 @@ func watchBackendEvent() error {
 				}
 
 				action := res.Action
-				value := res.Node.Value
+				value, err := decodeResource(kind, res.Node.Value)
+				if err != nil {
+					log.ErrorPrint(err)
+					return
+				}
+
+				notifyEventObservers(kind, getEventFromEtcdKey(remain, value, action))
 
 				noticer, ok := noticers[kind]
 				if !ok {
@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdCanaryKey = "/ufleet/deploy/canary"
+)
+
+//金丝雀发布的一个步骤
+type CanaryStep struct {
+	Weight int `json:"weight"` //金丝雀版本的流量百分比,1-100
+	Pause  int `json:"pause"`  //保持的秒数,到期且分析通过后自动进入下一步;0表示等待手动推进
+}
+
+//自动推进前对金丝雀Pod的检查,任一项不满足时中止发布
+type CanaryAnalysis struct {
+	MaxRestarts int     `json:"maxrestarts"` //大于0时检查金丝雀Pod的重启次数之和
+	MaxCPU      float64 `json:"maxcpu"`      //大于0时检查金丝雀Pod平均使用的核数
+	MaxMemory   uint64  `json:"maxmemory"`   //大于0时检查金丝雀Pod平均使用的内存,字节
+}
+
+//Deployment的金丝雀发布,每个Deployment只保留最近一次
+type Canary struct {
+	Workspace  string `json:"workspace"`
+	Name       string `json:"name"`       //稳定版本的Deployment
+	CanaryName string `json:"canaryname"` //金丝雀版本的Deployment
+
+	Strategy      string `json:"strategy"`      //replicas:按副本数比例共用Service;ingress:通过Ingress的权重注解
+	Ingress       string `json:"ingress"`       //ingress方式时稳定版本的Ingress
+	CanaryIngress string `json:"canaryingress"` //ingress方式时创建的金丝雀Ingress
+	CanaryService string `json:"canaryservice"` //ingress方式时创建的金丝雀Service
+
+	Steps    []CanaryStep   `json:"steps"`
+	Analysis CanaryAnalysis `json:"analysis"`
+
+	Step           int `json:"step"`           //当前步骤的下标
+	Weight         int `json:"weight"`         //当前金丝雀版本的流量百分比
+	StableReplicas int `json:"stablereplicas"` //开始发布时稳定版本的副本数
+
+	Phase      string `json:"phase"`
+	Message    string `json:"message"`
+	User       string `json:"user"`
+	CreateTime int64  `json:"createtime"`
+	StepTime   int64  `json:"steptime"` //进入当前步骤的时间
+	FinishTime int64  `json:"finishtime"`
+}
+
+func canaryKey(group string) string {
+	return etcdCanaryKey + "/" + group
+}
+
+//组下所有Deployment的金丝雀发布
+func GetCanaries(group string) ([]Canary, error) {
+	cs := make([]Canary, 0)
+	node, err := kv.Store.GetNode(canaryKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return cs, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &cs)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return cs, nil
+}
+
+func SetCanaries(group string, cs []Canary) error {
+	return kv.Store.UpdateNode(canaryKey(group), cs)
+}
@@ -0,0 +1,324 @@
+package backend
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"ufleet-deploy/pkg/log"
+)
+
+//信封加密:每条记录使用随机生成的数据密钥(DEK)加密,
+//数据密钥再由密钥提供者的主密钥(KEK)加密后与数据一起保存
+//轮换主密钥后旧记录仍可以用旧主密钥解密,通过迁移重新加密
+const (
+	encryptProviderEnvKey  = "ENCRYPT_PROVIDER"
+	defaultEncryptProvider = "local"
+	encryptKeyLen          = 32 //AES-256
+
+	//旧格式,直接使用主密钥加密,主密钥ID为legacyKeyID
+	encryptedPrefixV1 = "enc:v1:"
+	encryptedPrefixV2 = "enc:v2:"
+	legacyKeyID       = "default"
+)
+
+var (
+	//密钥提供者没有配置时返回,此时不加密,已有加密数据时不能启动
+	ErrKeyProviderNotConfigured = errors.New("encrypt key file is not configured, set ENCRYPT_KEYFILE to a key file on persistent storage")
+
+	keyProviderFactories = make(map[string]KeyProviderFactory)
+	keyProvider          KeyProvider
+	keyProviderLock      = sync.Mutex{}
+	//没有配置密钥时不加密,直接保存明文
+	encryptDisabled bool
+
+	//需要加密保存的资源类型
+	encryptedKinds = map[string]bool{
+		ResourceSecrets: true,
+	}
+)
+
+//主密钥提供者
+type KeyProvider interface {
+	Name() string
+	//返回当前用于加密的主密钥及其ID
+	CurrentKey() (string, []byte, error)
+	//根据ID获取主密钥,用于解密
+	GetKey(id string) ([]byte, error)
+	//生成新的主密钥并设为当前密钥,旧密钥保留用于解密
+	Rotate() (string, error)
+}
+
+type KeyProviderFactory func() (KeyProvider, error)
+
+func RegisterKeyProvider(name string, fn KeyProviderFactory) {
+	keyProviderLock.Lock()
+	defer keyProviderLock.Unlock()
+	keyProviderFactories[name] = fn
+}
+
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderLock.Lock()
+	defer keyProviderLock.Unlock()
+
+	if keyProvider != nil {
+		return keyProvider, nil
+	}
+
+	name := os.Getenv(encryptProviderEnvKey)
+	if name == "" {
+		name = defaultEncryptProvider
+	}
+	fn, ok := keyProviderFactories[name]
+	if !ok {
+		return nil, fmt.Errorf("encrypt key provider \"%v\" doesn't register", name)
+	}
+	kp, err := fn()
+	if err != nil {
+		return nil, err
+	}
+	keyProvider = kp
+	return keyProvider, nil
+}
+
+func initKeyProvider() error {
+	kp, err := getKeyProvider()
+	if err == ErrKeyProviderNotConfigured {
+		found, err2 := hasEncryptedData()
+		if err2 != nil {
+			return err2
+		}
+		if found {
+			return fmt.Errorf("there is encrypted data in etcd, but %v", err)
+		}
+		log.ErrorPrint("WARNING: %v, secrets are saved without encryption", err)
+		keyProviderLock.Lock()
+		encryptDisabled = true
+		keyProviderLock.Unlock()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, _, err = kp.CurrentKey()
+	return err
+}
+
+//轮换主密钥,返回新密钥的ID
+func RotateEncryptKey() (string, error) {
+	kp, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	return kp.Rotate()
+}
+
+func IsEncryptedKind(kind string) bool {
+	return encryptedKinds[kind]
+}
+
+func IsEncrypted(data string) bool {
+	return strings.HasPrefix(data, encryptedPrefixV1) || strings.HasPrefix(data, encryptedPrefixV2)
+}
+
+func newRandomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(rand.Reader, b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+//返回nonce+密文
+func seal(key, plain []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := newRandomBytes(gcm.NonceSize())
+	if err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid encrypted data")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+type envelope struct {
+	KeyID string `json:"kid"`
+	DEK   []byte `json:"dek"`  //主密钥加密后的数据密钥
+	Data  []byte `json:"data"` //数据密钥加密后的数据
+}
+
+func decodeEnvelope(data string) (*envelope, error) {
+	bs, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(data, encryptedPrefixV2))
+	if err != nil {
+		return nil, err
+	}
+	var env envelope
+	err = json.Unmarshal(bs, &env)
+	if err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+func isEncryptDisabled() bool {
+	keyProviderLock.Lock()
+	defer keyProviderLock.Unlock()
+	return encryptDisabled
+}
+
+//使用当前主密钥进行信封加密,没有配置密钥时返回明文
+func EncryptData(plain []byte) (string, error) {
+	if isEncryptDisabled() {
+		return string(plain), nil
+	}
+	kp, err := getKeyProvider()
+	if err != nil {
+		return "", err
+	}
+	kid, kek, err := kp.CurrentKey()
+	if err != nil {
+		return "", err
+	}
+
+	dek, err := newRandomBytes(encryptKeyLen)
+	if err != nil {
+		return "", err
+	}
+	var env envelope
+	env.KeyID = kid
+	env.DEK, err = seal(kek, dek)
+	if err != nil {
+		return "", err
+	}
+	env.Data, err = seal(dek, plain)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefixV2 + base64.StdEncoding.EncodeToString(bs), nil
+}
+
+func DecryptData(data string) ([]byte, error) {
+	kp, err := getKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasPrefix(data, encryptedPrefixV2):
+		env, err := decodeEnvelope(data)
+		if err != nil {
+			return nil, err
+		}
+		kek, err := kp.GetKey(env.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		dek, err := open(kek, env.DEK)
+		if err != nil {
+			return nil, err
+		}
+		return open(dek, env.Data)
+	case strings.HasPrefix(data, encryptedPrefixV1):
+		sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(data, encryptedPrefixV1))
+		if err != nil {
+			return nil, err
+		}
+		kek, err := kp.GetKey(legacyKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return open(kek, sealed)
+	}
+	return nil, fmt.Errorf("data is not encrypted")
+}
+
+//数据是否需要用当前主密钥重新加密
+func needReencrypt(data string) (bool, error) {
+	if !IsEncrypted(data) {
+		return true, nil
+	}
+	if strings.HasPrefix(data, encryptedPrefixV1) {
+		return true, nil
+	}
+
+	kp, err := getKeyProvider()
+	if err != nil {
+		return false, err
+	}
+	kid, _, err := kp.CurrentKey()
+	if err != nil {
+		return false, err
+	}
+	env, err := decodeEnvelope(data)
+	if err != nil {
+		return false, err
+	}
+	return env.KeyID != kid, nil
+}
+
+//需要加密的资源类型,写入etcd前加密
+func encodeResource(kind string, data interface{}) (interface{}, error) {
+	if !IsEncryptedKind(kind) {
+		return data, nil
+	}
+
+	var bs []byte
+	switch v := data.(type) {
+	case string:
+		bs = []byte(v)
+	case []byte:
+		bs = v
+	default:
+		var err error
+		bs, err = json.Marshal(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return EncryptData(bs)
+}
+
+//从etcd读出后解密,兼容未加密的旧数据
+func decodeResource(kind string, value string) (string, error) {
+	if !IsEncryptedKind(kind) || !IsEncrypted(value) {
+		return value, nil
+	}
+	bs, err := DecryptData(value)
+	if err != nil {
+		return "", err
+	}
+	return string(bs), nil
+}
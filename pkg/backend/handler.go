@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 
 	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
 )
 
 var (
@@ -66,7 +67,11 @@ func (e *eb) GetResource(kind, groupName, workspaceName, resouceName string) ([]
 			return nil, BackendResourceNotFound
 		}
 	}
-	return []byte(resp.Value), nil
+	value, err := decodeResource(kind, resp.Value)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
 
 }
 
@@ -76,7 +81,12 @@ func (e *eb) CreateResource(kind, groupName, workspaceName, resouceName string,
 		return BackendResourceInvalid
 	}
 
-	err = kv.Store.CreateNode(key, data)
+	value, err := encodeResource(kind, data)
+	if err != nil {
+		return err
+	}
+
+	err = kv.Store.CreateNode(key, value)
 	if err != nil {
 		if err == kv.ErrKeyAlreadyExists {
 			return BackendResourceAlreadyExists
@@ -94,7 +104,12 @@ func (e *eb) UpdateResource(kind, groupName, workspaceName, resouceName string,
 		return BackendResourceInvalid
 	}
 
-	err = kv.Store.UpdateNode(key, data)
+	value, err := encodeResource(kind, data)
+	if err != nil {
+		return err
+	}
+
+	err = kv.Store.UpdateNode(key, value)
 	if err != nil {
 		if err == kv.ErrKeyNotFound {
 			return BackendResourceNotFound
@@ -234,7 +249,13 @@ func (e *eb) GetResourceAllGroup(kind string) (map[string]ResourceGroup, error)
 			for _, n := range wrespChild {
 
 				resouceName := filepath.Base(n.Key)
-				resource := []byte(n.Value)
+				//单条记录解密失败时跳过,不影响其他资源的加载
+				value, err := decodeResource(kind, n.Value)
+				if err != nil {
+					log.ErrorPrint("decode %v \"%v\" fail, skip it: %v", kind, n.Key, err)
+					continue
+				}
+				resource := []byte(value)
 
 				workspace.Resources[resouceName] = resource
 			}
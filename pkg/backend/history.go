@@ -0,0 +1,109 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdHistoryKey = "/ufleet/deploy/history"
+
+	//每个资源最多保留的历史版本数
+	MaxHistoryRevisions = 20
+)
+
+var (
+	ErrRevisionNotFound = fmt.Errorf("revision not found")
+)
+
+//资源的一个历史版本,Data的格式由资源自己决定
+type Revision struct {
+	Version int64  `json:"version"`
+	User    string `json:"user"`
+	Time    int64  `json:"time"`
+	Comment string `json:"comment"`
+	Data    string `json:"data"`
+}
+
+func historyKey(kind, group, workspace, name string) string {
+	return etcdHistoryKey + "/" + kind + "/" + group + "/" + workspace + "/" + name
+}
+
+//按版本从旧到新返回
+func GetHistory(kind, group, workspace, name string) ([]Revision, error) {
+	rs := make([]Revision, 0)
+	node, err := kv.Store.GetNode(historyKey(kind, group, workspace, name))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return rs, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &rs)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return rs, nil
+}
+
+func GetRevision(kind, group, workspace, name string, version int64) (*Revision, error) {
+	rs, err := GetHistory(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	for k := range rs {
+		if rs[k].Version == version {
+			return &rs[k], nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+//追加历史版本,版本号递增,超过MaxHistoryRevisions时删除最旧的版本
+//调用者需要保证同一资源不会并发追加
+func AppendHistory(kind, group, workspace, name string, revisions ...Revision) error {
+	rs, err := GetHistory(kind, group, workspace, name)
+	if err != nil {
+		return err
+	}
+
+	var version int64
+	if len(rs) != 0 {
+		version = rs[len(rs)-1].Version
+	}
+	for _, v := range revisions {
+		version++
+		v.Version = version
+		rs = append(rs, v)
+	}
+	if len(rs) > MaxHistoryRevisions {
+		rs = rs[len(rs)-MaxHistoryRevisions:]
+	}
+
+	return kv.Store.UpdateNode(historyKey(kind, group, workspace, name), rs)
+}
+
+func DeleteHistory(kind, group, workspace, name string) error {
+	err := kv.Store.DeleteNode(historyKey(kind, group, workspace, name))
+	if err != nil && err != kv.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
+
+//删除工作区下某类资源的所有历史版本,workspace为空时删除整个组的
+func DeleteHistories(kind, group, workspace string) error {
+	key := etcdHistoryKey + "/" + kind + "/" + group
+	if workspace != "" {
+		key += "/" + workspace
+	}
+	err := kv.Store.DeleteDirNode(key)
+	if err != nil && err != kv.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
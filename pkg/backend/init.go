@@ -6,8 +6,15 @@ import (
 
 func Init() error {
 	be := NewBackendHandler()
+	//需要在读取加密的资源前初始化
+	log.DebugPrint("init encrypt key provider")
+	err := initKeyProvider()
+	if err != nil {
+		return err
+	}
+
 	//创建根key
-	err := initRootKey()
+	err = initRootKey()
 	if err != nil {
 		return err
 	}
@@ -41,11 +48,22 @@ func Init() error {
 		return err
 	}
 
+	log.DebugPrint("load workspace quotas")
+	err = loadWorkspaceQuotas()
+	if err != nil {
+		return err
+	}
+
 	err = watchWorkspaceChange()
 	if err != nil {
 		return err
 	}
 
+	err = watchRegistyEvent()
+	if err != nil {
+		return err
+	}
+
 	err = watchBackendEvent()
 	if err != nil {
 		return err
@@ -0,0 +1,247 @@
+package backend
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//密钥文件必须放在持久化的存储上,容器重启后丢失会导致已加密的数据无法解密
+	//不设置时不加密,见README
+	encryptKeyFileEnvKey = "ENCRYPT_KEYFILE"
+)
+
+func init() {
+	RegisterKeyProvider(defaultEncryptProvider, newLocalKeyProvider)
+}
+
+type localKey struct {
+	ID         string `json:"id"`
+	Key        []byte `json:"key"`
+	CreateTime int64  `json:"createtime"`
+}
+
+type localKeyFile struct {
+	Current string     `json:"current"`
+	Keys    []localKey `json:"keys"`
+}
+
+//本地密钥文件,保存所有主密钥
+//只有etcd中还没有加密的数据时才会生成新的密钥文件;
+//已读取的密钥不会丢弃,密钥文件被删除或者缺少密钥时用内存中的密钥补全
+type localKeyProvider struct {
+	path    string
+	keys    localKeyFile
+	modTime time.Time //密钥文件的修改时间,文件变化后重新读取
+	locker  sync.Mutex
+}
+
+func newLocalKeyProvider() (KeyProvider, error) {
+	f := os.Getenv(encryptKeyFileEnvKey)
+	if f == "" {
+		return nil, ErrKeyProviderNotConfigured
+	}
+	p := &localKeyProvider{path: f}
+	err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *localKeyProvider) Name() string {
+	return defaultEncryptProvider
+}
+
+func newLocalKey(id string) (*localKey, error) {
+	key, err := newRandomBytes(encryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	return &localKey{ID: id, Key: key, CreateTime: time.Now().Unix()}, nil
+}
+
+func newLocalKeyID() string {
+	return fmt.Sprintf("local-%v", time.Now().UnixNano())
+}
+
+//无锁
+func (p *localKeyProvider) load() error {
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		return p.loadMissing()
+	}
+
+	//需要写回文件:旧格式或者补回了密钥
+	changed := false
+	var kf localKeyFile
+	err = json.Unmarshal(data, &kf)
+	if err != nil {
+		//旧格式的密钥文件只有一个base64编码的密钥
+		key, err2 := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err2 != nil {
+			return fmt.Errorf("invalid encrypt key file \"%v\": %v", p.path, err)
+		}
+		kf = localKeyFile{Current: legacyKeyID, Keys: []localKey{{ID: legacyKeyID, Key: key}}}
+		changed = true
+	}
+
+	for _, v := range kf.Keys {
+		if len(v.Key) != encryptKeyLen {
+			return fmt.Errorf("invalid encrypt key file \"%v\": key \"%v\" must be %v bytes", p.path, v.ID, encryptKeyLen)
+		}
+	}
+	if _, ok := kf.get(kf.Current); !ok {
+		return fmt.Errorf("invalid encrypt key file \"%v\": current key \"%v\" not found", p.path, kf.Current)
+	}
+
+	//密钥只增不减,文件中缺少的已读取密钥补回去
+	for _, v := range p.keys.Keys {
+		key, ok := kf.get(v.ID)
+		if !ok {
+			log.ErrorPrint("encrypt key \"%v\" is missing in key file \"%v\", restore it", v.ID, p.path)
+			kf.Keys = append(kf.Keys, v)
+			changed = true
+			continue
+		}
+		if !bytes.Equal(key, v.Key) {
+			return fmt.Errorf("invalid encrypt key file \"%v\": key \"%v\" doesn't match the loaded one", p.path, v.ID)
+		}
+	}
+	p.keys = kf
+	if changed {
+		return p.save()
+	}
+	p.modTime = fileModTime(p.path)
+	return nil
+}
+
+//密钥文件不存在:已经读取过密钥时用内存中的密钥恢复文件;
+//启动时只有etcd中没有加密的数据才生成新密钥,否则生成的密钥无法解密已有的数据
+func (p *localKeyProvider) loadMissing() error {
+	if len(p.keys.Keys) != 0 {
+		log.ErrorPrint("encrypt key file \"%v\" is missing, restore it from loaded keys", p.path)
+		return p.save()
+	}
+
+	found, err := hasEncryptedData()
+	if err != nil {
+		return err
+	}
+	if found {
+		return fmt.Errorf("encrypt key file \"%v\" not found, but there is encrypted data in etcd, restore the key file first", p.path)
+	}
+
+	k, err := newLocalKey(newLocalKeyID())
+	if err != nil {
+		return err
+	}
+	p.keys = localKeyFile{Current: k.ID, Keys: []localKey{*k}}
+	return p.save()
+}
+
+func fileModTime(path string) time.Time {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return fi.ModTime()
+}
+
+//先写临时文件再重命名,避免写入中断导致密钥丢失
+func (p *localKeyProvider) save() error {
+	data, err := json.MarshalIndent(p.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p.path), 0700)
+	if err != nil {
+		return err
+	}
+	tmp := p.path + ".tmp"
+	err = ioutil.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmp, p.path)
+	if err != nil {
+		return err
+	}
+	p.modTime = fileModTime(p.path)
+	return nil
+}
+
+func (kf *localKeyFile) get(id string) ([]byte, bool) {
+	for _, v := range kf.Keys {
+		if v.ID == id {
+			return v.Key, true
+		}
+	}
+	return nil, false
+}
+
+//密钥文件被其他进程(如迁移命令)轮换后,使用新的当前密钥
+func (p *localKeyProvider) CurrentKey() (string, []byte, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if !fileModTime(p.path).Equal(p.modTime) {
+		err := p.load()
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	key, _ := p.keys.get(p.keys.Current)
+	return p.keys.Current, key, nil
+}
+
+//找不到时重新读取密钥文件
+func (p *localKeyProvider) GetKey(id string) ([]byte, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	if key, ok := p.keys.get(id); ok {
+		return key, nil
+	}
+	err := p.load()
+	if err != nil {
+		return nil, err
+	}
+	if key, ok := p.keys.get(id); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encrypt key \"%v\" not found", id)
+}
+
+func (p *localKeyProvider) Rotate() (string, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+
+	err := p.load()
+	if err != nil {
+		return "", err
+	}
+	k, err := newLocalKey(newLocalKeyID())
+	if err != nil {
+		return "", err
+	}
+	p.keys.Keys = append(p.keys.Keys, *k)
+	p.keys.Current = k.ID
+	err = p.save()
+	if err != nil {
+		return "", err
+	}
+	return k.ID, nil
+}
@@ -0,0 +1,169 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+type MigrateResult struct {
+	KeyID    string   `json:"keyid"` //迁移后使用的主密钥
+	Total    int      `json:"total"`
+	Migrated int      `json:"migrated"`
+	Failed   []string `json:"failed"`
+}
+
+//遍历key下第depth层的节点
+func walkNodes(key string, depth int, fn func(n kv.Node)) error {
+	nodes, err := kv.Store.GetChildNode(key)
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return nil
+		}
+		return err
+	}
+	for _, n := range nodes {
+		if depth == 1 {
+			fn(n)
+			continue
+		}
+		err := walkNodes(n.Key, depth-1, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//返回使用当前主密钥重新加密后的数据,不需要重新加密时返回空字符串
+func reencrypt(data string) (string, error) {
+	need, err := needReencrypt(data)
+	if err != nil || !need {
+		return "", err
+	}
+
+	plain := []byte(data)
+	if IsEncrypted(data) {
+		plain, err = DecryptData(data)
+		if err != nil {
+			return "", err
+		}
+	}
+	return EncryptData(plain)
+}
+
+func migrateResourceNode(n kv.Node) (bool, error) {
+	if n.Value == "" {
+		return false, nil
+	}
+	enc, err := reencrypt(n.Value)
+	if err != nil || enc == "" {
+		return false, err
+	}
+	return true, kv.Store.UpdateNode(n.Key, enc)
+}
+
+func migrateHistoryNode(n kv.Node) (bool, error) {
+	rs := make([]Revision, 0)
+	err := json.Unmarshal([]byte(n.Value), &rs)
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	for k := range rs {
+		enc, err := reencrypt(rs[k].Data)
+		if err != nil {
+			return false, err
+		}
+		if enc != "" {
+			rs[k].Data = enc
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	return true, kv.Store.UpdateNode(n.Key, rs)
+}
+
+//使用当前主密钥重新加密需要加密的资源及其历史版本,未加密的旧数据也会被加密
+//轮换主密钥后执行,迁移完成前旧主密钥不能删除
+//迁移与服务的写入之间没有互斥,建议在没有更新操作时执行
+func MigrateEncryption() (*MigrateResult, error) {
+	kp, err := getKeyProvider()
+	if err != nil {
+		return nil, err
+	}
+	kid, _, err := kp.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	r := MigrateResult{KeyID: kid, Failed: make([]string, 0)}
+	migrate := func(fn func(kv.Node) (bool, error)) func(kv.Node) {
+		return func(n kv.Node) {
+			r.Total++
+			migrated, err := fn(n)
+			if err != nil {
+				log.ErrorPrint("migrate encryption of \"%v\" fail for %v", n.Key, err)
+				r.Failed = append(r.Failed, n.Key)
+				return
+			}
+			if migrated {
+				r.Migrated++
+			}
+		}
+	}
+
+	for kind := range encryptedKinds {
+		key, err := generateBackendKey(kind)
+		if err != nil {
+			return nil, err
+		}
+		//group/workspace/resource
+		err = walkNodes(key, 3, migrate(migrateResourceNode))
+		if err != nil {
+			return nil, err
+		}
+
+		err = walkNodes(etcdHistoryKey+"/"+kind, 3, migrate(migrateHistoryNode))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &r, nil
+}
+
+//etcd中是否有加密的资源或者历史版本
+func hasEncryptedData() (bool, error) {
+	found := false
+	check := func(n kv.Node) {
+		if IsEncrypted(n.Value) {
+			found = true
+		}
+	}
+	for kind := range encryptedKinds {
+		key, err := generateBackendKey(kind)
+		if err != nil {
+			return false, err
+		}
+		err = walkNodes(key, 3, check)
+		if err != nil {
+			return false, err
+		}
+		err = walkNodes(etcdHistoryKey+"/"+kind, 3, func(n kv.Node) {
+			var rs []Revision
+			if json.Unmarshal([]byte(n.Value), &rs) != nil {
+				return
+			}
+			for _, r := range rs {
+				check(kv.Node{Value: r.Data})
+			}
+		})
+		if err != nil {
+			return false, err
+		}
+	}
+	return found, nil
+}
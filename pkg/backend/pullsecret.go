@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdPullSecretPolicyKey = "/ufleet/deploy/pullsecretpolicy"
+)
+
+//组的镜像拉取凭据注入策略,开启后创建/更新工作负载时,
+//为使用组镜像仓库的镜像自动添加imagePullSecrets
+type PullSecretPolicy struct {
+	Group   string `json:"group"`
+	Enabled bool   `json:"enabled"`
+}
+
+func pullSecretPolicyKey(group string) string {
+	return etcdPullSecretPolicyKey + "/" + group
+}
+
+//没有设置策略时返回关闭的策略
+func GetPullSecretPolicy(group string) (*PullSecretPolicy, error) {
+	pp := PullSecretPolicy{Group: group}
+
+	node, err := kv.Store.GetNode(pullSecretPolicyKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return &pp, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &pp)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return &pp, nil
+}
+
+func SetPullSecretPolicy(pp PullSecretPolicy) error {
+	return kv.Store.UpdateNode(pullSecretPolicyKey(pp.Group), pp)
+}
@@ -1,19 +1,21 @@
 package backend
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"strings"
 	"sync"
 	"time"
 	"ufleet-deploy/pkg/kv"
-
-	"github.com/astaxie/beego"
+	"ufleet-deploy/pkg/log"
 )
 
 //{"action":"get","node":{"key":"/ufleet/registry/g2","dir":true,"nodes":[{"key":"/ufleet/registry/g2/Reg20170806230821jP8C","value":"{\"id\":\"Reg20170806230821jP8C\",\"name\":\"reg\",\"address\":\"http://192.168.18.250:5002\",\"user\":\"admin\",\"password\":\"MTIzNDU2\",\"updateTime\":1502075301,\"belong\":\"g2\"}","modifiedIndex":19120,"createdIndex":19120}],"modifiedIndex":18757,"createdIndex":18757}}
 const (
 	etcdRegistryKey = "/ufleet/registry"
+
+	registryNoticerBufferSize = 100
 )
 
 var (
@@ -22,26 +24,36 @@ var (
 )
 
 type Registry struct {
-	ID       string `id`
-	Name     string `name`
-	Address  string `address`
-	User     string `user`
-	Password string `password`
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Address  string `json:"address"`
+	User     string `json:"user"`
+	Password string `json:"password"` //base64编码
 	//	UpdateTime int64  `updateTime`
-	Group string `belong`
+	Group string `json:"belong"`
+}
+
+//用户模块保存的密码经过base64编码,无法解码时认为是明文
+func (r *Registry) DecodedPassword() string {
+	p, err := base64.StdEncoding.DecodeString(r.Password)
+	if err != nil {
+		return r.Password
+	}
+	return string(p)
 }
 
 type RegistryEvent struct {
-	Action   string
-	Group    string
-	Registry *Registry
+	Action     string
+	Group      string
+	RegistryID string
+	Registry   *Registry //删除事件时为nil
 }
 
 func RegisterRegistryNoticer(name string) (chan RegistryEvent, error) {
 	regLock.Lock()
 	defer regLock.Unlock()
 
-	regChan := make(chan RegistryEvent)
+	regChan := make(chan RegistryEvent, registryNoticerBufferSize)
 	if _, ok := registryNoticers[name]; ok {
 		return nil, fmt.Errorf("noticer \"%v\" has registered", name)
 	}
@@ -51,7 +63,48 @@ func RegisterRegistryNoticer(name string) (chan RegistryEvent, error) {
 
 }
 
-//TODO:还需要监控registry更新事件
+func notifyRegistryNoticers(event RegistryEvent) {
+	regLock.Lock()
+	defer regLock.Unlock()
+	for k, v := range registryNoticers {
+		select {
+		case v <- event:
+		default:
+			log.ErrorPrint("registry noticer \"%v\" is busy, drop event of registry \"%v/%v\"", k, event.Group, event.RegistryID)
+		}
+	}
+}
+
+//key为/ufleet/registry/<group>/<registry id>
+func getRegistryEvent(we kv.WatchEvent) (*RegistryEvent, error) {
+	remain := strings.TrimPrefix(we.Node.Key, etcdRegistryKey+"/")
+	s := strings.Split(remain, "/")
+	if len(s) != 2 {
+		return nil, nil
+	}
+
+	var event RegistryEvent
+	event.Action = we.Action
+	event.Group = s[0]
+	event.RegistryID = s[1]
+	if we.Action == kv.ActionDelete || we.Node.Value == "" {
+		return &event, nil
+	}
+
+	var r Registry
+	err := json.Unmarshal([]byte(we.Node.Value), &r)
+	if err != nil {
+		return nil, fmt.Errorf("parse registry \"%v\" fail for %v", we.Node.Key, err)
+	}
+	if r.Group == "" {
+		r.Group = event.Group
+	}
+	event.Registry = &r
+	return &event, nil
+}
+
+//监听镜像仓库的创建/更新/删除事件,通知注册的noticer
+//watch通道关闭后重新建立watch
 func watchRegistyEvent() error {
 	wechan, err := kv.Store.WatchNode(etcdRegistryKey)
 	if err != nil {
@@ -60,43 +113,38 @@ func watchRegistyEvent() error {
 
 	go func() {
 		for {
-			we := <-wechan
+			we, ok := <-wechan
+			if !ok {
+				log.ErrorPrint("registry watcher closed, rewatch")
+				for {
+					time.Sleep(2 * time.Second)
+					wechan, err = kv.Store.WatchNode(etcdRegistryKey)
+					if err == nil {
+						break
+					}
+					log.ErrorPrint("rewatch registry fail for %v", err)
+				}
+				continue
+			}
 			if we.Err != nil {
-				beego.Error(we.Err)
+				log.ErrorPrint(we.Err)
 				time.Sleep(1 * time.Second)
 				continue
 			}
-
-			res := we
-			if res.Node.Key == etcdRegistryKey {
+			if we.Node == nil || we.Node.Key == etcdRegistryKey {
 				continue
 			}
 
-			remain := strings.TrimPrefix(res.Node.Key, etcdRegistryKey+"/")
-			s := strings.Split(remain, "/")
-			var r *Registry
-			//registry
-			group := s[0]
-			if len(s) == 2 {
-				value := res.Node.Value
-				err := json.Unmarshal([]byte(value), r)
-				if err != nil {
-					beego.Error(err)
-					continue
-				}
+			event, err := getRegistryEvent(we)
+			if err != nil {
+				log.ErrorPrint(err)
+				continue
 			}
-
-			var event RegistryEvent
-			event.Action = res.Action
-			event.Group = group
-			event.Registry = r
-
-			for _, v := range registryNoticers {
-				go func(c chan RegistryEvent) {
-					v <- event
-				}(v)
+			if event == nil {
+				continue
 			}
 
+			notifyRegistryNoticers(*event)
 		}
 	}()
 
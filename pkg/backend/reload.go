@@ -0,0 +1,47 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdReloadPolicyKey = "/ufleet/deploy/reloadpolicy"
+)
+
+//应用的配置重载策略,开启后应用中的ConfigMap/Secret更新时会滚动重启引用它们的工作负载
+type ReloadPolicy struct {
+	Group     string `json:"group"`
+	Workspace string `json:"workspace"`
+	App       string `json:"app"`
+	Enabled   bool   `json:"enabled"`
+}
+
+func reloadPolicyKey(group, workspace, app string) string {
+	return etcdReloadPolicyKey + "/" + group + "/" + workspace + "/" + app
+}
+
+//没有设置策略时返回关闭的策略
+func GetAppReloadPolicy(group, workspace, app string) (*ReloadPolicy, error) {
+	rp := ReloadPolicy{Group: group, Workspace: workspace, App: app}
+
+	node, err := kv.Store.GetNode(reloadPolicyKey(group, workspace, app))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return &rp, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &rp)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return &rp, nil
+}
+
+func SetAppReloadPolicy(rp ReloadPolicy) error {
+	return kv.Store.UpdateNode(reloadPolicyKey(rp.Group, rp.Workspace, rp.App), rp)
+}
@@ -0,0 +1,46 @@
+package backend
+
+import (
+	"encoding/json"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdRestartKey = "/ufleet/deploy/restart"
+)
+
+func restartKey(group, kind, workspace, name string) string {
+	return etcdRestartKey + "/" + group + "/" + workspace + "/" + kind + "/" + name
+}
+
+//工作负载最近一次重启的进度,保存在etcd中以便各副本都能查询
+//没有记录时返回false
+func GetRestartProgress(group, kind, workspace, name string, progress interface{}) (bool, error) {
+	node, err := kv.Store.GetNode(restartKey(group, kind, workspace, name))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return false, nil
+		}
+		return false, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), progress)
+	if err != nil {
+		return false, log.DebugPrint(err)
+	}
+	return true, nil
+}
+
+func SetRestartProgress(group, kind, workspace, name string, progress interface{}) error {
+	return kv.Store.UpdateNode(restartKey(group, kind, workspace, name), progress)
+}
+
+func DeleteRestartProgress(group, kind, workspace, name string) error {
+	err := kv.Store.DeleteNode(restartKey(group, kind, workspace, name))
+	if err != nil && err != kv.ErrKeyNotFound {
+		return err
+	}
+	return nil
+}
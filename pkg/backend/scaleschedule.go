@@ -0,0 +1,155 @@
+package backend
+
+import (
+	"encoding/json"
+	"sync"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdScaleScheduleKey = "/ufleet/deploy/scaleschedule"
+	etcdScaleHistoryKey  = "/ufleet/deploy/scalehistory"
+
+	//每个组最多保留的定时伸缩执行记录数
+	MaxScaleHistory = 500
+)
+
+//定时伸缩规则,在cron表达式指定的时间将副本数设为Replicas
+type ScaleRule struct {
+	Schedule    string `json:"schedule"`
+	Replicas    int    `json:"replicas"`
+	MinReplicas int    `json:"minreplicas"` //大于0时同时修改弹性伸缩的最小副本数
+	MaxReplicas int    `json:"maxreplicas"` //大于0时同时修改弹性伸缩的最大副本数
+}
+
+//工作负载的定时伸缩策略
+type ScaleSchedule struct {
+	Kind      string      `json:"kind"`
+	Workspace string      `json:"workspace"`
+	Name      string      `json:"name"`
+	Enabled   bool        `json:"enabled"`
+	Timezone  string      `json:"timezone"` //IANA时区名,为空时使用服务所在时区
+	Rules     []ScaleRule `json:"rules"`
+}
+
+//定时伸缩的一次执行记录
+type ScaleExecution struct {
+	Time        int64  `json:"time"`
+	Kind        string `json:"kind"`
+	Workspace   string `json:"workspace"`
+	Name        string `json:"name"`
+	Schedule    string `json:"schedule"`
+	Replicas    int    `json:"replicas"` //规则中的副本数
+	OldReplicas int    `json:"oldreplicas"`
+	NewReplicas int    `json:"newreplicas"`
+	Success     bool   `json:"success"`
+	Message     string `json:"message"`
+}
+
+//同一组的策略保存在一个节点中,修改时加锁
+var scaleScheduleLock sync.Mutex
+
+func scaleScheduleKey(group string) string {
+	return etcdScaleScheduleKey + "/" + group
+}
+
+func scaleHistoryKey(group string) string {
+	return etcdScaleHistoryKey + "/" + group
+}
+
+//组下所有工作负载的定时伸缩策略
+func GetScaleSchedules(group string) ([]ScaleSchedule, error) {
+	ss := make([]ScaleSchedule, 0)
+	node, err := kv.Store.GetNode(scaleScheduleKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return ss, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &ss)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return ss, nil
+}
+
+func SetScaleSchedules(group string, ss []ScaleSchedule) error {
+	return kv.Store.UpdateNode(scaleScheduleKey(group), ss)
+}
+
+func (ss ScaleSchedule) target(kind, workspace, name string) bool {
+	return ss.Kind == kind && ss.Workspace == workspace && ss.Name == name
+}
+
+//添加或替换工作负载的定时伸缩策略
+func SetScaleSchedule(group string, ss ScaleSchedule) error {
+	scaleScheduleLock.Lock()
+	defer scaleScheduleLock.Unlock()
+	sss, err := GetScaleSchedules(group)
+	if err != nil {
+		return err
+	}
+	for k, v := range sss {
+		if v.target(ss.Kind, ss.Workspace, ss.Name) {
+			sss[k] = ss
+			return SetScaleSchedules(group, sss)
+		}
+	}
+	return SetScaleSchedules(group, append(sss, ss))
+}
+
+//删除工作负载的定时伸缩策略,工作负载删除时也需要调用
+func DeleteScaleSchedule(group, kind, workspace, name string) error {
+	scaleScheduleLock.Lock()
+	defer scaleScheduleLock.Unlock()
+	sss, err := GetScaleSchedules(group)
+	if err != nil {
+		return err
+	}
+	result := make([]ScaleSchedule, 0, len(sss))
+	for _, v := range sss {
+		if !v.target(kind, workspace, name) {
+			result = append(result, v)
+		}
+	}
+	if len(result) == len(sss) {
+		return nil
+	}
+	return SetScaleSchedules(group, result)
+}
+
+//按时间从旧到新返回
+func GetScaleHistory(group string) ([]ScaleExecution, error) {
+	es := make([]ScaleExecution, 0)
+	node, err := kv.Store.GetNode(scaleHistoryKey(group))
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return es, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &es)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return es, nil
+}
+
+//追加执行记录,超过MaxScaleHistory时删除最旧的记录
+//调用者需要保证同一组不会并发追加
+func AppendScaleHistory(group string, executions ...ScaleExecution) error {
+	es, err := GetScaleHistory(group)
+	if err != nil {
+		return err
+	}
+	es = append(es, executions...)
+	if len(es) > MaxScaleHistory {
+		es = es[len(es)-MaxScaleHistory:]
+	}
+	return kv.Store.UpdateNode(scaleHistoryKey(group), es)
+}
@@ -0,0 +1,65 @@
+package backend
+
+import (
+	"encoding/json"
+	"fmt"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	//不放在etcdUfleetKey下,避免触发资源事件
+	etcdUsageRetentionKey = "/ufleet/deploy/usageretention"
+
+	defaultRawRetention        = 24
+	defaultTenMinutesRetention = 24 * 7
+	defaultHourlyRetention     = 24 * 90
+)
+
+//资源使用历史的保留时间,单位为小时
+type UsageRetention struct {
+	Raw        int64 `json:"raw"`        //原始采样
+	TenMinutes int64 `json:"tenminutes"` //10分钟精度
+	Hourly     int64 `json:"hourly"`     //1小时精度
+}
+
+func (ur UsageRetention) Validate() error {
+	if ur.Raw <= 0 || ur.TenMinutes <= 0 || ur.Hourly <= 0 {
+		return fmt.Errorf("retention must be greater than 0")
+	}
+	if ur.Raw > ur.TenMinutes || ur.TenMinutes > ur.Hourly {
+		return fmt.Errorf("retention of coarser resolution must be longer")
+	}
+	return nil
+}
+
+//没有设置时返回默认的保留时间
+func GetUsageRetention() (*UsageRetention, error) {
+	ur := UsageRetention{
+		Raw:        defaultRawRetention,
+		TenMinutes: defaultTenMinutesRetention,
+		Hourly:     defaultHourlyRetention,
+	}
+
+	node, err := kv.Store.GetNode(etcdUsageRetentionKey)
+	if err != nil {
+		if err == kv.ErrKeyNotFound {
+			return &ur, nil
+		}
+		return nil, log.DebugPrint(err)
+	}
+
+	err = json.Unmarshal([]byte(node.Value), &ur)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	return &ur, nil
+}
+
+func SetUsageRetention(ur UsageRetention) error {
+	err := ur.Validate()
+	if err != nil {
+		return err
+	}
+	return kv.Store.UpdateNode(etcdUsageRetentionKey, ur)
+}
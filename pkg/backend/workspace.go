@@ -22,11 +22,82 @@ var (
 	workspaceNoticers = make(map[string]chan WorkspaceEvent)
 	workspaceLock     = sync.Mutex{}
 	workspaceBE       = NewBackendHandler()
+
+	//工作区配额缓存,key为group/workspace
+	workspaceQuotas = make(map[string]Workspace)
+	//etcd中工作区的key对应的缓存key,删除事件中没有value,用它确定要删除的配额
+	workspaceQuotaKeys = make(map[string]string)
+	workspaceQuotaLock = sync.Mutex{}
+	ErrQuotaNotFound   = fmt.Errorf("workspace quota not found")
 )
 
+//cpu单位为核,c_mem_*/pod_mem_*单位为MB,mem单位为GB
+//值为0表示没有限制
 type Workspace struct {
 	Group     string `json:"group"`
 	Workspace string `json:"name"`
+
+	ContainerCPUMax     float64 `json:"c_cpu_max"`
+	ContainerMemMax     float64 `json:"c_mem_max"`
+	ContainerCPUDefault float64 `json:"c_cpu_default"`
+	ContainerMemDefault float64 `json:"c_mem_default"`
+	PodCPUMax           float64 `json:"pod_cpu_max"`
+	PodMemMax           float64 `json:"pod_mem_max"`
+	CPU                 float64 `json:"cpu"`
+	Mem                 float64 `json:"mem"`
+}
+
+func workspaceQuotaKey(group, workspace string) string {
+	return group + "/" + workspace
+}
+
+//获取工作区的配额
+func GetWorkspaceQuota(group, workspace string) (*Workspace, error) {
+	workspaceQuotaLock.Lock()
+	defer workspaceQuotaLock.Unlock()
+
+	w, ok := workspaceQuotas[workspaceQuotaKey(group, workspace)]
+	if !ok {
+		return nil, ErrQuotaNotFound
+	}
+	return &w, nil
+}
+
+//key为工作区在etcd中的key
+func setWorkspaceQuota(key string, w Workspace) {
+	workspaceQuotaLock.Lock()
+	defer workspaceQuotaLock.Unlock()
+	workspaceQuotas[workspaceQuotaKey(w.Group, w.Workspace)] = w
+	workspaceQuotaKeys[key] = workspaceQuotaKey(w.Group, w.Workspace)
+}
+
+//删除事件中没有value,根据创建时记录的组和工作区只删除这一个配额
+func deleteWorkspaceQuota(key string) {
+	workspaceQuotaLock.Lock()
+	defer workspaceQuotaLock.Unlock()
+	qk, ok := workspaceQuotaKeys[key]
+	if !ok {
+		return
+	}
+	delete(workspaceQuotas, qk)
+	delete(workspaceQuotaKeys, key)
+}
+
+func loadWorkspaceQuotas() error {
+	resp, err := kv.Store.GetChildNode(externalWorkspaceKey)
+	if err != nil && err != kv.ErrKeyNotFound {
+		return log.DebugPrint(err)
+	}
+
+	for _, v := range resp {
+		var w Workspace
+		err := json.Unmarshal([]byte(v.Value), &w)
+		if err != nil {
+			return log.DebugPrint(err)
+		}
+		setWorkspaceQuota(v.Key, w)
+	}
+	return nil
 }
 
 func RegisterWorkspaceNoticer(kind string) (chan WorkspaceEvent, error) {
@@ -187,6 +258,7 @@ func watchWorkspaceChange() error {
 			case kv.ActionDelete:
 				action = res.Action
 				//				value = res.PrevNode.Value
+				deleteWorkspaceQuota(res.Node.Key)
 			}
 
 			var w Workspace
@@ -195,6 +267,9 @@ func watchWorkspaceChange() error {
 				beego.Error("cannot unmarshal value '%v' for ", value, err)
 				continue
 			}
+			if action == kv.ActionCreate {
+				setWorkspaceQuota(res.Node.Key, w)
+			}
 			var event WorkspaceEvent
 			event.Action = action
 			event.Group = w.Group
@@ -0,0 +1,838 @@
+package canary
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
+	"ufleet-deploy/pkg/resource"
+	pk "ufleet-deploy/pkg/resource/deployment"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	StrategyReplicas = "replicas" //金丝雀Pod带有稳定版本Pod的全部标签,共用Service,按副本数比例分配流量
+	StrategyIngress  = "ingress"  //金丝雀Pod由单独的Service选择,通过nginx Ingress的canary-weight注解分配流量
+
+	PhaseRunning   = "Running"   //当前步骤到期且分析通过后自动进入下一步
+	PhasePaused    = "Paused"    //等待手动推进
+	PhasePromoting = "Promoting" //稳定版本正在升级到金丝雀的模板
+	PhasePromoted  = "Promoted"
+	PhaseAborted   = "Aborted"
+	PhaseFailed    = "Failed" //稳定版本升级失败或者被删除,金丝雀版本已清理
+
+	intervalEnvKey  = "CANARY_INTERVAL"
+	defaultInterval = 15 * time.Second
+	leaderName      = "canary"
+
+	canarySuffix = "-canary"
+
+	deploymentAPIVersion = "extensions/v1beta1"
+
+	//nginx Ingress控制器的金丝雀注解,没有指定ingress class时认为是nginx
+	ingressClassKey      = "kubernetes.io/ingress.class"
+	nginxIngressClass    = "nginx"
+	nginxCanaryKey       = "nginx.ingress.kubernetes.io/canary"
+	nginxCanaryWeightKey = "nginx.ingress.kubernetes.io/canary-weight"
+
+	systemOperator     = "system"
+	operatePromote     = "promote canary"
+	operateAutoPromote = "auto promote canary"
+)
+
+var (
+	//金丝雀发布按组保存,修改时加锁
+	locker sync.Mutex
+	leader *kv.Leader
+)
+
+//创建金丝雀发布的参数,Template只使用其中的Spec,标签和注解沿用稳定版本
+type CreateOption struct {
+	Template *corev1.PodTemplateSpec `json:"template"`
+	Strategy string                  `json:"strategy"` //默认replicas
+	Ingress  string                  `json:"ingress"`  //ingress方式时稳定版本的Ingress
+	Steps    []backend.CanaryStep    `json:"steps"`
+	Analysis backend.CanaryAnalysis  `json:"analysis"`
+
+	User string `json:"-"`
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		log.ErrorPrint("invalid %v '%v', use default %v", key, v, def)
+		return def
+	}
+	return d
+}
+
+//定时推进到期的步骤,检查金丝雀Pod并完成推广
+//多副本部署时只有leader执行
+func Init() {
+	leader = kv.NewLeader(leaderName)
+	go loop(durationEnv(intervalEnvKey, defaultInterval))
+}
+
+func IsActive(c backend.Canary) bool {
+	switch c.Phase {
+	case PhaseRunning, PhasePaused, PhasePromoting:
+		return true
+	}
+	return false
+}
+
+//replicas方式的金丝雀发布进行中时稳定版本的副本数由金丝雀发布控制,
+//定时伸缩和弹性伸缩不能修改,否则会被恢复副本数时覆盖
+func CheckScalable(group, workspace, name string) error {
+	cs, err := backend.GetCanaries(group)
+	if err != nil {
+		return err
+	}
+	i := find(cs, workspace, name)
+	if i >= 0 && IsActive(cs[i]) && cs[i].Strategy == StrategyReplicas {
+		return fmt.Errorf("deployment '%v' has a canary in phase %v, scaling is paused", name, cs[i].Phase)
+	}
+	return nil
+}
+
+func (opt *CreateOption) validate() error {
+	if opt.Template == nil || len(opt.Template.Spec.Containers) == 0 {
+		return fmt.Errorf("must commit pod template of canary")
+	}
+	switch opt.Strategy {
+	case "":
+		opt.Strategy = StrategyReplicas
+	case StrategyReplicas:
+	case StrategyIngress:
+		if opt.Ingress == "" {
+			return fmt.Errorf("must specify ingress for strategy '%v'", StrategyIngress)
+		}
+	default:
+		return fmt.Errorf("invalid strategy '%v'", opt.Strategy)
+	}
+	if len(opt.Steps) == 0 {
+		return fmt.Errorf("must specify at least one step")
+	}
+	last := 0
+	for _, s := range opt.Steps {
+		if s.Weight < 1 || s.Weight > 100 {
+			return fmt.Errorf("weight of step must be in [1,100]")
+		}
+		if s.Weight < last {
+			return fmt.Errorf("weight of steps must not decrease")
+		}
+		if s.Pause < 0 {
+			return fmt.Errorf("pause of step must not be negative")
+		}
+		last = s.Weight
+	}
+	a := opt.Analysis
+	if a.MaxRestarts < 0 || a.MaxCPU < 0 {
+		return fmt.Errorf("analysis thresholds must not be negative")
+	}
+	return nil
+}
+
+func find(cs []backend.Canary, workspace, name string) int {
+	for k, v := range cs {
+		if v.Workspace == workspace && v.Name == name {
+			return k
+		}
+	}
+	return -1
+}
+
+//Deployment最近一次金丝雀发布
+func Get(group, workspace, name string) (*backend.Canary, error) {
+	cs, err := backend.GetCanaries(group)
+	if err != nil {
+		return nil, err
+	}
+	i := find(cs, workspace, name)
+	if i < 0 {
+		return nil, fmt.Errorf("no canary of deployment '%v' found", name)
+	}
+	return &cs[i], nil
+}
+
+//加锁读取组下的金丝雀发布,fn修改后保存;fn出错时也保存,因为可能已经做了部分修改
+func update(group, workspace, name string, fn func(c *backend.Canary) error) (*backend.Canary, error) {
+	locker.Lock()
+	defer locker.Unlock()
+	cs, err := backend.GetCanaries(group)
+	if err != nil {
+		return nil, err
+	}
+	i := find(cs, workspace, name)
+	if i < 0 {
+		return nil, fmt.Errorf("no canary of deployment '%v' found", name)
+	}
+	ferr := fn(&cs[i])
+	err = backend.SetCanaries(group, cs)
+	if err != nil {
+		return nil, err
+	}
+	if ferr != nil {
+		return nil, ferr
+	}
+	return &cs[i], nil
+}
+
+//在稳定版本旁边创建金丝雀版本的Deployment,并进入第一个步骤
+func Create(group, workspace, name string, opt CreateOption) (*backend.Canary, error) {
+	err := opt.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+	cs, err := backend.GetCanaries(group)
+	if err != nil {
+		return nil, err
+	}
+	i := find(cs, workspace, name)
+	if i >= 0 && IsActive(cs[i]) {
+		return nil, fmt.Errorf("deployment '%v' already has a canary in phase %v", name, cs[i].Phase)
+	}
+
+	obj, err := pk.Controller.GetObject(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	di, err := pk.GetDeploymentInterface(obj)
+	if err != nil {
+		return nil, err
+	}
+	if opt.Strategy == StrategyReplicas && di.Info().AutoScaler.Deployed {
+		return nil, fmt.Errorf("deployment '%v' has autoscale, stop it or use strategy '%v'", name, StrategyIngress)
+	}
+	dh, err := cluster.NewDeploymentHandler(group, workspace)
+	if err != nil {
+		return nil, err
+	}
+	stable, err := dh.Get(workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	if stable.Labels[sign.SignUfleetCanary] != "" {
+		return nil, fmt.Errorf("deployment '%v' is a canary", name)
+	}
+
+	c := backend.Canary{
+		Workspace:      workspace,
+		Name:           name,
+		CanaryName:     name + canarySuffix,
+		Strategy:       opt.Strategy,
+		Steps:          opt.Steps,
+		Analysis:       opt.Analysis,
+		StableReplicas: 1,
+		User:           opt.User,
+		CreateTime:     time.Now().Unix(),
+	}
+	if stable.Spec.Replicas != nil {
+		c.StableReplicas = int(*stable.Spec.Replicas)
+	}
+
+	//ingress方式时,改变稳定版本Service选择的标签的值,使稳定版本的Service不选择金丝雀Pod
+	var svc *corev1.Service
+	var ing *extensionsv1beta1.Ingress
+	if c.Strategy == StrategyIngress {
+		svc, ing, err = stableIngressBackend(group, workspace, stable, opt.Ingress)
+		if err != nil {
+			return nil, err
+		}
+		c.Ingress = ing.Name
+	}
+
+	err = createCanaryDeployment(group, &c, stable, opt, svc)
+	if err != nil {
+		return nil, err
+	}
+	if c.Strategy == StrategyIngress {
+		err = createCanaryIngress(group, &c, svc, ing)
+		if err != nil {
+			cleanup(group, &c)
+			return nil, err
+		}
+	}
+
+	err = enterStep(group, &c, 0)
+	if err != nil {
+		restoreStable(group, &c)
+		cleanup(group, &c)
+		return nil, err
+	}
+
+	if i >= 0 {
+		cs[i] = c
+	} else {
+		cs = append(cs, c)
+	}
+	err = backend.SetCanaries(group, cs)
+	if err != nil {
+		restoreStable(group, &c)
+		cleanup(group, &c)
+		return nil, err
+	}
+	return &c, nil
+}
+
+//Ingress中指向稳定版本Pod的Service,只支持nginx Ingress控制器
+func stableIngressBackend(group, workspace string, stable *extensionsv1beta1.Deployment, name string) (*corev1.Service, *extensionsv1beta1.Ingress, error) {
+	ih, err := cluster.NewIngressHandler(group, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	ing, err := ih.Get(workspace, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	class := ing.Annotations[ingressClassKey]
+	if class != "" && class != nginxIngressClass {
+		return nil, nil, fmt.Errorf("ingress class '%v' doesn't support weighted canary, use strategy '%v'", class, StrategyReplicas)
+	}
+	if ing.Annotations[nginxCanaryKey] == "true" {
+		return nil, nil, fmt.Errorf("ingress '%v' is a canary", name)
+	}
+
+	dh, err := cluster.NewDeploymentHandler(group, workspace)
+	if err != nil {
+		return nil, nil, err
+	}
+	svcs, err := dh.GetServices(workspace, stable.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, s := range svcs {
+		for _, b := range ingressBackends(ing) {
+			if b.ServiceName == s.Name {
+				return s, ing, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("ingress '%v' has no backend service of deployment '%v'", name, stable.Name)
+}
+
+func ingressBackends(ing *extensionsv1beta1.Ingress) []*extensionsv1beta1.IngressBackend {
+	bs := make([]*extensionsv1beta1.IngressBackend, 0)
+	if ing.Spec.Backend != nil {
+		bs = append(bs, ing.Spec.Backend)
+	}
+	for i := range ing.Spec.Rules {
+		r := &ing.Spec.Rules[i]
+		if r.HTTP == nil {
+			continue
+		}
+		for j := range r.HTTP.Paths {
+			bs = append(bs, &r.HTTP.Paths[j].Backend)
+		}
+	}
+	return bs
+}
+
+func copyMap(m map[string]string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+//金丝雀Pod的标签: replicas方式为稳定版本的标签加上金丝雀标记;
+//ingress方式还把稳定版本Service选择的标签的值加上-canary后缀
+func canaryLabels(c *backend.Canary, stable *extensionsv1beta1.Deployment, svc *corev1.Service) map[string]string {
+	labels := copyMap(stable.Spec.Template.Labels)
+	if svc != nil {
+		for k, v := range svc.Spec.Selector {
+			labels[k] = v + canarySuffix
+		}
+	}
+	labels[sign.SignUfleetCanary] = c.Name
+	return labels
+}
+
+//通过Deployment的资源控制器创建,以经过配额检查并记录到etcd中
+func createCanaryDeployment(group string, c *backend.Canary, stable *extensionsv1beta1.Deployment, opt CreateOption, svc *corev1.Service) error {
+	tpl := stable.Spec.Template.DeepCopy()
+	tpl.Spec = *opt.Template.Spec.DeepCopy()
+	tpl.Labels = canaryLabels(c, stable, svc)
+	if tpl.Annotations == nil {
+		tpl.Annotations = make(map[string]string)
+	}
+	for k, v := range opt.Template.Annotations {
+		tpl.Annotations[k] = v
+	}
+	//不属于稳定版本的应用,也不沿用稳定版本的修改记录
+	delete(tpl.Annotations, sign.SignUfleetAppKey)
+	delete(tpl.Annotations, sign.SignChangeCause)
+	delete(tpl.Annotations, sign.SignUfleetChangeUser)
+	delete(tpl.Annotations, sign.SignUfleetChangeComment)
+
+	labels := copyMap(stable.Labels)
+	labels[sign.SignUfleetCanary] = c.Name
+	var replicas int32
+	d := extensionsv1beta1.Deployment{
+		TypeMeta: metav1.TypeMeta{Kind: workload.KindDeployment, APIVersion: deploymentAPIVersion},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      c.CanaryName,
+			Namespace: c.Workspace,
+			Labels:    labels,
+		},
+		Spec: extensionsv1beta1.DeploymentSpec{
+			Replicas:                &replicas,
+			Selector:                &metav1.LabelSelector{MatchLabels: copyMap(tpl.Labels)},
+			Template:                *tpl,
+			Strategy:                stable.Spec.Strategy,
+			MinReadySeconds:         stable.Spec.MinReadySeconds,
+			ProgressDeadlineSeconds: stable.Spec.ProgressDeadlineSeconds,
+		},
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	return pk.Controller.CreateObject(group, c.Workspace, data, resource.CreateOption{User: c.User, Comment: "canary of " + c.Name})
+}
+
+//复制稳定版本的Service和Ingress,Ingress只保留指向稳定版本Service的路径
+func createCanaryIngress(group string, c *backend.Canary, svc *corev1.Service, ing *extensionsv1beta1.Ingress) error {
+	sh, err := cluster.NewServiceHandler(group, c.Workspace)
+	if err != nil {
+		return err
+	}
+	csvc := corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svc.Name + canarySuffix,
+			Namespace: c.Workspace,
+			Labels:    map[string]string{sign.SignUfleetCanary: c.Name},
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: make(map[string]string),
+		},
+	}
+	for k, v := range svc.Spec.Selector {
+		csvc.Spec.Selector[k] = v + canarySuffix
+	}
+	for _, p := range svc.Spec.Ports {
+		p.NodePort = 0
+		csvc.Spec.Ports = append(csvc.Spec.Ports, p)
+	}
+	err = sh.Create(c.Workspace, &csvc)
+	if err != nil {
+		return err
+	}
+	c.CanaryService = csvc.Name
+
+	cing := extensionsv1beta1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ing.Name + canarySuffix,
+			Namespace:   c.Workspace,
+			Labels:      map[string]string{sign.SignUfleetCanary: c.Name},
+			Annotations: copyMap(ing.Annotations),
+		},
+	}
+	delete(cing.Annotations, sign.SignFromUfleetKey)
+	delete(cing.Annotations, sign.SignUfleetAppKey)
+	cing.Annotations[nginxCanaryKey] = "true"
+	cing.Annotations[nginxCanaryWeightKey] = "0"
+	cing.Spec.TLS = ing.Spec.TLS
+	if ing.Spec.Backend != nil && ing.Spec.Backend.ServiceName == svc.Name {
+		b := *ing.Spec.Backend
+		b.ServiceName = csvc.Name
+		cing.Spec.Backend = &b
+	}
+	for _, r := range ing.Spec.Rules {
+		if r.HTTP == nil {
+			continue
+		}
+		paths := make([]extensionsv1beta1.HTTPIngressPath, 0)
+		for _, p := range r.HTTP.Paths {
+			if p.Backend.ServiceName == svc.Name {
+				p.Backend.ServiceName = csvc.Name
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		r.HTTP = &extensionsv1beta1.HTTPIngressRuleValue{Paths: paths}
+		cing.Spec.Rules = append(cing.Spec.Rules, r)
+	}
+
+	ih, err := cluster.NewIngressHandler(group, c.Workspace)
+	if err != nil {
+		return err
+	}
+	err = ih.Create(c.Workspace, &cing)
+	if err != nil {
+		return err
+	}
+	c.CanaryIngress = cing.Name
+	return nil
+}
+
+//按权重计算的金丝雀副本数,至少为1
+func canaryReplicas(total, weight int) int {
+	n := (total*weight + 99) / 100
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+//replicas方式下副本数较少时比例只能近似,权重小于100时稳定版本至少保留1个副本
+func applyWeight(group string, c *backend.Canary, weight int) error {
+	n := canaryReplicas(c.StableReplicas, weight)
+	err := workload.Scale(workload.KindDeployment, group, c.Workspace, c.CanaryName, n)
+	if err != nil {
+		return err
+	}
+
+	if c.Strategy == StrategyReplicas {
+		stable := c.StableReplicas - n
+		if stable < 1 && weight < 100 {
+			stable = 1
+		}
+		if stable < 0 {
+			stable = 0
+		}
+		err = workload.Scale(workload.KindDeployment, group, c.Workspace, c.Name, stable)
+		if err != nil {
+			return err
+		}
+	} else {
+		err = setIngressWeight(group, c, weight)
+		if err != nil {
+			return err
+		}
+	}
+	c.Weight = weight
+	return nil
+}
+
+func setIngressWeight(group string, c *backend.Canary, weight int) error {
+	ih, err := cluster.NewIngressHandler(group, c.Workspace)
+	if err != nil {
+		return err
+	}
+	ing, err := ih.Get(c.Workspace, c.CanaryIngress)
+	if err != nil {
+		return err
+	}
+	ing = ing.DeepCopy()
+	if ing.Annotations == nil {
+		ing.Annotations = make(map[string]string)
+	}
+	ing.Annotations[nginxCanaryWeightKey] = strconv.Itoa(weight)
+	return ih.Update(c.Workspace, ing)
+}
+
+func enterStep(group string, c *backend.Canary, step int) error {
+	s := c.Steps[step]
+	err := applyWeight(group, c, s.Weight)
+	if err != nil {
+		return err
+	}
+	c.Step = step
+	c.StepTime = time.Now().Unix()
+	c.Phase = PhaseRunning
+	if s.Pause == 0 {
+		c.Phase = PhasePaused
+	}
+	c.Message = fmt.Sprintf("step %d of %d, canary weight %d%%", step+1, len(c.Steps), s.Weight)
+	return nil
+}
+
+//进入下一步,已经是最后一步时推广金丝雀版本
+func next(group string, c *backend.Canary, opt resource.UpdateOption) error {
+	if c.Phase != PhaseRunning && c.Phase != PhasePaused {
+		return fmt.Errorf("canary of deployment '%v' is %v", c.Name, c.Phase)
+	}
+	if c.Step+1 >= len(c.Steps) {
+		return promote(group, c, opt)
+	}
+	return enterStep(group, c, c.Step+1)
+}
+
+//把金丝雀版本的Pod模板合并到稳定版本并恢复稳定版本的副本数,
+//稳定版本升级完成后再删除金丝雀版本
+func promote(group string, c *backend.Canary, opt resource.UpdateOption) error {
+	if c.Phase != PhaseRunning && c.Phase != PhasePaused {
+		return fmt.Errorf("canary of deployment '%v' is %v", c.Name, c.Phase)
+	}
+	dh, err := cluster.NewDeploymentHandler(group, c.Workspace)
+	if err != nil {
+		return err
+	}
+	cd, err := dh.Get(c.Workspace, c.CanaryName)
+	if err != nil {
+		return err
+	}
+	spec := cd.Spec.Template.Spec.DeepCopy()
+	err = workload.UpdatePodTemplate(workload.KindDeployment, group, c.Workspace, c.Name, func(tpl *corev1.PodTemplateSpec) error {
+		tpl.Spec = *spec
+		return nil
+	}, opt)
+	if err != nil {
+		return err
+	}
+	if c.Strategy == StrategyReplicas {
+		err = workload.Scale(workload.KindDeployment, group, c.Workspace, c.Name, c.StableReplicas)
+		if err != nil {
+			return err
+		}
+	}
+	c.Phase = PhasePromoting
+	c.Message = "waiting for stable deployment to roll out"
+	return nil
+}
+
+//恢复稳定版本的副本数,ingress方式时副本数没有修改
+func restoreStable(group string, c *backend.Canary) error {
+	if c.Strategy != StrategyReplicas {
+		return nil
+	}
+	return workload.Scale(workload.KindDeployment, group, c.Workspace, c.Name, c.StableReplicas)
+}
+
+//删除金丝雀版本的Ingress,Service和Deployment,已经不存在的忽略
+func cleanup(group string, c *backend.Canary) error {
+	if c.CanaryIngress != "" {
+		ih, err := cluster.NewIngressHandler(group, c.Workspace)
+		if err != nil {
+			return err
+		}
+		err = ih.Delete(c.Workspace, c.CanaryIngress)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	if c.CanaryService != "" {
+		sh, err := cluster.NewServiceHandler(group, c.Workspace)
+		if err != nil {
+			return err
+		}
+		err = sh.Delete(c.Workspace, c.CanaryService)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	err := pk.Controller.DeleteObject(group, c.Workspace, c.CanaryName, resource.DeleteOption{DontCallApp: true})
+	if err != nil && err != resource.ErrResourceNotFound {
+		return err
+	}
+	return nil
+}
+
+func abort(group string, c *backend.Canary, reason string) error {
+	if !IsActive(*c) {
+		return fmt.Errorf("canary of deployment '%v' is %v", c.Name, c.Phase)
+	}
+	err := restoreStable(group, c)
+	if err != nil {
+		return err
+	}
+	err = cleanup(group, c)
+	if err != nil {
+		return err
+	}
+	c.Phase = PhaseAborted
+	c.Message = reason
+	c.FinishTime = time.Now().Unix()
+	return nil
+}
+
+func Next(group, workspace, name string, opt resource.UpdateOption) (*backend.Canary, error) {
+	return update(group, workspace, name, func(c *backend.Canary) error {
+		return next(group, c, opt)
+	})
+}
+
+func Promote(group, workspace, name string, opt resource.UpdateOption) (*backend.Canary, error) {
+	if opt.Operation == "" {
+		opt.Operation = operatePromote
+	}
+	return update(group, workspace, name, func(c *backend.Canary) error {
+		return promote(group, c, opt)
+	})
+}
+
+//推广过程中中止时,稳定版本已经修改的模板不会恢复
+func Abort(group, workspace, name, user string) (*backend.Canary, error) {
+	return update(group, workspace, name, func(c *backend.Canary) error {
+		return abort(group, c, fmt.Sprintf("aborted by %v", user))
+	})
+}
+
+func loop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if !leader.IsLeader() {
+			continue
+		}
+		for _, g := range pk.Controller.ListGroups() {
+			checkGroup(g, now)
+		}
+	}
+}
+
+func checkGroup(group string, now time.Time) {
+	cs, err := backend.GetCanaries(group)
+	if err != nil {
+		log.ErrorPrint("get canaries of group '%v' fail: %v", group, err)
+		return
+	}
+	for _, v := range cs {
+		if !IsActive(v) {
+			continue
+		}
+		_, err := update(group, v.Workspace, v.Name, func(c *backend.Canary) error {
+			return check(group, c, now)
+		})
+		if err != nil {
+			log.ErrorPrint("check canary of deployment %v/%v fail: %v", v.Workspace, v.Name, err)
+		}
+	}
+}
+
+//清理金丝雀版本并结束为Failed,清理失败时下次检查重试
+func fail(group string, c *backend.Canary, reason string, now time.Time) error {
+	err := cleanup(group, c)
+	if err != nil {
+		return err
+	}
+	c.Phase = PhaseFailed
+	c.Message = reason
+	c.FinishTime = now.Unix()
+	return nil
+}
+
+//推广中等待稳定版本升级完成;其他阶段检查金丝雀版本,失败时中止,
+//自动推进的步骤到期且分析通过后进入下一步
+//稳定版本被删除或者推广时升级失败,清理金丝雀版本后结束
+func check(group string, c *backend.Canary, now time.Time) error {
+	if !IsActive(*c) {
+		return nil
+	}
+
+	dh, err := cluster.NewDeploymentHandler(group, c.Workspace)
+	if err != nil {
+		return err
+	}
+	_, err = dh.Get(c.Workspace, c.Name)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return fail(group, c, "stable deployment has been deleted", now)
+		}
+		return err
+	}
+
+	if c.Phase == PhasePromoting {
+		s, err := workload.GetRolloutStatus(workload.KindDeployment, group, c.Workspace, c.Name)
+		if err != nil {
+			return err
+		}
+		if s.Failed {
+			return fail(group, c, fmt.Sprintf("stable deployment failed to roll out: %v", s.Message), now)
+		}
+		if !s.Done {
+			return nil
+		}
+		err = cleanup(group, c)
+		if err != nil {
+			return err
+		}
+		c.Phase = PhasePromoted
+		c.Message = "successfully promoted"
+		c.FinishTime = now.Unix()
+		return nil
+	}
+
+	s, err := workload.GetRolloutStatus(workload.KindDeployment, group, c.Workspace, c.CanaryName)
+	if err != nil {
+		return err
+	}
+	if s.Failed {
+		return abort(group, c, fmt.Sprintf("canary failed to roll out: %v", s.Message))
+	}
+	ok, msg, err := analyze(group, c)
+	if err != nil {
+		return abort(group, c, fmt.Sprintf("analysis failed: %v", err))
+	}
+	if c.Phase != PhaseRunning || !s.Done {
+		return nil
+	}
+	if now.Unix()-c.StepTime < int64(c.Steps[c.Step].Pause) {
+		return nil
+	}
+	if !ok {
+		c.Message = msg
+		return nil
+	}
+	return next(group, c, resource.UpdateOption{User: systemOperator, Operation: operateAutoPromote})
+}
+
+//检查金丝雀Pod的重启次数和资源使用;超过阈值时返回错误,
+//暂时没有指标时返回false和原因
+func analyze(group string, c *backend.Canary) (bool, string, error) {
+	a := c.Analysis
+	if a.MaxRestarts > 0 {
+		pods, err := workload.GetPods(workload.KindDeployment, group, c.Workspace, c.CanaryName)
+		if err != nil {
+			return false, "", err
+		}
+		restarts := 0
+		for _, p := range pods {
+			for _, cs := range p.Status.ContainerStatuses {
+				restarts += int(cs.RestartCount)
+			}
+		}
+		if restarts > a.MaxRestarts {
+			return false, "", fmt.Errorf("canary pods restarted %d times, more than %d", restarts, a.MaxRestarts)
+		}
+	}
+	if a.MaxCPU <= 0 && a.MaxMemory == 0 {
+		return true, "", nil
+	}
+
+	ws, err := metrics.WorkloadSeriesOf(workload.KindDeployment, group, c.Workspace, c.CanaryName, metrics.DefaultStep)
+	if err != nil {
+		return false, fmt.Sprintf("waiting for metrics: %v", err), nil
+	}
+	if len(ws.Pods) == 0 || len(ws.Total.Samples) == 0 {
+		return false, "waiting for metrics of running canary pods", nil
+	}
+	//使用最近的采样
+	last := ws.Total.Samples[len(ws.Total.Samples)-1]
+	n := len(ws.Pods)
+	cpu := last.CPU / float64(n)
+	mem := last.Memory / uint64(n)
+	if a.MaxCPU > 0 && cpu > a.MaxCPU {
+		return false, "", fmt.Errorf("canary pods use %.3f cores on average, more than %v", cpu, a.MaxCPU)
+	}
+	if a.MaxMemory > 0 && mem > a.MaxMemory {
+		return false, "", fmt.Errorf("canary pods use %d bytes of memory on average, more than %d", mem, a.MaxMemory)
+	}
+	return true, "", nil
+}
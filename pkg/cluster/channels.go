@@ -1,5 +1,7 @@
 package cluster
 
+import "sync"
+
 const (
 	ActionDelete ActionType = "delete"
 	ActionCreate ActionType = "create"
@@ -38,3 +40,26 @@ type Event struct {
 	Object     interface{}
 	FromUfleet bool //表明该资源由用户直接通过ufleet去创建的
 }
+
+//旁路观察集群事件,不参与资源抽象的构建(如搜索索引)
+//观察者不能阻塞,否则会影响informer的事件分发
+type EventObserver func(e Event)
+
+var (
+	observerLocker sync.Mutex
+	observers      = make(map[string]EventObserver)
+)
+
+func RegisterEventObserver(name string, fn EventObserver) {
+	observerLocker.Lock()
+	defer observerLocker.Unlock()
+	observers[name] = fn
+}
+
+func notifyEventObservers(e Event) {
+	observerLocker.Lock()
+	defer observerLocker.Unlock()
+	for _, fn := range observers {
+		fn(e)
+	}
+}
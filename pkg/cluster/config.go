@@ -114,7 +114,11 @@ type WorkspaceAndConfig struct {
 	Namespace string        `json:"namespace"`
 }
 
-func GetTerminalUrl(group, workspace string, podName string, containerName string, hostIp string, clusterName string, token string) (string, error) {
-	url := hostDomain + "/console/?cluster_name=" + clusterName + "&" + "pod_name=" + podName + "&" + "container_name=" + containerName + "&" + "namespace=" + workspace + "&" + "ip=" + hostIp
-	return url, nil
+//用调用者的token向集群模块获取工作区的集群信息,获取失败说明没有该工作区的权限
+func CheckWorkspacePermission(group, workspace, token string) error {
+	_, err := GetK8sClientConfig(group, workspace, token)
+	if err != nil {
+		return fmt.Errorf("no permission of workspace '%v' in group '%v': %v", workspace, group, err)
+	}
+	return nil
 }
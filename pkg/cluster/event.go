@@ -0,0 +1,50 @@
+package cluster
+
+import (
+	"fmt"
+	"time"
+	"ufleet-deploy/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const eventSource = "ufleet-deploy"
+
+//ufleet自身的操作(如自动回滚)需要在资源上记录事件时使用
+type EventHandler interface {
+	Create(namespace string, involved corev1.ObjectReference, eventType, reason, message string) error
+}
+
+func NewEventHandler(group, workspace string) (EventHandler, error) {
+	Cluster, err := Controller.GetCluster(group, workspace)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	return &eventHandler{Cluster: Cluster}, nil
+}
+
+type eventHandler struct {
+	*Cluster
+}
+
+func (h *eventHandler) Create(namespace string, involved corev1.ObjectReference, eventType, reason, message string) error {
+	now := metav1.NewTime(time.Now())
+	e := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%v.%x", involved.Name, now.UnixNano()),
+			Namespace: namespace,
+		},
+		InvolvedObject: involved,
+		Reason:         reason,
+		Message:        message,
+		Source:         corev1.EventSource{Component: eventSource},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+		Type:           eventType,
+	}
+	_, err := h.clientset.CoreV1().Events(namespace).Create(e)
+	return err
+}
@@ -147,6 +147,7 @@ func (c *ResourceController) resourceAdd(obj interface{}) {
 	}
 
 	e := *ep
+	notifyEventObservers(e)
 	switch obj.(type) {
 	case *corev1.Pod:
 		PodEventChan <- e
@@ -193,6 +194,10 @@ func (c *ResourceController) resourceUpdate(obj, new interface{}) {
 		return
 	}
 	e := *ep
+	//更新事件中携带的是旧对象,观察者需要新对象
+	ne := e
+	ne.Object = new
+	notifyEventObservers(ne)
 
 	switch obj.(type) {
 	case *corev1.Pod:
@@ -241,6 +246,7 @@ func (c *ResourceController) resourceDelete(obj interface{}) {
 	}
 
 	e := *ep
+	notifyEventObservers(e)
 	switch obj.(type) {
 	case *corev1.Pod:
 		PodEventChan <- e
@@ -4,6 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"sort"
 	"strconv"
 	"time"
@@ -36,6 +39,10 @@ import (
 	kubernetesapi "k8s.io/kubernetes/pkg/api"
 	//"k8s.io/kubernetes/pkg/controller"
 	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	"k8s.io/client-go/transport/spdy"
 	deploymentutil "k8s.io/kubernetes/pkg/controller/deployment/util"
 )
 
@@ -56,6 +63,10 @@ type PodHandler interface {
 	Delete(namespace string, name string) error
 	Create(namespace string, pod *corev1.Pod) error
 	Log(namespace, podName string, containerName string, opt LogOption) (string, error)
+	LogStream(namespace, podName string, containerName string, opt LogOption) (io.ReadCloser, error)
+	Exec(namespace, podName string, opt ExecOption) error
+	PortForward(namespace, podName string, port int, stream io.ReadWriter) error
+	Metrics(namespace, podName string) (*PodMetrics, error)
 	Event(namespace, resourceName string) ([]corev1.Event, error)
 	Update(namespace string, pod *corev1.Pod) error
 	List(namespace string) ([]*corev1.Pod, error)
@@ -104,20 +115,40 @@ func (h *podHandler) Delete(namespace, podName string) error {
 }
 
 type LogOption struct {
-	DisplayTailLine int64
+	DisplayTailLine int64 //为0时不限制
 	Timestamps      bool
-	SinceSeconds    int64
+	SinceSeconds    int64 //为0时不限制
+	SinceTime       *time.Time
+	Follow          bool
+	Previous        bool  //上一次终止的容器的日志
+	LimitBytes      int64 //为0时不限制
 }
 
-func (h *podHandler) Log(namespace, podName string, containerName string, opt LogOption) (string, error) {
+func (opt LogOption) podLogOptions(containerName string) *corev1.PodLogOptions {
 	corev1Opt := corev1.PodLogOptions{
-		Container:    containerName,
-		TailLines:    &opt.DisplayTailLine,
-		Timestamps:   opt.Timestamps,
-		SinceSeconds: &opt.SinceSeconds,
+		Container:  containerName,
+		Timestamps: opt.Timestamps,
+		Follow:     opt.Follow,
+		Previous:   opt.Previous,
+	}
+	if opt.DisplayTailLine > 0 {
+		corev1Opt.TailLines = &opt.DisplayTailLine
+	}
+	if opt.SinceSeconds > 0 {
+		corev1Opt.SinceSeconds = &opt.SinceSeconds
+	} else if opt.SinceTime != nil {
+		t := metav1.NewTime(*opt.SinceTime)
+		corev1Opt.SinceTime = &t
+	}
+	if opt.LimitBytes > 0 {
+		corev1Opt.LimitBytes = &opt.LimitBytes
 	}
+	return &corev1Opt
+}
 
-	req := h.clientset.CoreV1().Pods(namespace).GetLogs(podName, &corev1Opt)
+func (h *podHandler) Log(namespace, podName string, containerName string, opt LogOption) (string, error) {
+	opt.Follow = false
+	req := h.clientset.CoreV1().Pods(namespace).GetLogs(podName, opt.podLogOptions(containerName))
 	bc, err := req.Do().Raw()
 	if err != nil {
 		return "", err
@@ -126,6 +157,168 @@ func (h *podHandler) Log(namespace, podName string, containerName string, opt Lo
 	return string(bc), nil
 }
 
+//返回日志流,调用者负责关闭
+func (h *podHandler) LogStream(namespace, podName string, containerName string, opt LogOption) (io.ReadCloser, error) {
+	req := h.clientset.CoreV1().Pods(namespace).GetLogs(podName, opt.podLogOptions(containerName))
+	return req.Stream()
+}
+
+type ExecOption struct {
+	Container string
+	Command   []string
+	Stdin     io.Reader
+	Stdout    io.Writer
+	Stderr    io.Writer //TTY时stderr合并到stdout中
+	TTY       bool
+	SizeQueue remotecommand.TerminalSizeQueue //TTY时终端大小的变化
+}
+
+//通过SPDY在容器中执行命令,直到命令退出或者流被关闭
+func (h *podHandler) Exec(namespace, podName string, opt ExecOption) error {
+	req := h.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec")
+	req.VersionedParams(&corev1.PodExecOptions{
+		Container: opt.Container,
+		Command:   opt.Command,
+		Stdin:     opt.Stdin != nil,
+		Stdout:    opt.Stdout != nil,
+		Stderr:    opt.Stderr != nil && !opt.TTY,
+		TTY:       opt.TTY,
+	}, scheme.ParameterCodec)
+
+	executor, err := remotecommand.NewSPDYExecutor(h.Config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+
+	so := remotecommand.StreamOptions{
+		Stdin:             opt.Stdin,
+		Stdout:            opt.Stdout,
+		Tty:               opt.TTY,
+		TerminalSizeQueue: opt.SizeQueue,
+	}
+	if !opt.TTY {
+		so.Stderr = opt.Stderr
+	}
+	return executor.Stream(so)
+}
+
+const (
+	portForwardProtocolV1Name = "portforward.k8s.io"
+	portForwardDrainTimeout   = 5 * time.Second
+)
+
+//通过SPDY将stream转发到Pod的端口,直到任意一方关闭
+//每次调用对应一个到Pod端口的连接
+func (h *podHandler) PortForward(namespace, podName string, port int, stream io.ReadWriter) error {
+	req := h.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("portforward")
+
+	transport, upgrader, err := spdy.RoundTripperFor(h.Config)
+	if err != nil {
+		return err
+	}
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, "POST", req.URL())
+	conn, _, err := dialer.Dial(portForwardProtocolV1Name)
+	if err != nil {
+		return fmt.Errorf("upgrade connection fail for %v", err)
+	}
+	defer conn.Close()
+
+	headers := http.Header{}
+	headers.Set(corev1.StreamType, corev1.StreamTypeError)
+	headers.Set(corev1.PortHeader, strconv.Itoa(port))
+	headers.Set(corev1.PortForwardRequestIDHeader, "0")
+	errorStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("create error stream fail for %v", err)
+	}
+	//只读取错误流
+	errorStream.Close()
+
+	errorChan := make(chan error, 1)
+	go func() {
+		message, err := ioutil.ReadAll(errorStream)
+		switch {
+		case err != nil:
+			errorChan <- fmt.Errorf("read error stream fail for %v", err)
+		case len(message) > 0:
+			errorChan <- fmt.Errorf("forward port %v fail for %v", port, string(message))
+		}
+		close(errorChan)
+	}()
+
+	headers.Set(corev1.StreamType, corev1.StreamTypeData)
+	dataStream, err := conn.CreateStream(headers)
+	if err != nil {
+		return fmt.Errorf("create data stream fail for %v", err)
+	}
+
+	remoteDone := make(chan struct{})
+	localDone := make(chan struct{})
+	go func() {
+		io.Copy(stream, dataStream)
+		close(remoteDone)
+	}()
+	go func() {
+		//本地关闭后通知Pod
+		defer dataStream.Close()
+		io.Copy(dataStream, stream)
+		close(localDone)
+	}()
+
+	select {
+	case <-remoteDone:
+	case <-localDone:
+		//等待Pod返回剩余的数据
+		select {
+		case <-remoteDone:
+		case <-time.After(portForwardDrainTimeout):
+		}
+	}
+
+	select {
+	case err := <-errorChan:
+		return err
+	case <-time.After(portForwardDrainTimeout):
+		return nil
+	}
+}
+
+//metrics.k8s.io中Pod的资源使用
+type PodMetrics struct {
+	Timestamp  metav1.Time        `json:"timestamp"`
+	Window     metav1.Duration    `json:"window"`
+	Containers []ContainerMetrics `json:"containers"`
+}
+
+type ContainerMetrics struct {
+	Name  string              `json:"name"`
+	Usage corev1.ResourceList `json:"usage"`
+}
+
+//从metrics API(metrics-server)获取Pod的资源使用
+func (h *podHandler) Metrics(namespace, podName string) (*PodMetrics, error) {
+	data, err := h.clientset.CoreV1().RESTClient().Get().
+		AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", namespace, "pods", podName).
+		DoRaw()
+	if err != nil {
+		return nil, err
+	}
+	var pm PodMetrics
+	err = json.Unmarshal(data, &pm)
+	if err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
 func (h *podHandler) Event(namespace, podName string) ([]corev1.Event, error) {
 	//	pod, err := h.clientset.Pods(namespace).Get(podName, metav1.GetOptions{})
 	selector := h.clientset.CoreV1().Events(namespace).GetFieldSelector(&podName, &namespace, nil, nil)
@@ -477,6 +670,7 @@ type ReplicationControllerHandler interface {
 	Delete(namespace string, name string) error
 	GetPods(namespace, name string) ([]*corev1.Pod, error)
 	Update(namespace string, resource *corev1.ReplicationController) error
+	PatchAnnotations(namespace, name string, annotations map[string]string) error
 	Scale(namespace, name string, num int32) error
 	Event(namespace, resourceName string) ([]corev1.Event, error)
 	List(namespace string) ([]*corev1.ReplicationController, error)
@@ -510,6 +704,33 @@ func (h *replicationcontrollerHandler) Update(namespace string, resource *corev1
 	return err
 }
 
+func (h *replicationcontrollerHandler) PatchAnnotations(namespace, name string, annotations map[string]string) error {
+	data, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	_, err = h.clientset.CoreV1().ReplicationControllers(namespace).Patch(name, types.MergePatchType, data)
+	return err
+}
+
+//只修改metadata.annotations的merge patch,值为空时删除该注解
+func annotationsPatch(annotations map[string]string) ([]byte, error) {
+	as := make(map[string]interface{})
+	for k, v := range annotations {
+		if v == "" {
+			as[k] = nil
+			continue
+		}
+		as[k] = v
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": as,
+		},
+	}
+	return json.Marshal(patch)
+}
+
 func (h *replicationcontrollerHandler) Event(namespace, resourceName string) ([]corev1.Event, error) {
 	//	pod, err := h.clientset.Pods(namespace).Get(podName, metav1.GetOptions{})
 	selector := h.clientset.CoreV1().Events(namespace).GetFieldSelector(&resourceName, &namespace, nil, nil)
@@ -806,6 +1027,7 @@ type DeploymentHandler interface {
 	Create(namespace string, d *extensionsv1beta1.Deployment) error
 	Delete(namespace string, name string) error
 	Update(namespace string, resource *extensionsv1beta1.Deployment) error
+	PatchAnnotations(namespace, name string, annotations map[string]string) error
 	Scale(namespace, name string, num int32) error
 	GetPods(namespace, name string) ([]*corev1.Pod, error)
 	Event(namespace, resourceName string) ([]corev1.Event, error)
@@ -849,6 +1071,15 @@ func (h *deploymentHandler) Update(namespace string, resource *extensionsv1beta1
 	return err
 }
 
+func (h *deploymentHandler) PatchAnnotations(namespace, name string, annotations map[string]string) error {
+	data, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	_, err = h.clientset.ExtensionsV1beta1().Deployments(namespace).Patch(name, types.MergePatchType, data)
+	return err
+}
+
 func (h *deploymentHandler) Delete(namespace, deploymentName string) error {
 	//	return h.clientset.ExtensionsV1beta1().Deployments(namespace).Delete(deploymentName, nil)
 	var e error
@@ -1140,7 +1371,7 @@ func (h *deploymentHandler) GetAllReplicaSets(namespace string, name string) ([]
 
 	var newRS *extensionsv1beta1.ReplicaSet
 	for i := range owned {
-		equal, err := EqualIgnoreHash(&rsList[i].Spec.Template, &d.Spec.Template)
+		equal, err := EqualIgnoreHash(&owned[i].Spec.Template, &d.Spec.Template)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -1304,6 +1535,7 @@ type ReplicaSetHandler interface {
 	Delete(namespace string, name string) error
 	GetPods(namespace, name string) ([]*corev1.Pod, error)
 	Update(namespace string, resource *extensionsv1beta1.ReplicaSet) error
+	PatchAnnotations(namespace, name string, annotations map[string]string) error
 	Scale(namespace, name string, num int32) error
 	Event(namespace, resourceName string) ([]corev1.Event, error)
 	GetServices(namespace string, name string) ([]*corev1.Service, error)
@@ -1339,6 +1571,15 @@ func (h *replicasetHandler) Update(namespace string, resource *extensionsv1beta1
 	return err
 }
 
+func (h *replicasetHandler) PatchAnnotations(namespace, name string, annotations map[string]string) error {
+	data, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	_, err = h.clientset.ExtensionsV1beta1().ReplicaSets(namespace).Patch(name, types.MergePatchType, data)
+	return err
+}
+
 func (h *replicasetHandler) Event(namespace, resourceName string) ([]corev1.Event, error) {
 	//	pod, err := h.clientset.Pods(namespace).Get(podName, metav1.GetOptions{})
 	selector := h.clientset.CoreV1().Events(namespace).GetFieldSelector(&resourceName, &namespace, nil, nil)
@@ -1468,12 +1709,15 @@ type DaemonSetHandler interface {
 	Create(namespace string, ds *extensionsv1beta1.DaemonSet) error
 	Delete(namespace string, name string) error
 	Update(namespace string, resource *extensionsv1beta1.DaemonSet) error
+	PatchAnnotations(namespace, name string, annotations map[string]string) error
 	GetPods(namespace, name string) ([]*corev1.Pod, error)
 	Event(namespace, resourceName string) ([]corev1.Event, error)
 	Revision(namespace, name string) (int64, error)
 	GetRevisionsAndDescribe(namespace, name string) (map[int64]*corev1.PodTemplateSpec, error)
+	GetControllerRevisions(namespace, name string) (*extensionsv1beta1.DaemonSet, map[int64]*appv1beta1.ControllerRevision, error)
 	Rollback(namespace, name string, revision int64) (*string, error)
 	GetServices(namespace string, name string) ([]*corev1.Service, error)
+	CountNodes(nodeSelector map[string]string) (int32, error)
 }
 
 func NewDaemonSetHandler(group, workspace string) (DaemonSetHandler, error) {
@@ -1510,6 +1754,15 @@ func (h *daemonsetHandler) Update(namespace string, resource *extensionsv1beta1.
 	return err
 }
 
+func (h *daemonsetHandler) PatchAnnotations(namespace, name string, annotations map[string]string) error {
+	data, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	_, err = h.clientset.ExtensionsV1beta1().DaemonSets(namespace).Patch(name, types.MergePatchType, data)
+	return err
+}
+
 func (h *daemonsetHandler) GetPods(namespace, name string) ([]*corev1.Pod, error) {
 	d, err := h.informerController.daemonsetInformer.Lister().DaemonSets(namespace).Get(name)
 	if err != nil {
@@ -1710,6 +1963,22 @@ func getPatch(ds *extensionsv1beta1.DaemonSet) ([]byte, error) {
 	return patch, err
 }
 
+//按nodeSelector估计DaemonSet会调度到的节点数,不可调度的节点不计入,不考虑污点和亲和性
+func (h *daemonsetHandler) CountNodes(nodeSelector map[string]string) (int32, error) {
+	opts := metav1.ListOptions{LabelSelector: labels.SelectorFromSet(nodeSelector).String()}
+	nodes, err := h.clientset.CoreV1().Nodes().List(opts)
+	if err != nil {
+		return 0, err
+	}
+	var n int32
+	for _, v := range nodes.Items {
+		if !v.Spec.Unschedulable {
+			n++
+		}
+	}
+	return n, nil
+}
+
 func (h *daemonsetHandler) GetServices(namespace string, name string) ([]*corev1.Service, error) {
 	allServices, err := h.informerController.serviceInformer.Lister().Services(namespace).List(labels.Everything())
 	if err != nil {
@@ -1823,6 +2092,7 @@ type StatefulSetHandler interface {
 	Create(namespace string, ss *appv1beta2.StatefulSet) error
 	Delete(namespace string, name string) error
 	Update(namespace string, resource *appv1beta2.StatefulSet) error
+	PatchAnnotations(namespace, name string, annotations map[string]string) error
 	GetPods(namespace, name string) ([]*corev1.Pod, error)
 	GetServices(namespace string, name string) ([]*corev1.Service, error)
 }
@@ -1860,6 +2130,15 @@ func (h *statefulsetHandler) Update(namespace string, resource *appv1beta2.State
 	_, err := h.clientset.Apps().StatefulSets(namespace).Update(resource)
 	return err
 }
+
+func (h *statefulsetHandler) PatchAnnotations(namespace, name string, annotations map[string]string) error {
+	data, err := annotationsPatch(annotations)
+	if err != nil {
+		return err
+	}
+	_, err = h.clientset.Apps().StatefulSets(namespace).Patch(name, types.MergePatchType, data)
+	return err
+}
 func (h *statefulsetHandler) GetPods(namespace, name string) ([]*corev1.Pod, error) {
 	d, err := h.informerController.statefulsetInformer.Lister().StatefulSets(namespace).Get(name)
 	if err != nil {
@@ -2164,6 +2443,11 @@ type HorizontalPodAutoscalerHandler interface {
 	Create(namespace string, hpa *autoscalingv1.HorizontalPodAutoscaler) error
 	Update(namespace string, hpa *autoscalingv1.HorizontalPodAutoscaler) error
 	List(namespace string) ([]*autoscalingv1.HorizontalPodAutoscaler, error)
+
+	//informer只缓存v1的对象,v2beta1中内存等指标需直接从apiserver获取
+	GetV2beta1(namespace string, name string) (*autoscalingv2beta1.HorizontalPodAutoscaler, error)
+	CreateV2beta1(namespace string, hpa *autoscalingv2beta1.HorizontalPodAutoscaler) error
+	UpdateV2beta1(namespace string, hpa *autoscalingv2beta1.HorizontalPodAutoscaler) error
 }
 
 func NewHorizontalPodAutoscalerHandler(group, workspace string) (HorizontalPodAutoscalerHandler, error) {
@@ -2201,6 +2485,20 @@ func (h *hpaHandler) List(namespace string) ([]*autoscalingv1.HorizontalPodAutos
 	return h.informerController.hpaInformer.Lister().HorizontalPodAutoscalers(namespace).List(labels.Everything())
 }
 
+func (h *hpaHandler) GetV2beta1(namespace, name string) (*autoscalingv2beta1.HorizontalPodAutoscaler, error) {
+	return h.clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (h *hpaHandler) CreateV2beta1(namespace string, hpa *autoscalingv2beta1.HorizontalPodAutoscaler) error {
+	_, err := h.clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Create(hpa)
+	return err
+}
+
+func (h *hpaHandler) UpdateV2beta1(namespace string, hpa *autoscalingv2beta1.HorizontalPodAutoscaler) error {
+	_, err := h.clientset.AutoscalingV2beta1().HorizontalPodAutoscalers(namespace).Update(hpa)
+	return err
+}
+
 /*  helpers */
 
 func watchRollbackEvent(w watch.Interface) string {
@@ -2281,11 +2579,11 @@ func runtimeObjectListToObjectReference(obj interface{}) []corev1.ObjectReferenc
 
 	ors := make([]corev1.ObjectReference, 0)
 	switch res := obj.(type) {
-	case []*appv1beta1.StatefulSet:
+	case []*appv1beta2.StatefulSet:
 		for _, v := range res {
 			var or corev1.ObjectReference
 			or.Kind = "StatefulSet"
-			or.APIVersion = "apps/v1beta1"
+			or.APIVersion = "apps/v1beta2"
 			or.Name = v.Name
 			or.ResourceVersion = v.ResourceVersion
 			or.Namespace = v.Namespace
@@ -0,0 +1,89 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/log"
+)
+
+const (
+	leaderKeyPrefix = "/ufleet/deploy/leader/"
+	leaderTTL       = 15 //秒
+)
+
+//多副本部署时,定时任务只在持有leader key的副本上执行
+//key带有租约,副本退出后租约过期,由其他副本接管
+type Leader struct {
+	key    string
+	id     string
+	locker sync.Mutex
+	leader bool
+}
+
+//在后台竞选name对应的leader
+//不是etcd v3的存储时(单副本)始终为leader
+func NewLeader(name string) *Leader {
+	host, _ := os.Hostname()
+	l := &Leader{
+		key: leaderKeyPrefix + name,
+		id:  fmt.Sprintf("%v-%v", host, os.Getpid()),
+	}
+
+	s, ok := Store.(*kvStoreV3)
+	if !ok {
+		l.leader = true
+		return l
+	}
+	go l.campaign(s)
+	return l
+}
+
+func (l *Leader) IsLeader() bool {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	return l.leader
+}
+
+func (l *Leader) setLeader(leader bool) {
+	l.locker.Lock()
+	defer l.locker.Unlock()
+	if l.leader != leader {
+		log.DebugPrint("%v leader of '%v': %v", l.id, l.key, leader)
+	}
+	l.leader = leader
+}
+
+func (l *Leader) campaign(s *kvStoreV3) {
+	for {
+		lease, ok, _, err := s.client.CreateWithLease(l.key, l.id, leaderTTL)
+		if err != nil {
+			log.ErrorPrint("campaign leader '%v' fail: %v", l.key, err)
+			time.Sleep(leaderTTL * time.Second / 3)
+			continue
+		}
+		if !ok {
+			//其他副本是leader,或者是本副本租约失效后残留的key,等待其过期
+			l.setLeader(false)
+			time.Sleep(leaderTTL * time.Second / 3)
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		ch, err := s.client.KeepAlive(ctx, lease)
+		if err != nil {
+			cancel()
+			s.client.Revoke(lease)
+			time.Sleep(leaderTTL * time.Second / 3)
+			continue
+		}
+		l.setLeader(true)
+		for range ch {
+		}
+		//租约失效
+		l.setLeader(false)
+		cancel()
+	}
+}
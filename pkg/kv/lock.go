@@ -0,0 +1,93 @@
+package kv
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"ufleet-deploy/pkg/log"
+
+	"github.com/coreos/etcd/clientv3"
+)
+
+const (
+	lockKeyPrefix = "/ufleet/deploy/lock/"
+)
+
+var (
+	//不是etcd v3的存储时(单副本)使用进程内的锁
+	localLocks      = make(map[string]bool)
+	localLocksMutex sync.Mutex
+)
+
+//跨副本的互斥锁,key带有租约,持有的副本退出后租约过期,锁随之释放
+type Lock struct {
+	name   string
+	lease  clientv3.LeaseID
+	cancel context.CancelFunc
+	s      *kvStoreV3
+}
+
+//尝试获取name对应的锁,已被持有时返回false
+func TryLock(name string) (*Lock, bool, error) {
+	s, ok := Store.(*kvStoreV3)
+	if !ok {
+		localLocksMutex.Lock()
+		defer localLocksMutex.Unlock()
+		if localLocks[name] {
+			return nil, false, nil
+		}
+		localLocks[name] = true
+		return &Lock{name: name}, true, nil
+	}
+
+	host, _ := os.Hostname()
+	id := fmt.Sprintf("%v-%v", host, os.Getpid())
+	lease, ok, _, err := s.client.CreateWithLease(lockKeyPrefix+name, id, leaderTTL)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := s.client.KeepAlive(ctx, lease)
+	if err != nil {
+		cancel()
+		s.client.Revoke(lease)
+		return nil, false, err
+	}
+	go func() {
+		for range ch {
+		}
+	}()
+	return &Lock{name: name, lease: lease, cancel: cancel, s: s}, true, nil
+}
+
+//锁是否被某个副本持有
+func IsLocked(name string) (bool, error) {
+	if _, ok := Store.(*kvStoreV3); !ok {
+		localLocksMutex.Lock()
+		defer localLocksMutex.Unlock()
+		return localLocks[name], nil
+	}
+	_, err := Store.GetNode(lockKeyPrefix + name)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Lock) Unlock() {
+	if l.s == nil {
+		localLocksMutex.Lock()
+		defer localLocksMutex.Unlock()
+		delete(localLocks, l.name)
+		return
+	}
+	l.cancel()
+	err := l.s.client.Revoke(l.lease)
+	if err != nil {
+		log.ErrorPrint("release lock '%v' fail: %v", l.name, err)
+	}
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"ufleet-deploy/util/cadvisor"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	cadvisorProviderName = "cadvisor"
+)
+
+//通过节点上cadvisor的v2 API获取容器的资源使用
+type cadvisorProvider struct {
+	port     string
+	locker   sync.Mutex
+	managers map[string]cadvisor.Manager //节点IP到cadvisor客户端的缓存
+}
+
+func newCadvisorProvider(port string) *cadvisorProvider {
+	return &cadvisorProvider{port: port, managers: make(map[string]cadvisor.Manager)}
+}
+
+func (p *cadvisorProvider) Name() string {
+	return cadvisorProviderName
+}
+
+func (p *cadvisorProvider) Supports(metric string) bool {
+	return true
+}
+
+func (p *cadvisorProvider) manager(hostIP string) (cadvisor.Manager, error) {
+	p.locker.Lock()
+	defer p.locker.Unlock()
+	if m, ok := p.managers[hostIP]; ok {
+		return m, nil
+	}
+	m, err := cadvisor.NewManager("http://" + hostIP + ":" + p.port + "/")
+	if err != nil {
+		return nil, err
+	}
+	p.managers[hostIP] = m
+	return m, nil
+}
+
+//Pod的cgroup名,依次为cgroupfs和systemd驱动下的名称
+func podCgroupNames(pod *corev1.Pod) []string {
+	uid := string(pod.UID)
+	systemdUID := strings.Replace(uid, "-", "_", -1)
+	switch pod.Status.QOSClass {
+	case corev1.PodQOSGuaranteed:
+		return []string{
+			"/kubepods/pod" + uid,
+			"/kubepods.slice/kubepods-pod" + systemdUID + ".slice",
+		}
+	case corev1.PodQOSBestEffort:
+		return []string{
+			"/kubepods/besteffort/pod" + uid,
+			"/kubepods.slice/kubepods-besteffort.slice/kubepods-besteffort-pod" + systemdUID + ".slice",
+		}
+	default:
+		return []string{
+			"/kubepods/burstable/pod" + uid,
+			"/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod" + systemdUID + ".slice",
+		}
+	}
+}
+
+//docker的容器可以直接按ID查询
+//containerd/CRI-O的容器在Pod的cgroup下查找,cgroup名中包含容器ID
+func (p *cadvisorProvider) containerStats(m cadvisor.Manager, pod *corev1.Pod, runtime, id string) ([]cadvisor.ContainerStat, error) {
+	if runtime == "docker" {
+		css, err := m.GetStats(id, cadvisor.StatOption{IdType: cadvisor.IdTypeDocker})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range css {
+			return v, nil
+		}
+		return nil, fmt.Errorf("container '%v' not found", id)
+	}
+
+	var lastErr error
+	for _, name := range podCgroupNames(pod) {
+		css, err := m.GetStats(name, cadvisor.StatOption{IdType: cadvisor.IdTypeName, Recursive: true})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for k, v := range css {
+			if strings.Contains(k, id) {
+				return v, nil
+			}
+		}
+		lastErr = fmt.Errorf("container '%v' not found in cgroup '%v'", id, name)
+	}
+	return nil, lastErr
+}
+
+func (p *cadvisorProvider) ContainerSeries(group string, pod *corev1.Pod, container string) (*Series, error) {
+	cid, err := containerID(pod, container)
+	if err != nil {
+		return nil, err
+	}
+	runtime, id, err := ParseContainerID(cid)
+	if err != nil {
+		return nil, err
+	}
+	if pod.Status.HostIP == "" {
+		return nil, fmt.Errorf("pod '%v' isn't scheduled", pod.Name)
+	}
+
+	m, err := p.manager(pod.Status.HostIP)
+	if err != nil {
+		return nil, err
+	}
+	css, err := p.containerStats(m, pod, runtime, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var s Series
+	s.Samples = make([]Sample, 0, len(css))
+	for _, v := range css {
+		seconds := v.End.Sub(v.Start).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		s.Samples = append(s.Samples, Sample{
+			Time:      v.End,
+			CPU:       v.Cpu.Usage,
+			Memory:    v.Memory.Used,
+			RxRate:    float64(v.Network.RxBytes) / seconds,
+			TxRate:    float64(v.Network.TxBytes) / seconds,
+			DiskRead:  float64(v.Disk.ReadBytes) / seconds,
+			DiskWrite: float64(v.Disk.WriteBytes) / seconds,
+		})
+	}
+	return &s, nil
+}
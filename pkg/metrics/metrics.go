@@ -0,0 +1,315 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource/workload"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	providersEnvKey    = "METRICS_PROVIDERS"
+	cadvisorPortEnvKey = "CADVISOR_PORT"
+
+	defaultProviders    = "cadvisor,metrics-api"
+	defaultCadvisorPort = "4194"
+
+	DefaultStep = 15 * time.Second
+
+	//各来源能提供的指标
+	MetricCPU     = "cpu"
+	MetricMemory  = "memory"
+	MetricDisk    = "disk"
+	MetricNetwork = "network"
+)
+
+//一个时间点的资源使用
+type Sample struct {
+	Time      time.Time `json:"time"`
+	CPU       float64   `json:"cpu"`       //使用的核数
+	Memory    uint64    `json:"memory"`    //working set,字节
+	RxRate    float64   `json:"rxrate"`    //接收,字节/秒;容器的为所在Pod的
+	TxRate    float64   `json:"txrate"`    //发送,字节/秒
+	DiskRead  float64   `json:"diskread"`  //读取,字节/秒
+	DiskWrite float64   `json:"diskwrite"` //写入,字节/秒
+}
+
+type Series struct {
+	Name     string   `json:"name"`
+	Provider string   `json:"provider,omitempty"`
+	Samples  []Sample `json:"samples"`
+	Error    string   `json:"error,omitempty"` //部分容器获取失败时的原因
+}
+
+type PodSeries struct {
+	Pod        string   `json:"pod"`
+	Total      Series   `json:"total"`
+	Containers []Series `json:"containers"`
+}
+
+type WorkloadSeries struct {
+	Kind  string      `json:"kind"`
+	Name  string      `json:"name"`
+	Total Series      `json:"total"`
+	Pods  []PodSeries `json:"pods"`
+}
+
+//资源使用的来源
+type Provider interface {
+	Name() string
+	//是否能提供该指标,不能提供的指标在采样中为0
+	Supports(metric string) bool
+	ContainerSeries(group string, pod *corev1.Pod, container string) (*Series, error)
+}
+
+var (
+	providers = make([]Provider, 0)
+)
+
+func newProvider(name string) (Provider, error) {
+	switch name {
+	case cadvisorProviderName:
+		port := os.Getenv(cadvisorPortEnvKey)
+		if port == "" {
+			port = defaultCadvisorPort
+		}
+		return newCadvisorProvider(port), nil
+	case metricsAPIProviderName:
+		return &metricsAPIProvider{}, nil
+	}
+	return nil, fmt.Errorf("unknown metrics provider '%v'", name)
+}
+
+//按配置的顺序使用各个来源,前一个失败时使用下一个
+func Init() {
+	names := os.Getenv(providersEnvKey)
+	if strings.TrimSpace(names) == "" {
+		names = defaultProviders
+	}
+	for _, v := range strings.Split(names, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		p, err := newProvider(v)
+		if err != nil {
+			log.ErrorPrint("%v: %v, ignore it", providersEnvKey, err)
+			continue
+		}
+		providers = append(providers, p)
+	}
+	if len(providers) == 0 {
+		log.ErrorPrint("%v '%v' has no valid provider, use '%v'", providersEnvKey, names, defaultProviders)
+		names = defaultProviders
+		for _, v := range strings.Split(names, ",") {
+			p, _ := newProvider(v)
+			providers = append(providers, p)
+		}
+	}
+	log.DebugPrint("metrics providers: %v", names)
+}
+
+//配置的来源中是否有能提供该指标的
+func Supported(metric string) bool {
+	for _, p := range providers {
+		if p.Supports(metric) {
+			return true
+		}
+	}
+	return false
+}
+
+//时间序列中的该指标是否有数据,来源不能提供时只是0
+func HasMetric(s Series, metric string) bool {
+	for _, p := range providers {
+		if p.Name() == s.Provider {
+			return p.Supports(metric)
+		}
+	}
+	return false
+}
+
+//容器ID的格式为<runtime>://<id>,如docker://...,containerd://...,cri-o://...
+func ParseContainerID(containerID string) (string, string, error) {
+	s := strings.SplitN(containerID, "://", 2)
+	if len(s) != 2 || s[1] == "" {
+		return "", "", fmt.Errorf("invalid container id '%v'", containerID)
+	}
+	return s[0], s[1], nil
+}
+
+func containerID(pod *corev1.Pod, container string) (string, error) {
+	for _, v := range pod.Status.ContainerStatuses {
+		if v.Name == container {
+			if v.ContainerID == "" {
+				return "", fmt.Errorf("container '%v' of pod '%v' isn't started", container, pod.Name)
+			}
+			return v.ContainerID, nil
+		}
+	}
+	return "", fmt.Errorf("container '%v' not found in pod '%v'", container, pod.Name)
+}
+
+//容器的资源使用
+func ContainerSeries(group string, pod *corev1.Pod, container string) (*Series, error) {
+	if len(providers) == 0 {
+		return nil, fmt.Errorf("no metrics provider")
+	}
+	errs := make([]string, 0)
+	for _, p := range providers {
+		s, err := p.ContainerSeries(group, pod, container)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%v: %v", p.Name(), err))
+			continue
+		}
+		s.Name = pod.Name + "/" + container
+		s.Provider = p.Name()
+		return s, nil
+	}
+	return nil, fmt.Errorf("get metrics of %v/%v fail: %v", pod.Name, container, strings.Join(errs, "; "))
+}
+
+//Pod中所有容器的资源使用,Total为各容器之和
+//Pod中的容器共用网络,每个容器的网络流量都是整个Pod的,Total中不累加
+func PodSeriesOf(group string, pod *corev1.Pod, step time.Duration) (*PodSeries, error) {
+	ps := PodSeries{Pod: pod.Name}
+	ps.Containers = make([]Series, 0)
+	errs := make([]string, 0)
+	for _, c := range pod.Spec.Containers {
+		s, err := ContainerSeries(group, pod, c.Name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		ps.Containers = append(ps.Containers, *s)
+	}
+	if len(ps.Containers) == 0 {
+		return nil, fmt.Errorf("%v", strings.Join(errs, "; "))
+	}
+
+	ps.Total = sum(pod.Name, ps.Containers, step, true)
+	ps.Total.Error = strings.Join(errs, "; ")
+	return &ps, nil
+}
+
+//工作负载所有Pod的资源使用,Total为各Pod之和
+func WorkloadSeriesOf(kind, group, workspace, name string, step time.Duration) (*WorkloadSeries, error) {
+	pods, err := workload.GetPods(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := WorkloadSeries{Kind: kind, Name: name}
+	ws.Pods = make([]PodSeries, 0)
+	totals := make([]Series, 0)
+	errs := make([]string, 0)
+	for _, p := range pods {
+		if p.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		ps, err := PodSeriesOf(group, p, step)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		ws.Pods = append(ws.Pods, *ps)
+		totals = append(totals, ps.Total)
+	}
+	if len(ws.Pods) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("%v", strings.Join(errs, "; "))
+	}
+
+	ws.Total = Sum(name, totals, step)
+	ws.Total.Error = strings.Join(errs, "; ")
+	return &ws, nil
+}
+
+type sortableSamples []Sample
+
+func (s sortableSamples) Len() int           { return len(s) }
+func (s sortableSamples) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortableSamples) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+
+//将一个时间序列按step对齐,同一区间内的采样取平均
+func Align(samples []Sample, step time.Duration) []Sample {
+	if step <= 0 {
+		step = DefaultStep
+	}
+	sums := make(map[int64]*Sample)
+	counts := make(map[int64]int)
+	for _, v := range samples {
+		t := v.Time.Truncate(step)
+		k := t.UnixNano()
+		s, ok := sums[k]
+		if !ok {
+			s = &Sample{Time: t}
+			sums[k] = s
+		}
+		s.CPU += v.CPU
+		s.Memory += v.Memory
+		s.RxRate += v.RxRate
+		s.TxRate += v.TxRate
+		s.DiskRead += v.DiskRead
+		s.DiskWrite += v.DiskWrite
+		counts[k]++
+	}
+
+	result := make([]Sample, 0, len(sums))
+	for k, s := range sums {
+		n := counts[k]
+		s.CPU /= float64(n)
+		s.Memory /= uint64(n)
+		s.RxRate /= float64(n)
+		s.TxRate /= float64(n)
+		s.DiskRead /= float64(n)
+		s.DiskWrite /= float64(n)
+		result = append(result, *s)
+	}
+	sort.Sort(sortableSamples(result))
+	return result
+}
+
+//多个时间序列按step对齐后相加
+func Sum(name string, series []Series, step time.Duration) Series {
+	return sum(name, series, step, false)
+}
+
+//sharedNetwork为true时各序列的网络流量相同,取最大值而不相加
+func sum(name string, series []Series, step time.Duration, sharedNetwork bool) Series {
+	sums := make(map[int64]*Sample)
+	for _, v := range series {
+		for _, a := range Align(v.Samples, step) {
+			k := a.Time.UnixNano()
+			s, ok := sums[k]
+			if !ok {
+				s = &Sample{Time: a.Time}
+				sums[k] = s
+			}
+			s.CPU += a.CPU
+			s.Memory += a.Memory
+			if sharedNetwork {
+				s.RxRate = math.Max(s.RxRate, a.RxRate)
+				s.TxRate = math.Max(s.TxRate, a.TxRate)
+			} else {
+				s.RxRate += a.RxRate
+				s.TxRate += a.TxRate
+			}
+			s.DiskRead += a.DiskRead
+			s.DiskWrite += a.DiskWrite
+		}
+	}
+
+	result := Series{Name: name}
+	result.Samples = make([]Sample, 0, len(sums))
+	for _, s := range sums {
+		result.Samples = append(result.Samples, *s)
+	}
+	sort.Sort(sortableSamples(result.Samples))
+	return result
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/cluster"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	metricsAPIProviderName = "metrics-api"
+)
+
+//通过metrics API(metrics-server)获取容器的资源使用
+//只有CPU和内存,且只有最近一个时间点
+type metricsAPIProvider struct{}
+
+func (p *metricsAPIProvider) Name() string {
+	return metricsAPIProviderName
+}
+
+func (p *metricsAPIProvider) Supports(metric string) bool {
+	return metric == MetricCPU || metric == MetricMemory
+}
+
+func (p *metricsAPIProvider) ContainerSeries(group string, pod *corev1.Pod, container string) (*Series, error) {
+	ph, err := cluster.NewPodHandler(group, pod.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	pm, err := ph.Metrics(pod.Namespace, pod.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range pm.Containers {
+		if v.Name != container {
+			continue
+		}
+		var sample Sample
+		sample.Time = pm.Timestamp.Time
+		if q, ok := v.Usage[corev1.ResourceCPU]; ok {
+			sample.CPU = float64(q.MilliValue()) / 1000
+		}
+		if q, ok := v.Usage[corev1.ResourceMemory]; ok {
+			sample.Memory = uint64(q.Value())
+		}
+		return &Series{Samples: []Sample{sample}}, nil
+	}
+	return nil, fmt.Errorf("container '%v' not found in metrics of pod '%v'", container, pod.Name)
+}
@@ -0,0 +1,265 @@
+package quota
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	mb = 1024 * 1024
+)
+
+type Resource struct {
+	CPU float64 `json:"cpu"` //核
+	Mem float64 `json:"mem"` //MB
+}
+
+type Usage struct {
+	Group     string            `json:"group"`
+	Workspace string            `json:"workspace"`
+	Quota     backend.Workspace `json:"quota"`
+	Pods      int               `json:"pods"`
+	Used      Resource          `json:"used"`
+	//剩余可用的资源,配额为0(不限制)时为-1
+	Free Resource `json:"free"`
+}
+
+func cpuQuantity(cores float64) apiresource.Quantity {
+	return *apiresource.NewMilliQuantity(int64(cores*1000), apiresource.DecimalSI)
+}
+
+func memQuantity(mbs float64) apiresource.Quantity {
+	return *apiresource.NewQuantity(int64(mbs*mb), apiresource.BinarySI)
+}
+
+//容器的资源限制,没有设置limits时取requests
+func containerResource(c corev1.Container) Resource {
+	var r Resource
+	cpu, ok := c.Resources.Limits[corev1.ResourceCPU]
+	if !ok {
+		cpu = c.Resources.Requests[corev1.ResourceCPU]
+	}
+	mem, ok := c.Resources.Limits[corev1.ResourceMemory]
+	if !ok {
+		mem = c.Resources.Requests[corev1.ResourceMemory]
+	}
+	r.CPU = float64(cpu.MilliValue()) / 1000
+	r.Mem = float64(mem.Value()) / mb
+	return r
+}
+
+//Pod的有效资源为所有容器之和与单个初始化容器的最大值
+func PodResource(spec *corev1.PodSpec) Resource {
+	var r Resource
+	for _, c := range spec.Containers {
+		cr := containerResource(c)
+		r.CPU += cr.CPU
+		r.Mem += cr.Mem
+	}
+	for _, c := range spec.InitContainers {
+		cr := containerResource(c)
+		if cr.CPU > r.CPU {
+			r.CPU = cr.CPU
+		}
+		if cr.Mem > r.Mem {
+			r.Mem = cr.Mem
+		}
+	}
+	return r
+}
+
+func injectContainerDefaults(q *backend.Workspace, c *corev1.Container) {
+	defCPU := q.ContainerCPUDefault
+	if defCPU <= 0 {
+		defCPU = q.ContainerCPUMax
+	}
+	defMem := q.ContainerMemDefault
+	if defMem <= 0 {
+		defMem = q.ContainerMemMax
+	}
+
+	if c.Resources.Limits == nil {
+		c.Resources.Limits = make(corev1.ResourceList)
+	}
+	if c.Resources.Requests == nil {
+		c.Resources.Requests = make(corev1.ResourceList)
+	}
+
+	inject := func(name corev1.ResourceName, def apiresource.Quantity) {
+		if def.IsZero() {
+			return
+		}
+		limit, hasLimit := c.Resources.Limits[name]
+		_, hasRequest := c.Resources.Requests[name]
+		if !hasLimit {
+			c.Resources.Limits[name] = def
+			limit = def
+		}
+		//requests不能大于limits
+		if !hasRequest {
+			if def.Cmp(limit) > 0 {
+				c.Resources.Requests[name] = limit
+			} else {
+				c.Resources.Requests[name] = def
+			}
+		}
+	}
+	inject(corev1.ResourceCPU, cpuQuantity(defCPU))
+	inject(corev1.ResourceMemory, memQuantity(defMem))
+}
+
+//为没有设置requests/limits的容器注入工作区的默认值
+func InjectDefaults(q *backend.Workspace, spec *corev1.PodSpec) {
+	for k := range spec.InitContainers {
+		injectContainerDefaults(q, &spec.InitContainers[k])
+	}
+	for k := range spec.Containers {
+		injectContainerDefaults(q, &spec.Containers[k])
+	}
+}
+
+//检查容器和Pod是否超过工作区的单个容器/Pod的限制
+func CheckPodSpec(q *backend.Workspace, spec *corev1.PodSpec) error {
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		r := containerResource(c)
+		if q.ContainerCPUMax > 0 && r.CPU > q.ContainerCPUMax {
+			return fmt.Errorf("container '%v' cpu %v exceeds workspace container max cpu %v", c.Name, r.CPU, q.ContainerCPUMax)
+		}
+		if q.ContainerMemMax > 0 && r.Mem > q.ContainerMemMax {
+			return fmt.Errorf("container '%v' memory %vMB exceeds workspace container max memory %vMB", c.Name, r.Mem, q.ContainerMemMax)
+		}
+	}
+
+	r := PodResource(spec)
+	if q.PodCPUMax > 0 && r.CPU > q.PodCPUMax {
+		return fmt.Errorf("pod cpu %v exceeds workspace pod max cpu %v", r.CPU, q.PodCPUMax)
+	}
+	if q.PodMemMax > 0 && r.Mem > q.PodMemMax {
+		return fmt.Errorf("pod memory %vMB exceeds workspace pod max memory %vMB", r.Mem, q.PodMemMax)
+	}
+	return nil
+}
+
+//统计工作区中未结束的Pod占用的资源
+func GetUsage(group, workspace string) (*Usage, error) {
+	q, err := backend.GetWorkspaceQuota(group, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	ph, err := cluster.NewPodHandler(group, workspace)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	pods, err := ph.List(workspace)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	var u Usage
+	u.Group = group
+	u.Workspace = workspace
+	u.Quota = *q
+	for _, v := range pods {
+		if v.DeletionTimestamp != nil ||
+			v.Status.Phase == corev1.PodSucceeded ||
+			v.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		r := PodResource(&v.Spec)
+		u.Pods++
+		u.Used.CPU += r.CPU
+		u.Used.Mem += r.Mem
+	}
+
+	u.Free.CPU = -1
+	if q.CPU > 0 {
+		u.Free.CPU = q.CPU - u.Used.CPU
+	}
+	u.Free.Mem = -1
+	if q.Mem > 0 {
+		u.Free.Mem = q.Mem*1024 - u.Used.Mem
+	}
+	return &u, nil
+}
+
+//转换为与集群中的Pod模板相同的形式后再计算资源:
+//注入工作区的默认值,并且和apiserver一样在没有设置requests时取limits
+func normalize(q *backend.Workspace, spec *corev1.PodSpec) *corev1.PodSpec {
+	n := spec.DeepCopy()
+	InjectDefaults(q, n)
+	fill := func(c *corev1.Container) {
+		for name, l := range c.Resources.Limits {
+			if _, ok := c.Resources.Requests[name]; !ok {
+				c.Resources.Requests[name] = l
+			}
+		}
+	}
+	for k := range n.InitContainers {
+		fill(&n.InitContainers[k])
+	}
+	for k := range n.Containers {
+		fill(&n.Containers[k])
+	}
+	return n
+}
+
+//创建/更新/伸缩工作负载前调用,注入默认资源并检查是否超出工作区配额
+//spec为新的Pod模板,会被注入默认值;old为更新前的Pod模板,创建时为nil
+//工作区没有配额时不做任何处理
+func Admit(group, workspace string, spec *corev1.PodSpec, replicas int32, old *corev1.PodSpec, oldReplicas int32) error {
+	q, err := backend.GetWorkspaceQuota(group, workspace)
+	if err != nil {
+		if err == backend.ErrQuotaNotFound {
+			return nil
+		}
+		return err
+	}
+
+	InjectDefaults(q, spec)
+	err = CheckPodSpec(q, spec)
+	if err != nil {
+		return err
+	}
+
+	if q.CPU <= 0 && q.Mem <= 0 {
+		return nil
+	}
+
+	//新旧模板使用相同的默认值形式,避免因为默认值不同算出错误的增量
+	nr := PodResource(normalize(q, spec))
+	need := Resource{CPU: nr.CPU * float64(replicas), Mem: nr.Mem * float64(replicas)}
+	if old != nil {
+		or := PodResource(normalize(q, old))
+		need.CPU -= or.CPU * float64(oldReplicas)
+		need.Mem -= or.Mem * float64(oldReplicas)
+	}
+	if need.CPU <= 0 && need.Mem <= 0 {
+		return nil
+	}
+
+	u, err := GetUsage(group, workspace)
+	if err != nil {
+		return err
+	}
+	if need.CPU > 0 && q.CPU > 0 && u.Used.CPU+need.CPU > q.CPU {
+		return fmt.Errorf("exceed workspace cpu quota: quota %v, used %v, request %v", q.CPU, u.Used.CPU, need.CPU)
+	}
+	if need.Mem > 0 && q.Mem > 0 && u.Used.Mem+need.Mem > q.Mem*1024 {
+		return fmt.Errorf("exceed workspace memory quota: quota %vGB, used %vMB, request %vMB", q.Mem, u.Used.Mem, need.Mem)
+	}
+	return nil
+}
+
+//副本数未设置时默认为1
+func Replicas(r *int32) int32 {
+	if r == nil {
+		return 1
+	}
+	return *r
+}
@@ -0,0 +1,83 @@
+package resource
+
+import (
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const defaultChangeOperation = "update"
+
+var changeAnnotationKeys = []string{
+	sign.SignChangeCause,
+	sign.SignUfleetChangeUser,
+	sign.SignUfleetChangeComment,
+}
+
+//可读的修改记录,如"set image by admin: fix bug"
+func (opt UpdateOption) ChangeCause() string {
+	cause := opt.Operation
+	if cause == "" {
+		cause = defaultChangeOperation
+	}
+	if opt.User != "" {
+		cause += " by " + opt.User
+	}
+	if opt.Comment != "" {
+		cause += ": " + opt.Comment
+	}
+	return cause
+}
+
+//修改记录对应的注解,值为空表示删除
+func (opt UpdateOption) ChangeAnnotations() map[string]string {
+	return map[string]string{
+		sign.SignChangeCause:         opt.ChangeCause(),
+		sign.SignUfleetChangeUser:    opt.User,
+		sign.SignUfleetChangeComment: opt.Comment,
+	}
+}
+
+func setChangeAnnotations(as map[string]string, changes map[string]string) map[string]string {
+	if as == nil {
+		as = make(map[string]string)
+	}
+	for k, v := range changes {
+		if v == "" {
+			delete(as, k)
+			continue
+		}
+		as[k] = v
+	}
+	return as
+}
+
+//提交的模板不带默认值,注入默认值后再和集群中的模板比较
+func withoutChangeAnnotations(tpl *corev1.PodTemplateSpec) *corev1.PodTemplateSpec {
+	c := DefaultPodTemplate(tpl)
+	for _, k := range changeAnnotationKeys {
+		delete(c.Annotations, k)
+	}
+	return c
+}
+
+//把修改记录写入工作负载的注解
+//Pod模板只在除修改记录外有变化时写入,成为新版本的记录;
+//否则保留旧模板中的记录,只写入工作负载自身的注解,避免只因为记录变化而触发滚动升级
+func (opt UpdateOption) RecordChange(meta *metav1.ObjectMeta, tpl, oldTpl *corev1.PodTemplateSpec) {
+	changes := opt.ChangeAnnotations()
+	meta.Annotations = setChangeAnnotations(meta.Annotations, changes)
+	if tpl == nil {
+		return
+	}
+
+	if oldTpl != nil && apiequality.Semantic.DeepEqual(withoutChangeAnnotations(tpl), withoutChangeAnnotations(oldTpl)) {
+		changes = make(map[string]string)
+		for _, k := range changeAnnotationKeys {
+			changes[k] = oldTpl.Annotations[k]
+		}
+	}
+	tpl.Annotations = setChangeAnnotations(tpl.Annotations, changes)
+}
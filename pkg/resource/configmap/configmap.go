@@ -144,6 +144,10 @@ func (p *ConfigMapManager) DeleteGroup(groupName string) error {
 	}
 
 	delete(p.Groups, groupName)
+	err := backend.DeleteHistories(backendKind, groupName, "")
+	if err != nil {
+		log.ErrorPrint(err)
+	}
 	return nil
 }
 
@@ -248,6 +252,10 @@ func (p *ConfigMapManager) DeleteWorkspace(groupName string, workspaceName strin
 	}
 	delete(group.Workspaces, workspaceName)
 	p.Groups[groupName] = group
+	err := backend.DeleteHistories(backendKind, groupName, workspaceName)
+	if err != nil {
+		log.ErrorPrint(err)
+	}
 	return nil
 }
 
@@ -459,6 +467,12 @@ func (p *ConfigMapManager) UpdateObject(groupName, workspaceName string, resourc
 		return nil
 	}
 
+	//更新前的数据,用于记录历史版本
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		oldr = nil
+	}
+
 	old := *res
 	res.Comment = opt.Comment
 	be := backend.NewBackendHandler()
@@ -476,6 +490,11 @@ func (p *ConfigMapManager) UpdateObject(groupName, workspaceName string, resourc
 		return log.DebugPrint(err)
 	}
 
+	err = recordHistory(&old, oldr, &newr, opt)
+	if err != nil {
+		log.ErrorPrint(err)
+	}
+
 	return nil
 }
 
@@ -525,6 +544,11 @@ func (p *ConfigMapManager) DeleteObject(group, workspace, resourceName string, o
 	}
 
 	if opt.MemoryOnly {
+		//etcd中的记录可能由其他途径删除,如删除应用,一并清理历史版本
+		err := backend.DeleteHistory(backendKind, group, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		return p.delete(group, workspace, resourceName)
 	}
 
@@ -545,6 +569,10 @@ func (p *ConfigMapManager) DeleteObject(group, workspace, resourceName string, o
 		if err != nil {
 			return log.DebugPrint(err)
 		}
+		err = backend.DeleteHistory(backendKind, group, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		err = ph.Delete(workspace, resourceName)
 		if err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -0,0 +1,172 @@
+package configmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/util"
+	"ufleet-deploy/pkg/resource/workload"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+type Revision struct {
+	Version int64             `json:"version"`
+	User    string            `json:"user"`
+	Time    int64             `json:"time"`
+	Comment string            `json:"comment"`
+	Data    map[string]string `json:"data"`
+}
+
+func toBackendRevision(data map[string]string, user string, t int64, comment string) (*backend.Revision, error) {
+	if data == nil {
+		data = make(map[string]string)
+	}
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return &backend.Revision{User: user, Time: t, Comment: comment, Data: string(bs)}, nil
+}
+
+func fromBackendRevision(br backend.Revision) (*Revision, error) {
+	r := Revision{Version: br.Version, User: br.User, Time: br.Time, Comment: br.Comment}
+	r.Data = make(map[string]string)
+	err := json.Unmarshal([]byte(br.Data), &r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//更新成功后记录新版本,没有历史时先记录更新前的数据作为第一个版本
+//数据没有变化时不记录
+func recordHistory(old *ConfigMap, oldr *corev1.ConfigMap, newr *corev1.ConfigMap, opt resource.UpdateOption) error {
+	rs, err := backend.GetHistory(backendKind, old.Group, old.Workspace, old.Name)
+	if err != nil {
+		return err
+	}
+
+	nr, err := toBackendRevision(newr.Data, opt.User, time.Now().Unix(), opt.Comment)
+	if err != nil {
+		return err
+	}
+
+	brs := make([]backend.Revision, 0)
+	if len(rs) == 0 {
+		if oldr != nil {
+			or, err := toBackendRevision(oldr.Data, old.User, old.CreateTime, old.Comment)
+			if err != nil {
+				return err
+			}
+			if or.Data != nr.Data {
+				brs = append(brs, *or)
+			}
+		}
+	} else if rs[len(rs)-1].Data == nr.Data {
+		return nil
+	}
+	brs = append(brs, *nr)
+
+	return backend.AppendHistory(backendKind, old.Group, old.Workspace, old.Name, brs...)
+}
+
+//按版本从旧到新返回
+func ListHistory(group, workspace, name string) ([]Revision, error) {
+	_, err := rm.Get(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	brs, err := backend.GetHistory(backendKind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make([]Revision, 0, len(brs))
+	for _, v := range brs {
+		r, err := fromBackendRevision(v)
+		if err != nil {
+			return nil, log.DebugPrint(err)
+		}
+		rs = append(rs, *r)
+	}
+	return rs, nil
+}
+
+func GetRevision(group, workspace, name string, version int64) (*Revision, error) {
+	br, err := backend.GetRevision(backendKind, group, workspace, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return fromBackendRevision(*br)
+}
+
+//比较两个版本的数据,to为0时与当前数据比较
+func DiffRevision(group, workspace, name string, from, to int64) ([]util.DataChange, error) {
+	fr, err := GetRevision(group, workspace, name, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var toData map[string]string
+	if to == 0 {
+		cm, err := rm.Get(group, workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		runtime, err := cm.GetRuntime()
+		if err != nil {
+			return nil, err
+		}
+		toData = runtime.Data
+	} else {
+		tr, err := GetRevision(group, workspace, name, to)
+		if err != nil {
+			return nil, err
+		}
+		toData = tr.Data
+	}
+
+	return util.DiffData(fr.Data, toData), nil
+}
+
+//将数据恢复到指定版本,恢复本身也会产生一个新版本
+func Restore(group, workspace, name string, version int64, opt resource.UpdateOption) ([]workload.ReloadResult, error) {
+	r, err := GetRevision(group, workspace, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	cm, err := rm.Get(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := cm.GetRuntime()
+	if err != nil {
+		return nil, err
+	}
+
+	newr := runtime.ConfigMap.DeepCopy()
+	newr.Kind = resourceKind
+	newr.APIVersion = "v1"
+	newr.Data = r.Data
+
+	data, err := json.Marshal(newr)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	if opt.Comment == "" {
+		opt.Comment = fmt.Sprintf("restore from version %v", version)
+	}
+	err = Controller.UpdateObject(group, workspace, name, data, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return Reload(group, workspace, name, opt)
+}
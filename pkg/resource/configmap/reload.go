@@ -0,0 +1,40 @@
+package configmap
+
+import (
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/workload"
+)
+
+//ConfigMap更新后调用
+//重载策略和配置内容都取自集群中的ConfigMap,而不是请求的数据;
+//滚动重启开启了重载的工作负载,返回各工作负载的处理结果
+func Reload(group, workspace, name string, opt resource.UpdateOption) ([]workload.ReloadResult, error) {
+	cm, err := rm.Get(group, workspace, name)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	runtime, err := cm.GetRuntime()
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	enabled, err := workload.ReloadEnabled(runtime.Annotations, group, workspace, cm.App)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	refs, err := cm.GetReferenceObjects()
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	content := make(map[string][]byte)
+	for k, v := range runtime.Data {
+		content[k] = []byte(v)
+	}
+	hash := workload.ContentHash(content)
+
+	return workload.RestartForConfig(resourceKind, group, workspace, name, hash, refs, enabled, opt), nil
+}
@@ -9,6 +9,7 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	jk "ufleet-deploy/pkg/resource/job"
 	pk "ufleet-deploy/pkg/resource/pod"
@@ -355,6 +356,12 @@ func (p *CronJobManager) CreateObject(groupName, workspaceName string, data []by
 		return log.DebugPrint("must offer one  cronjob resource json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.JobTemplate.Spec.Template.Spec, quota.Replicas(obj.Spec.JobTemplate.Spec.Parallelism), nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -430,6 +437,15 @@ func (p *CronJobManager) UpdateObject(groupName, workspaceName string, resourceN
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.JobTemplate.Spec.Template.Spec, quota.Replicas(newr.Spec.JobTemplate.Spec.Parallelism), &oldr.Spec.JobTemplate.Spec.Template.Spec, quota.Replicas(oldr.Spec.JobTemplate.Spec.Parallelism))
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -9,6 +9,7 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/pod"
 	"ufleet-deploy/pkg/resource/util"
@@ -370,6 +371,17 @@ func (p *DaemonSetManager) CreateObject(groupName, workspaceName string, data []
 		return log.DebugPrint("must and  offer one resource json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	//DaemonSet的Pod数为调度到的节点数
+	nodes, err := ph.CountNodes(obj.Spec.Template.Spec.NodeSelector)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.Template.Spec, nodes, nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -528,6 +540,22 @@ func (p *DaemonSetManager) UpdateObject(groupName, workspaceName string, resourc
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	//nodeSelector修改后调度到的节点数可能变化
+	nodes, err := ph.CountNodes(newr.Spec.Template.Spec.NodeSelector)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.Template.Spec, nodes, &oldr.Spec.Template.Spec, oldr.Status.DesiredNumberScheduled)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	opt.RecordChange(&newr.ObjectMeta, &newr.Spec.Template, &oldr.Spec.Template)
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -0,0 +1,353 @@
+package deployment
+
+import (
+	"encoding/json"
+	"fmt"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
+
+	autoscalingv2beta1 "k8s.io/api/autoscaling/v2beta1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	AutoScaleTypeCPU  = "cpu"
+	AutoScaleTypeMem  = "mem"
+	AutoScaleTypeDisk = "disk"
+	AutoScaleTypeNet  = "net"
+	AutoScaleTypeNone = "none"
+
+	//记录用户设置的阈值区间,HPA中只能保存目标值
+	autoScalerAnnotationKey = "ufleet.io/autoscaler"
+)
+
+//检查并只保留对应类型的阈值
+func (opt HPA) normalize() (HPA, error) {
+	var hpaopt HPA
+	hpaopt.MinReplicas = opt.MinReplicas
+	hpaopt.MaxReplicas = opt.MaxReplicas
+	hpaopt.Deployed = true
+	hpaopt.Type = opt.Type
+	switch opt.Type {
+	case AutoScaleTypeCPU:
+		hpaopt.MaxCPU = opt.MaxCPU
+		hpaopt.MinCPU = opt.MinCPU
+		if hpaopt.MaxCPU < hpaopt.MinCPU {
+			err := fmt.Errorf("Invalid Setting, 'Min > Max'")
+			return hpaopt, err
+		}
+
+	case AutoScaleTypeMem:
+		hpaopt.MaxMem = opt.MaxMem
+		hpaopt.MinMem = opt.MinMem
+		if hpaopt.MaxMem < hpaopt.MinMem {
+			err := fmt.Errorf("Invalid Setting, 'Min > Max'")
+			return hpaopt, err
+		}
+	case AutoScaleTypeDisk:
+		hpaopt.MaxDisk = opt.MaxDisk
+		hpaopt.MinDisk = opt.MinDisk
+		if hpaopt.MaxDisk < hpaopt.MinDisk {
+			err := fmt.Errorf("Invalid Setting, 'Min > Max'")
+			return hpaopt, err
+		}
+	case AutoScaleTypeNet:
+		hpaopt.MaxNetFlow = opt.MaxNetFlow
+		hpaopt.MinNetFlow = opt.MinNetFlow
+		if hpaopt.MaxNetFlow < hpaopt.MinNetFlow {
+			err := fmt.Errorf("Invalid Setting, 'Min > Max'")
+			return hpaopt, err
+		}
+	case AutoScaleTypeNone:
+		hpaopt.Deployed = false
+		hpaopt.MinReplicas = 0
+		hpaopt.MaxReplicas = 0
+		return hpaopt, nil
+	default:
+		err := fmt.Errorf("invalid autoscale option type")
+		return hpaopt, err
+	}
+
+	//磁盘和网络的指标只有cadvisor等来源能提供
+	if !hpaopt.InCluster() && !metrics.Supported(hpaopt.metric()) {
+		return hpaopt, fmt.Errorf("no metrics provider supports %v metrics, can't autoscale by %v", hpaopt.metric(), hpaopt.Type)
+	}
+
+	//apiserver会将未设置的minReplicas默认为1
+	if hpaopt.MinReplicas <= 0 {
+		hpaopt.MinReplicas = 1
+	}
+	if hpaopt.MaxReplicas <= 0 {
+		return hpaopt, fmt.Errorf("maxReplicas must be greater than 0")
+	}
+	if hpaopt.MinReplicas > hpaopt.MaxReplicas {
+		return hpaopt, fmt.Errorf("minReplicas must not be greater than maxReplicas")
+	}
+	return hpaopt, nil
+}
+
+//磁盘和网络伸缩使用的指标
+func (opt HPA) metric() string {
+	if opt.Type == AutoScaleTypeDisk {
+		return metrics.MetricDisk
+	}
+	return metrics.MetricNetwork
+}
+
+//cpu和内存由集群中的HPA伸缩,磁盘和网络由服务内的autoscaler伸缩
+func (opt HPA) InCluster() bool {
+	return opt.Type == AutoScaleTypeCPU || opt.Type == AutoScaleTypeMem
+}
+
+//HPA只有一个目标值,取阈值区间的中间值
+func (opt HPA) metrics() []autoscalingv2beta1.MetricSpec {
+	utilization := func(name corev1.ResourceName, min, max int) autoscalingv2beta1.MetricSpec {
+		target := int32((min + max) / 2)
+		return autoscalingv2beta1.MetricSpec{
+			Type: autoscalingv2beta1.ResourceMetricSourceType,
+			Resource: &autoscalingv2beta1.ResourceMetricSource{
+				Name:                     name,
+				TargetAverageUtilization: &target,
+			},
+		}
+	}
+
+	switch opt.Type {
+	case AutoScaleTypeCPU:
+		return []autoscalingv2beta1.MetricSpec{utilization(corev1.ResourceCPU, opt.MinCPU, opt.MaxCPU)}
+	case AutoScaleTypeMem:
+		return []autoscalingv2beta1.MetricSpec{utilization(corev1.ResourceMemory, opt.MinMem, opt.MaxMem)}
+	}
+	return nil
+}
+
+func (opt HPA) spec(name string) autoscalingv2beta1.HorizontalPodAutoscalerSpec {
+	var spec autoscalingv2beta1.HorizontalPodAutoscalerSpec
+	spec.ScaleTargetRef = autoscalingv2beta1.CrossVersionObjectReference{
+		APIVersion: "extensions/v1beta1",
+		Kind:       resourceKind,
+		Name:       name,
+	}
+	min := int32(opt.MinReplicas)
+	spec.MinReplicas = &min
+	spec.MaxReplicas = int32(opt.MaxReplicas)
+	spec.Metrics = opt.metrics()
+	return spec
+}
+
+//由集群中的HPA得到弹性伸缩设置
+//由ufleet创建且未被修改过的HPA直接使用注解中记录的阈值区间,
+//否则以目标值的80%~120%作为阈值区间
+func HPAFromV2beta1(hpa *autoscalingv2beta1.HorizontalPodAutoscaler) (*HPA, error) {
+	if data, ok := hpa.Annotations[autoScalerAnnotationKey]; ok {
+		var opt HPA
+		err := json.Unmarshal([]byte(data), &opt)
+		if err == nil && apiequality.Semantic.DeepEqual(opt.spec(hpa.Spec.ScaleTargetRef.Name), hpa.Spec) {
+			return &opt, nil
+		}
+	}
+
+	if len(hpa.Spec.Metrics) == 0 {
+		return nil, fmt.Errorf("hpa '%v' has no metrics", hpa.Name)
+	}
+
+	var opt HPA
+	opt.Deployed = true
+	if hpa.Spec.MinReplicas != nil {
+		opt.MinReplicas = int(*hpa.Spec.MinReplicas)
+	}
+	opt.MaxReplicas = int(hpa.Spec.MaxReplicas)
+
+	minOf := func(target int32) int { return int(float32(target) * 0.8) }
+	maxOf := func(target int32) int { return int(float32(target) * 1.2) }
+
+	m := hpa.Spec.Metrics[0]
+	switch {
+	case m.Resource != nil && m.Resource.TargetAverageUtilization != nil:
+		target := *m.Resource.TargetAverageUtilization
+		switch m.Resource.Name {
+		case corev1.ResourceCPU:
+			opt.Type = AutoScaleTypeCPU
+			opt.MinCPU, opt.MaxCPU = minOf(target), maxOf(target)
+		case corev1.ResourceMemory:
+			opt.Type = AutoScaleTypeMem
+			opt.MinMem, opt.MaxMem = minOf(target), maxOf(target)
+		default:
+			return nil, fmt.Errorf("resource metric '%v' is not supported", m.Resource.Name)
+		}
+	default:
+		return nil, fmt.Errorf("metric type '%v' is not supported", m.Type)
+	}
+	return &opt, nil
+}
+
+//HPA是否归属于该Deployment,同名的Deployment重建后UID不同
+func ownHPA(d *extensionsv1beta1.Deployment, hpa *autoscalingv2beta1.HorizontalPodAutoscaler) bool {
+	for _, v := range hpa.OwnerReferences {
+		if v.Kind == resourceKind && v.Name == d.Name && v.UID == d.UID {
+			return true
+		}
+	}
+	return false
+}
+
+//以Deployment为伸缩目标的HPA,不限于与Deployment同名的
+func (j *Deployment) targetingHPAs(hh cluster.HorizontalPodAutoscalerHandler) ([]string, error) {
+	hpas, err := hh.List(j.Workspace)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0)
+	for _, v := range hpas {
+		ref := v.Spec.ScaleTargetRef
+		if ref.Kind == resourceKind && ref.Name == j.Name {
+			names = append(names, v.Name)
+		}
+	}
+	return names, nil
+}
+
+//使集群中以Deployment为目标的HPA与弹性伸缩设置一致
+//HPA的owner为Deployment,删除Deployment时由集群回收
+//已经有其他HPA(如通过HPA管理创建的)以该Deployment为目标时拒绝,避免两个HPA同时伸缩
+func (j *Deployment) reconcileHPA(opt HPA) error {
+	hh, err := cluster.NewHorizontalPodAutoscalerHandler(j.Group, j.Workspace)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	dh, err := cluster.NewDeploymentHandler(j.Group, j.Workspace)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	d, err := dh.Get(j.Workspace, j.Name)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	names, err := j.targetingHPAs(hh)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	if len(names) > 1 {
+		return fmt.Errorf("hpas %v already target deployment '%v'", names, j.Name)
+	}
+
+	var old *autoscalingv2beta1.HorizontalPodAutoscaler
+	if len(names) == 1 {
+		old, err = hh.GetV2beta1(j.Workspace, names[0])
+		if err != nil {
+			return log.DebugPrint(err)
+		}
+		if !ownHPA(d, old) {
+			return fmt.Errorf("hpa '%v' already targets deployment '%v' and isn't managed by it", old.Name, j.Name)
+		}
+	}
+
+	if !opt.Deployed || !opt.InCluster() {
+		if old == nil {
+			return nil
+		}
+		err := hh.Delete(j.Workspace, old.Name)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return log.DebugPrint(err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(opt)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	spec := opt.spec(j.Name)
+
+	if old == nil {
+		var hpa autoscalingv2beta1.HorizontalPodAutoscaler
+		hpa.Name = j.Name
+		hpa.Namespace = j.Workspace
+		hpa.Annotations = map[string]string{autoScalerAnnotationKey: string(data)}
+		hpa.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "extensions/v1beta1",
+			Kind:       resourceKind,
+			Name:       d.Name,
+			UID:        d.UID,
+		}}
+		hpa.Spec = spec
+		return hh.CreateV2beta1(j.Workspace, &hpa)
+	}
+
+	if old.Annotations[autoScalerAnnotationKey] == string(data) && apiequality.Semantic.DeepEqual(old.Spec, spec) {
+		return nil
+	}
+	hpa := old.DeepCopy()
+	if hpa.Annotations == nil {
+		hpa.Annotations = make(map[string]string)
+	}
+	hpa.Annotations[autoScalerAnnotationKey] = string(data)
+	hpa.Spec = spec
+	return hh.UpdateV2beta1(j.Workspace, hpa)
+}
+
+//由集群中HPA的变化更新弹性伸缩设置,只更新记录,不再回写集群
+func (j *Deployment) SyncAutoScale(opt HPA) error {
+	if j.MemoryOnly {
+		return nil
+	}
+
+	current := j.AutoScaler
+	current.Replicas = 0
+	opt.Replicas = 0
+	if current == opt {
+		return nil
+	}
+
+	j.AutoScaler = opt
+	be := backend.NewBackendHandler()
+	err := be.UpdateResource(backendKind, j.Group, j.Workspace, j.Name, j)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	return nil
+}
+
+//修改弹性伸缩设置时持有DeploymentManager的锁,避免接口和定时伸缩并发修改
+func StartAutoScale(group, workspace, name string, opt HPA) error {
+	rm.Lock()
+	defer rm.Unlock()
+	d, err := rm.get(group, workspace, name)
+	if err != nil {
+		return err
+	}
+	return d.StartAutoScale(opt)
+}
+
+//修改已开启的弹性伸缩的副本范围,min/max为0时保持不变
+//返回修改后的设置,未开启弹性伸缩时不修改
+func UpdateAutoScaleRange(group, workspace, name string, min, max int) (*HPA, error) {
+	rm.Lock()
+	defer rm.Unlock()
+	d, err := rm.get(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	opt := d.AutoScaler
+	if !opt.Deployed || (min <= 0 && max <= 0) {
+		return &opt, nil
+	}
+	if min > 0 {
+		opt.MinReplicas = min
+	}
+	if max > 0 {
+		opt.MaxReplicas = max
+	}
+	err = d.StartAutoScale(opt)
+	if err != nil {
+		return nil, err
+	}
+	return &opt, nil
+}
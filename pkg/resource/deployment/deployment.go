@@ -9,6 +9,7 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	"ufleet-deploy/pkg/resource/util"
 	"ufleet-deploy/pkg/sign"
@@ -38,6 +39,7 @@ type DeploymentInterface interface {
 	Rollback(revision int64) (*string, error)
 	GetAutoScale() (*HPA, error)
 	StartAutoScale(HPA) error
+	SyncAutoScale(HPA) error
 	ResumeOrPauseRollOut() error
 	GetServices() ([]*corev1.Service, error)
 }
@@ -392,6 +394,11 @@ func (p *DeploymentManager) CreateObject(groupName, workspaceName string, data [
 
 	obj.ResourceVersion = ""
 
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas), nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -460,6 +467,11 @@ func (p *DeploymentManager) DeleteObject(group, workspace, resourceName string,
 	defer p.locker.Unlock()
 
 	if opt.MemoryOnly {
+		//集群中的工作负载已删除,一并清理定时伸缩策略
+		err := backend.DeleteScaleSchedule(group, resourceKind, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		return p.delete(group, workspace, resourceName)
 	}
 
@@ -489,6 +501,10 @@ func (p *DeploymentManager) DeleteObject(group, workspace, resourceName string,
 		if err != nil {
 			return log.DebugPrint(err)
 		}
+		err = backend.DeleteScaleSchedule(group, resourceKind, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		err = ph.Delete(workspace, resourceName)
 		if err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -580,6 +596,17 @@ func (p *DeploymentManager) UpdateObject(groupName, workspaceName string, resour
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.Template.Spec, quota.Replicas(newr.Spec.Replicas), &oldr.Spec.Template.Spec, quota.Replicas(oldr.Spec.Replicas))
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	opt.RecordChange(&newr.ObjectMeta, &newr.Spec.Template, &oldr.Spec.Template)
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -781,6 +808,16 @@ func (j *Deployment) Scale(num int) error {
 		return err
 	}
 
+	obj, err := jh.Get(j.Workspace, j.Name)
+	if err != nil {
+		return err
+	}
+	spec := obj.Spec.Template.Spec.DeepCopy()
+	err = quota.Admit(j.Group, j.Workspace, spec, int32(num), &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas))
+	if err != nil {
+		return err
+	}
+
 	err = jh.Scale(j.Workspace, j.Name, int32(num))
 	if err != nil {
 		return err
@@ -856,51 +893,18 @@ func (j *Deployment) Rollback(revision int64) (*string, error) {
 
 }
 
-//需要加锁
+//需要加锁,外部通过StartAutoScale/UpdateAutoScaleRange调用
+//先使集群中的HPA与设置一致,再保存设置
 func (j *Deployment) StartAutoScale(opt HPA) error {
 
 	if j.MemoryOnly != true {
-		var hpaopt HPA
-		hpaopt.MinReplicas = opt.MinReplicas
-		hpaopt.MaxReplicas = opt.MaxReplicas
-		hpaopt.Deployed = true
-		hpaopt.Type = opt.Type
-		switch opt.Type {
-		case "cpu":
-			hpaopt.MaxCPU = opt.MaxCPU
-			hpaopt.MinCPU = opt.MinCPU
-			if hpaopt.MaxCPU < hpaopt.MinCPU {
-				err := fmt.Errorf("Invalid Setting, 'Min > Max'")
-				return err
-			}
+		hpaopt, err := opt.normalize()
+		if err != nil {
+			return err
+		}
 
-		case "mem":
-			hpaopt.MaxMem = opt.MaxMem
-			hpaopt.MinMem = opt.MinMem
-			if hpaopt.MaxMem < hpaopt.MinMem {
-				err := fmt.Errorf("Invalid Setting, 'Min > Max'")
-				return err
-			}
-		case "disk":
-			hpaopt.MaxDisk = opt.MaxDisk
-			hpaopt.MinDisk = opt.MinDisk
-			if hpaopt.MaxDisk < hpaopt.MinDisk {
-				err := fmt.Errorf("Invalid Setting, 'Min > Max'")
-				return err
-			}
-		case "net":
-			hpaopt.MaxNetFlow = opt.MaxNetFlow
-			hpaopt.MinNetFlow = opt.MinNetFlow
-			if hpaopt.MaxNetFlow < hpaopt.MinNetFlow {
-				err := fmt.Errorf("Invalid Setting, 'Min > Max'")
-				return err
-			}
-		case "none":
-			hpaopt.Deployed = false
-			hpaopt.MinReplicas = 0
-			hpaopt.MaxReplicas = 0
-		default:
-			err := fmt.Errorf("invalid autoscale option type")
+		err = j.reconcileHPA(hpaopt)
+		if err != nil {
 			return err
 		}
 
@@ -908,7 +912,7 @@ func (j *Deployment) StartAutoScale(opt HPA) error {
 		log.DebugPrint(hpaopt.Deployed)
 
 		be := backend.NewBackendHandler()
-		err := be.UpdateResource(backendKind, j.Group, j.Workspace, j.Name, j)
+		err = be.UpdateResource(backendKind, j.Group, j.Workspace, j.Name, j)
 		if err != nil {
 			return log.DebugPrint(err)
 		}
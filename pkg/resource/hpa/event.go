@@ -117,11 +117,15 @@ func HandleEventWatchFromK8sCluster(echan chan cluster.Event, kind string, oc *H
 	}
 }
 
+//HPA变化时同步Deployment的弹性伸缩设置
+//Deployment.StartAutoScale创建的HPA同样会触发该事件,设置相同时不会重复保存
 func (c *HorizontalPodAutoscalerManager) setDeploymentHPA(e cluster.Event) error {
 	hpaObj, _ := e.Object.(*autoscalingv1.HorizontalPodAutoscaler)
-	hpaspec := hpaObj.Spec
+	if hpaObj == nil {
+		return nil
+	}
 
-	if hpaspec.ScaleTargetRef.Kind != "Deployment" {
+	if hpaObj.Spec.ScaleTargetRef.Kind != "Deployment" {
 		err := fmt.Errorf("hpa scale target is not deployment, not support")
 		return err
 	}
@@ -133,34 +137,35 @@ func (c *HorizontalPodAutoscalerManager) setDeploymentHPA(e cluster.Event) error
 		}
 		return err
 	}
+	di, _ := pk.GetDeploymentInterface(d)
 
-	if hpaObj.Spec.TargetCPUUtilizationPercentage != nil {
-		minCPU := int(float32(*hpaObj.Spec.TargetCPUUtilizationPercentage) * 0.8)
-		maxCPU := int(float32(*hpaObj.Spec.TargetCPUUtilizationPercentage) * 1.2)
-
-		var hpaopt pk.HPA
-		switch e.Action {
-		case cluster.ActionCreate, cluster.ActionUpdate:
-			hpaopt.Type = "cpu"
-			hpaopt.MinCPU = minCPU
-			hpaopt.MaxCPU = maxCPU
-			if hpaspec.MinReplicas != nil {
-				hpaopt.MinReplicas = int(*hpaspec.MinReplicas)
-			}
-			hpaopt.MaxReplicas = int(hpaspec.MaxReplicas)
-
-		case cluster.ActionDelete:
-			hpaopt.Type = "none"
+	var hpaopt pk.HPA
+	switch e.Action {
+	case cluster.ActionCreate, cluster.ActionUpdate:
+		//v1的对象中没有内存等指标,需获取v2beta1的对象
+		hh, err := cluster.NewHorizontalPodAutoscalerHandler(e.Group, e.Workspace)
+		if err != nil {
+			return err
 		}
-
-		di, _ := pk.GetDeploymentInterface(d)
-		err := di.StartAutoScale(hpaopt)
+		hpa, err := hh.GetV2beta1(e.Workspace, hpaObj.Name)
 		if err != nil {
 			return err
 		}
+		opt, err := pk.HPAFromV2beta1(hpa)
+		if err != nil {
+			return err
+		}
+		hpaopt = *opt
+
+	case cluster.ActionDelete:
+		//改为由autoscaler伸缩时会删除HPA,此时不需要同步
+		if !di.Info().AutoScaler.InCluster() {
+			return nil
+		}
+		hpaopt.Type = pk.AutoScaleTypeNone
 	}
 
-	return nil
+	return di.SyncAutoScale(hpaopt)
 }
 
 func (c *HorizontalPodAutoscalerManager) HandleEvent(e backend.ResourceEvent) {
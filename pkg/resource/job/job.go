@@ -9,11 +9,13 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/pod"
 	"ufleet-deploy/pkg/resource/util"
 	"ufleet-deploy/pkg/sign"
 
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
 	batchv1 "k8s.io/api/batch/v1"
@@ -23,6 +25,9 @@ import (
 var (
 	rm *JobManager
 
+	//Job控制器在Pod模板中自动添加的标签
+	jobGeneratedLabels = []string{"controller-uid", "job-name"}
+
 	Controller resource.ObjectController
 )
 
@@ -365,6 +370,12 @@ func (p *JobManager) CreateObject(groupName, workspaceName string, data []byte,
 		return log.DebugPrint("must offer one  resource json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Parallelism), nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -480,6 +491,27 @@ func (p *JobManager) DeleteObject(group, workspace, resourceName string, opt res
 	}
 }
 
+//Job的Pod模板创建后不可修改,提前返回明确的错误,而不是交给apiserver拒绝
+//比较前注入默认值,并补上控制器自动添加的标签
+func checkTemplateUnchanged(newr, oldr *batchv1.Job) error {
+	tpl := resource.DefaultPodTemplate(&newr.Spec.Template)
+	for _, k := range jobGeneratedLabels {
+		if _, ok := tpl.Labels[k]; ok {
+			continue
+		}
+		if v, ok := oldr.Spec.Template.Labels[k]; ok {
+			if tpl.Labels == nil {
+				tpl.Labels = make(map[string]string)
+			}
+			tpl.Labels[k] = v
+		}
+	}
+	if !apiequality.Semantic.DeepEqual(tpl, &oldr.Spec.Template) {
+		return fmt.Errorf("pod template of Job '%v' is immutable, delete and recreate the Job to change it", newr.Name)
+	}
+	return nil
+}
+
 func (p *JobManager) UpdateObject(groupName, workspaceName string, resourceName string, data []byte, opt resource.UpdateOption) error {
 	p.locker.Lock()
 	defer p.locker.Unlock()
@@ -516,6 +548,19 @@ func (p *JobManager) UpdateObject(groupName, workspaceName string, resourceName
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.Template.Spec, quota.Replicas(newr.Spec.Parallelism), &oldr.Spec.Template.Spec, quota.Replicas(oldr.Spec.Parallelism))
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = checkTemplateUnchanged(&newr, oldr)
+	if err != nil {
+		return err
+	}
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -0,0 +1,156 @@
+package pod
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+)
+
+//容器中的路径必须是绝对路径
+func cleanContainerPath(p string) (string, error) {
+	if !strings.HasPrefix(p, "/") {
+		return "", fmt.Errorf("path '%v' must be absolute", p)
+	}
+	return path.Clean(p), nil
+}
+
+//错误信息中带上命令的stderr
+func execError(err error, stderr *bytes.Buffer) error {
+	if err == nil {
+		return nil
+	}
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("%v: %v", err, msg)
+	}
+	return err
+}
+
+//转发到Pod的端口
+func (p *Pod) PortForward(port int, stream io.ReadWriter) error {
+	if port <= 0 || port > 65535 {
+		return fmt.Errorf("invalid port %v", port)
+	}
+	err := p.checkRunning("")
+	if err != nil {
+		return err
+	}
+
+	ph, err := cluster.NewPodHandler(p.Group, p.Workspace)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	return ph.PortForward(p.Workspace, p.Name, port, stream)
+}
+
+//将容器中的文件或目录打包为tar写入w,容器中需要有tar命令
+func (p *Pod) CopyFrom(containerName string, srcPath string, w io.Writer) error {
+	src, err := cleanContainerPath(srcPath)
+	if err != nil {
+		return err
+	}
+	err = p.checkRunning(containerName)
+	if err != nil {
+		return err
+	}
+
+	ph, err := cluster.NewPodHandler(p.Group, p.Workspace)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	var stderr bytes.Buffer
+	var opt cluster.ExecOption
+	opt.Container = containerName
+	opt.Command = []string{"tar", "cf", "-", "-C", path.Dir(src), path.Base(src)}
+	opt.Stdout = w
+	opt.Stderr = &stderr
+	err = ph.Exec(p.Workspace, p.Name, opt)
+	return execError(err, &stderr)
+}
+
+//将tar包解压到容器的目录中,目录不存在时创建,容器中需要有sh和tar命令
+func (p *Pod) CopyTo(containerName string, destDir string, r io.Reader) error {
+	dest, err := cleanContainerPath(destDir)
+	if err != nil {
+		return err
+	}
+	err = p.checkRunning(containerName)
+	if err != nil {
+		return err
+	}
+
+	ph, err := cluster.NewPodHandler(p.Group, p.Workspace)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	var opt cluster.ExecOption
+	opt.Container = containerName
+	//目录作为参数传递,避免被shell解析
+	opt.Command = []string{"sh", "-c", `mkdir -p "$1" && tar xf - -C "$1"`, "sh", dest}
+	opt.Stdin = r
+	opt.Stdout = &stdout
+	opt.Stderr = &stderr
+	err = ph.Exec(p.Workspace, p.Name, opt)
+	return execError(err, &stderr)
+}
+
+//将多个文件打包为tar,文件名作为tar中的路径
+func TarFiles(files map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	now := time.Now()
+	for _, name := range names {
+		data := files[name]
+		hdr := tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  now,
+		}
+		err := tw.WriteHeader(&hdr)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tw.Write(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := tw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+//tar包中的文件名,用于审计
+func TarNames(archive []byte) ([]string, error) {
+	names := make([]string, 0)
+	tr := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid tar archive: %v", err)
+		}
+		names = append(names, hdr.Name)
+	}
+}
@@ -3,16 +3,18 @@ package pod
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
 	"time"
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	"ufleet-deploy/pkg/resource/util"
 	"ufleet-deploy/pkg/sign"
-	cadvisor "ufleet-deploy/util/cadvisor"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	//"k8s.io/apis/pkg/api/errors"
@@ -31,8 +33,13 @@ type PodInterface interface {
 	GetStatus() *Status
 	GetTemplate() (string, error)
 	Log(c string) (string, error)
-	Stat(c string) ([]ContainerStat, error)
-	Terminal(containerName string) (string, error)
+	LogStream(c string, opt cluster.LogOption) (io.ReadCloser, error)
+	Stat(c string) (*metrics.Series, error)
+	StatPod(step time.Duration) (*metrics.PodSeries, error)
+	Terminal(opt cluster.ExecOption) error
+	PortForward(port int, stream io.ReadWriter) error
+	CopyFrom(containerName string, srcPath string, w io.Writer) error
+	CopyTo(containerName string, destDir string, r io.Reader) error
 	Event() ([]corev1.Event, error)
 	GetServices() ([]*corev1.Service, error)
 }
@@ -375,6 +382,12 @@ func (p *PodManager) CreateObject(groupName, workspaceName string, data []byte,
 		return log.DebugPrint("must and  offer one resource json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	err = quota.Admit(groupName, workspaceName, &obj.Spec, 1, nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -873,85 +886,73 @@ func (p *Pod) Log(containerName string) (string, error) {
 
 }
 
-type ContainerStat struct {
-	cadvisor.ContainerStat
-}
-
-func (p *Pod) Terminal(containerName string) (string, error) {
-	c, err := cluster.Controller.GetCluster(p.Group, p.Workspace)
-	if err != nil {
-		return "", log.DebugPrint(err)
-	}
-	runtime, err := p.GetRuntime()
+func (p *Pod) LogStream(containerName string, opt cluster.LogOption) (io.ReadCloser, error) {
+	ph, err := cluster.NewPodHandler(p.Group, p.Workspace)
 	if err != nil {
-		return "", log.DebugPrint(err)
-	}
-
-	var found bool
-	for _, v := range runtime.Pod.Spec.Containers {
-		if v.Name == containerName {
-			found = true
-		}
-	}
-	if !found {
-		return "", fmt.Errorf("container not exist in pod %v ", p.Name)
+		return nil, log.DebugPrint(err)
 	}
 
-	token := "1234567890987654321"
-
-	url, err := cluster.GetTerminalUrl(p.Group, p.Workspace, p.Name, containerName, runtime.Pod.Status.HostIP, c.Name, token)
+	rc, err := ph.LogStream(p.Workspace, p.Name, containerName, opt)
 	if err != nil {
-		return "", err
+		return nil, log.DebugPrint(err)
 	}
-
-	return url, nil
+	return rc, nil
 }
 
-func (p *Pod) Stat(containerName string) ([]ContainerStat, error) {
+//检查Pod是否正在运行,container不为空时检查容器是否存在
+func (p *Pod) checkRunning(container string) error {
 	runtime, err := p.GetRuntime()
 	if err != nil {
-		return nil, err
+		return log.DebugPrint(err)
 	}
 
-	var containerID string
-
-	for _, v := range runtime.Pod.Status.ContainerStatuses {
-		if v.Name == containerName {
-			containerID = v.ContainerID
+	if container != "" {
+		var found bool
+		for _, v := range runtime.Pod.Spec.Containers {
+			if v.Name == container {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("container not exist in pod %v ", p.Name)
 		}
 	}
-
-	if len(containerID) == 0 {
-		return nil, fmt.Errorf("container not found")
+	if runtime.Pod.Status.Phase != corev1.PodRunning {
+		return fmt.Errorf("pod %v is %v, not running", p.Name, runtime.Pod.Status.Phase)
 	}
+	return nil
+}
 
-	dockerPrefix := "docker://"
-	if !strings.HasPrefix(containerID, dockerPrefix) {
-		return nil, fmt.Errorf("only support docker container")
+//在容器中打开终端,直到终端退出或者流被关闭
+func (p *Pod) Terminal(opt cluster.ExecOption) error {
+	err := p.checkRunning(opt.Container)
+	if err != nil {
+		return err
 	}
 
-	id := strings.TrimPrefix(containerID, dockerPrefix)
-	cadvisorID := "/docker/" + id
-
-	cadvisorPort := "4194"
-	url := "http://" + runtime.Pod.Status.HostIP + ":" + cadvisorPort + "/"
-
-	manager, err := cadvisor.NewManager(url)
+	ph, err := cluster.NewPodHandler(p.Group, p.Workspace)
 	if err != nil {
-		return nil, err
+		return log.DebugPrint(err)
 	}
-	cstats, err := manager.GetContainerStats(cadvisorID)
+	return ph.Exec(p.Workspace, p.Name, opt)
+}
+
+//容器的资源使用
+func (p *Pod) Stat(containerName string) (*metrics.Series, error) {
+	runtime, err := p.GetRuntime()
 	if err != nil {
 		return nil, err
 	}
+	return metrics.ContainerSeries(p.Group, runtime.Pod, containerName)
+}
 
-	stats := make([]ContainerStat, 0)
-	for _, v := range cstats {
-		cs := ContainerStat{v}
-		stats = append(stats, cs)
-
+//Pod中所有容器的资源使用
+func (p *Pod) StatPod(step time.Duration) (*metrics.PodSeries, error) {
+	runtime, err := p.GetRuntime()
+	if err != nil {
+		return nil, err
 	}
-	return stats, nil
+	return metrics.PodSeriesOf(p.Group, runtime.Pod, step)
 }
 
 func (p *Pod) Event() ([]corev1.Event, error) {
@@ -0,0 +1,298 @@
+package pullsecret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/secret"
+	"ufleet-deploy/pkg/resource/util"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/pkg/user"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	managedSecretPrefix = "registry-"
+	defaultRegistryHost = "docker.io"
+)
+
+var (
+	invalidNameChars = regexp.MustCompile("[^a-z0-9-]+")
+)
+
+//组是否开启了自动注入
+func Enabled(group string) (bool, error) {
+	pp, err := backend.GetPullSecretPolicy(group)
+	if err != nil {
+		return false, err
+	}
+	return pp.Enabled, nil
+}
+
+//仓库地址去掉协议和路径,如http://192.168.18.250:5002/ -> 192.168.18.250:5002
+func RegistryHost(address string) string {
+	h := strings.TrimSpace(strings.ToLower(address))
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	if i := strings.Index(h, "/"); i >= 0 {
+		h = h[:i]
+	}
+	return h
+}
+
+//镜像的仓库地址,没有指定仓库的镜像属于docker.io
+func ImageRegistryHost(image string) string {
+	i := strings.Index(image, "/")
+	if i < 0 {
+		return defaultRegistryHost
+	}
+	h := image[:i]
+	if !strings.ContainsAny(h, ".:") && h != "localhost" {
+		return defaultRegistryHost
+	}
+	return strings.ToLower(h)
+}
+
+//托管的Secret名称,由仓库名转换为合法的资源名
+func ManagedSecretName(registry string) string {
+	n := invalidNameChars.ReplaceAllString(strings.ToLower(registry), "-")
+	n = strings.Trim(n, "-")
+	if n == "" {
+		n = "default"
+	}
+	name := managedSecretPrefix + n
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	return name
+}
+
+type injector struct {
+	group     string
+	workspace string
+	user      string
+	//仓库地址到仓库的映射
+	registries map[string]user.Registry
+	//仓库名到Secret名的缓存
+	secrets map[string]string
+	//工作区中已存在的Secret
+	existing []*corev1.Secret
+}
+
+func newInjector(group, workspace, who string, regs []user.Registry) *injector {
+	ij := injector{group: group, workspace: workspace, user: who}
+	ij.registries = make(map[string]user.Registry)
+	for _, v := range regs {
+		ij.registries[RegistryHost(v.Address)] = v
+	}
+	ij.secrets = make(map[string]string)
+	return &ij
+}
+
+func (ij *injector) listExisting() error {
+	if ij.existing != nil {
+		return nil
+	}
+	ph, err := cluster.NewSecretHandler(ij.group, ij.workspace)
+	if err != nil {
+		return err
+	}
+	ss, err := ph.List(ij.workspace)
+	if err != nil {
+		return err
+	}
+	ij.existing = ss
+	return nil
+}
+
+//确保工作区中存在仓库的dockercfg类型的Secret,返回Secret名
+//优先使用已有的由该仓库生成的Secret
+func (ij *injector) ensureSecret(reg user.Registry) (string, error) {
+	if name, ok := ij.secrets[reg.Name]; ok {
+		return name, nil
+	}
+
+	err := ij.listExisting()
+	if err != nil {
+		return "", err
+	}
+	name := ManagedSecretName(reg.Name)
+	for _, v := range ij.existing {
+		if secret.IsFromRegistry(v, reg.ID, reg.Name) {
+			ij.secrets[reg.Name] = v.Name
+			return v.Name, nil
+		}
+	}
+	for _, v := range ij.existing {
+		if v.Name == name {
+			return "", fmt.Errorf("secret '%v' already exists but isn't generated from registry '%v'", name, reg.Name)
+		}
+	}
+
+	data, err := secret.NewDockercfgData(reg.Address, reg.User, reg.Password, reg.Email)
+	if err != nil {
+		return "", err
+	}
+	s := corev1.Secret{}
+	s.Kind = "Secret"
+	s.APIVersion = "v1"
+	s.Name = name
+	s.Type = corev1.SecretTypeDockercfg
+	secret.SetRegistry(&s, reg.ID, reg.Name)
+	s.Data = map[string][]byte{corev1.DockerConfigKey: data}
+	bs, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	var opt resource.CreateOption
+	opt.User = ij.user
+	opt.Comment = fmt.Sprintf("pull secret of registry \"%v\"", reg.Name)
+	err = secret.Controller.CreateObject(ij.group, ij.workspace, bs, opt)
+	if err != nil {
+		//可能同时被其他请求创建
+		if _, err2 := secret.Controller.GetObject(ij.group, ij.workspace, name); err2 != nil {
+			return "", err
+		}
+	}
+	log.DebugPrint("pull secret \"%v/%v/%v\" of registry \"%v\" created", ij.group, ij.workspace, name, reg.Name)
+	ij.secrets[reg.Name] = name
+	return name, nil
+}
+
+func hasPullSecret(spec *corev1.PodSpec, name string) bool {
+	for _, v := range spec.ImagePullSecrets {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+//为Pod中使用组镜像仓库的镜像添加imagePullSecrets,返回是否有修改
+func (ij *injector) injectPodSpec(spec *corev1.PodSpec) (bool, error) {
+	cs := make([]corev1.Container, 0, len(spec.InitContainers)+len(spec.Containers))
+	cs = append(cs, spec.InitContainers...)
+	cs = append(cs, spec.Containers...)
+
+	changed := false
+	for _, c := range cs {
+		reg, ok := ij.registries[ImageRegistryHost(c.Image)]
+		if !ok {
+			continue
+		}
+		name, err := ij.ensureSecret(reg)
+		if err != nil {
+			return false, err
+		}
+		if hasPullSecret(spec, name) {
+			continue
+		}
+		spec.ImagePullSecrets = append(spec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+		changed = true
+	}
+	return changed, nil
+}
+
+//工作负载的模板中Pod spec的路径
+func podSpecPath(kind string) []string {
+	if kind == workload.KindCronJob {
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	return []string{"spec", "template", "spec"}
+}
+
+//只修改模板中的imagePullSecrets,其他字段原样保留
+//不通过结构体重新序列化,避免丢失结构体中没有的字段
+func patchPullSecrets(raw []byte, path []string, secrets []corev1.LocalObjectReference) ([]byte, error) {
+	doc := make(map[string]interface{})
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	err := d.Decode(&doc)
+	if err != nil {
+		return nil, err
+	}
+
+	m := doc
+	for _, k := range path {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			m[k] = next
+		}
+		m = next
+	}
+	m["imagePullSecrets"] = secrets
+	return json.Marshal(doc)
+}
+
+//处理工作负载/应用的模板,模板中可以有多个资源
+//没有修改时返回原模板,有修改时返回JSON格式的模板
+func Inject(group, workspace, who string, regs []user.Registry, data []byte) ([]byte, error) {
+	if len(regs) == 0 {
+		return data, nil
+	}
+	exts, err := util.ParseJsonOrYaml(data)
+	if err != nil {
+		return nil, err
+	}
+
+	ij := newInjector(group, workspace, who, regs)
+	changed := false
+	docs := make([][]byte, 0, len(exts))
+	for _, ext := range exts {
+		var tm struct {
+			Kind string `json:"kind"`
+		}
+		err := json.Unmarshal(ext.Raw, &tm)
+		if err != nil {
+			return nil, err
+		}
+		if !workload.IsWorkloadKind(tm.Kind) {
+			docs = append(docs, ext.Raw)
+			continue
+		}
+
+		obj, err := workload.NewObject(tm.Kind)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(ext.Raw, obj)
+		if err != nil {
+			return nil, err
+		}
+		tpl, err := workload.PodTemplateOf(obj)
+		if err != nil {
+			return nil, err
+		}
+		c, err := ij.injectPodSpec(&tpl.Spec)
+		if err != nil {
+			return nil, err
+		}
+		if !c {
+			docs = append(docs, ext.Raw)
+			continue
+		}
+
+		raw, err := patchPullSecrets(ext.Raw, podSpecPath(tm.Kind), tpl.Spec.ImagePullSecrets)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, raw)
+		changed = true
+	}
+
+	if !changed {
+		return data, nil
+	}
+	//多个JSON对象依次排列即可被ParseJsonOrYaml解析
+	return bytes.Join(docs, []byte("\n")), nil
+}
@@ -9,6 +9,7 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	pk "ufleet-deploy/pkg/resource/pod"
 	"ufleet-deploy/pkg/resource/util"
@@ -367,6 +368,15 @@ func (p *ReplicationControllerManager) CreateObject(groupName, workspaceName str
 		return log.DebugPrint("must and  offer one rc json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	if obj.Spec.Template == nil {
+		return log.DebugPrint("replicationcontroller must have pod template")
+	}
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas), nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -434,6 +444,11 @@ func (p *ReplicationControllerManager) DeleteObject(group, workspace, replicatio
 	defer p.locker.Unlock()
 
 	if opt.MemoryOnly {
+		//集群中的工作负载已删除,一并清理定时伸缩策略
+		err := backend.DeleteScaleSchedule(group, resourceKind, workspace, replicationcontrollerName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		return p.delete(group, workspace, replicationcontrollerName)
 	}
 
@@ -462,6 +477,10 @@ func (p *ReplicationControllerManager) DeleteObject(group, workspace, replicatio
 		if err != nil {
 			return log.DebugPrint(err)
 		}
+		err = backend.DeleteScaleSchedule(group, resourceKind, workspace, replicationcontrollerName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		err = ph.Delete(workspace, replicationcontrollerName)
 		if err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -526,6 +545,20 @@ func (p *ReplicationControllerManager) UpdateObject(groupName, workspaceName str
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	if newr.Spec.Template == nil || oldr.Spec.Template == nil {
+		return log.DebugPrint("replicationcontroller must have pod template")
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.Template.Spec, quota.Replicas(newr.Spec.Replicas), &oldr.Spec.Template.Spec, quota.Replicas(oldr.Spec.Replicas))
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	opt.RecordChange(&newr.ObjectMeta, newr.Spec.Template, oldr.Spec.Template)
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -730,6 +763,18 @@ func (j *ReplicationController) Scale(num int) error {
 		return err
 	}
 
+	obj, err := jh.Get(j.Workspace, j.Name)
+	if err != nil {
+		return err
+	}
+	if obj.Spec.Template != nil {
+		spec := obj.Spec.Template.Spec.DeepCopy()
+		err = quota.Admit(j.Group, j.Workspace, spec, int32(num), &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas))
+		if err != nil {
+			return err
+		}
+	}
+
 	err = jh.Scale(j.Workspace, j.Name, int32(num))
 	if err != nil {
 		return err
@@ -47,7 +47,9 @@ type DeleteOption struct {
 }
 
 type UpdateOption struct {
-	Comment string //注释
+	Comment   string //注释
+	User      string //更新的用户
+	Operation string //操作,工作负载的修改记录使用
 }
 
 //抽象,便于app使用
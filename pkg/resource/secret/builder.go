@@ -0,0 +1,294 @@
+package secret
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+	"ufleet-deploy/pkg/resource/util"
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	TLSCAKey             = "ca.crt"
+	SSHAuthKnownHostsKey = "known_hosts"
+)
+
+type TLSOption struct {
+	Cert string `json:"cert"`         //PEM格式的证书(链)
+	Key  string `json:"key"`          //PEM格式的私钥
+	CA   string `json:"ca,omitempty"` //可选,PEM格式的CA证书
+}
+
+type BasicAuthOption struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type SSHAuthOption struct {
+	PrivateKey string `json:"privatekey"`           //PEM格式的私钥
+	KnownHosts string `json:"knownhosts,omitempty"` //可选
+}
+
+//.dockercfg中一个镜像仓库的账号
+type DockerRegistryAccount struct {
+	User     string `json:"username"`
+	Password string `json:"password"`
+	Email    string `json:"email"`
+	Auth     string `json:"auth"`
+}
+
+//记录Secret的来源仓库,仓库更新时重新生成
+func SetRegistry(s *corev1.Secret, id, name string) {
+	if s.Annotations == nil {
+		s.Annotations = make(map[string]string)
+	}
+	s.Annotations[sign.SignUfleetRegistryID] = id
+	s.Annotations[sign.SignUfleetRegistry] = name
+}
+
+//Secret是否由该镜像仓库生成:按仓库ID匹配,没有记录ID的旧Secret按仓库名匹配
+func IsFromRegistry(s *corev1.Secret, id, name string) bool {
+	if s.Type != corev1.SecretTypeDockercfg {
+		return false
+	}
+	if v, ok := s.Annotations[sign.SignUfleetRegistryID]; ok {
+		return v == id
+	}
+	return s.Annotations[sign.SignUfleetRegistry] == name
+}
+
+//生成只包含一个镜像仓库账号的.dockercfg
+func NewDockercfgData(address, user, password, email string) ([]byte, error) {
+	ra := DockerRegistryAccount{
+		User:     user,
+		Password: password,
+		Email:    email,
+	}
+	ra.Auth = base64.StdEncoding.EncodeToString([]byte(user + ":" + password))
+
+	account := make(map[string]DockerRegistryAccount)
+	account[address] = ra
+	return json.Marshal(account)
+}
+
+//证书信息,用于展示TLS类型Secret的有效期和SAN
+type CertificateInfo struct {
+	Subject     string   `json:"subject"`
+	Issuer      string   `json:"issuer"`
+	SerialNo    string   `json:"serialno"`
+	NotBefore   int64    `json:"notbefore"`
+	NotAfter    int64    `json:"notafter"`
+	Expired     bool     `json:"expired"`
+	ExpireDays  int64    `json:"expiredays"` //剩余天数,已过期时为负数
+	DNSNames    []string `json:"dnsnames"`
+	IPAddresses []string `json:"ipaddresses"`
+	IsCA        bool     `json:"isca"`
+}
+
+func newSecret(name string, t corev1.SecretType) *corev1.Secret {
+	s := corev1.Secret{}
+	s.Name = name
+	s.Kind = resourceKind
+	s.APIVersion = "v1"
+	s.Type = t
+	s.Data = make(map[string][]byte)
+	return &s
+}
+
+func decodePEMBlocks(data []byte, what string) ([]*pem.Block, error) {
+	blocks := make([]*pem.Block, 0)
+	rest := data
+	for {
+		var b *pem.Block
+		b, rest = pem.Decode(rest)
+		if b == nil {
+			break
+		}
+		blocks = append(blocks, b)
+	}
+	if len(blocks) == 0 {
+		return nil, fmt.Errorf("%v is not valid PEM data", what)
+	}
+	if strings.TrimSpace(string(rest)) != "" {
+		return nil, fmt.Errorf("%v contains non-PEM data", what)
+	}
+	return blocks, nil
+}
+
+func ParseCertificateInfo(c *x509.Certificate) *CertificateInfo {
+	var ci CertificateInfo
+	ci.Subject = c.Subject.CommonName
+	ci.Issuer = c.Issuer.CommonName
+	ci.SerialNo = c.SerialNumber.String()
+	ci.NotBefore = c.NotBefore.Unix()
+	ci.NotAfter = c.NotAfter.Unix()
+	ci.IsCA = c.IsCA
+
+	left := c.NotAfter.Sub(time.Now())
+	ci.Expired = left <= 0
+	ci.ExpireDays = int64(left / (24 * time.Hour))
+
+	ci.DNSNames = make([]string, 0)
+	ci.DNSNames = append(ci.DNSNames, c.DNSNames...)
+	ci.IPAddresses = make([]string, 0)
+	for _, ip := range c.IPAddresses {
+		ci.IPAddresses = append(ci.IPAddresses, ip.String())
+	}
+	return &ci
+}
+
+//解析PEM中的第一个证书(证书链中的叶子证书)
+func ParseCertificatePEM(data []byte) (*CertificateInfo, error) {
+	blocks, err := decodePEMBlocks(data, "certificate")
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.Type != "CERTIFICATE" {
+			continue
+		}
+		c, err := x509.ParseCertificate(b.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate fail for %v", err)
+		}
+		return ParseCertificateInfo(c), nil
+	}
+	return nil, fmt.Errorf("certificate PEM doesn't contain a CERTIFICATE block")
+}
+
+//校验证书和私钥是否匹配
+func NewTLSSecret(name string, opt TLSOption) (*corev1.Secret, error) {
+	if strings.TrimSpace(opt.Cert) == "" || strings.TrimSpace(opt.Key) == "" {
+		return nil, fmt.Errorf("tls secret must offer cert and key")
+	}
+	_, err := ParseCertificatePEM([]byte(opt.Cert))
+	if err != nil {
+		return nil, err
+	}
+	_, err = tls.X509KeyPair([]byte(opt.Cert), []byte(opt.Key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid tls cert/key pair: %v", err)
+	}
+
+	s := newSecret(name, corev1.SecretTypeTLS)
+	s.Data[corev1.TLSCertKey] = []byte(opt.Cert)
+	s.Data[corev1.TLSPrivateKeyKey] = []byte(opt.Key)
+	if strings.TrimSpace(opt.CA) != "" {
+		_, err := ParseCertificatePEM([]byte(opt.CA))
+		if err != nil {
+			return nil, fmt.Errorf("invalid ca: %v", err)
+		}
+		s.Data[TLSCAKey] = []byte(opt.CA)
+	}
+	return s, nil
+}
+
+func NewBasicAuthSecret(name string, opt BasicAuthOption) (*corev1.Secret, error) {
+	if opt.Username == "" && opt.Password == "" {
+		return nil, fmt.Errorf("basic-auth secret must offer username or password")
+	}
+	s := newSecret(name, corev1.SecretTypeBasicAuth)
+	s.Data[corev1.BasicAuthUsernameKey] = []byte(opt.Username)
+	s.Data[corev1.BasicAuthPasswordKey] = []byte(opt.Password)
+	return s, nil
+}
+
+func validatePrivateKey(b *pem.Block) error {
+	var err error
+	switch b.Type {
+	case "RSA PRIVATE KEY":
+		_, err = x509.ParsePKCS1PrivateKey(b.Bytes)
+	case "EC PRIVATE KEY":
+		_, err = x509.ParseECPrivateKey(b.Bytes)
+	case "PRIVATE KEY":
+		_, err = x509.ParsePKCS8PrivateKey(b.Bytes)
+	case "OPENSSH PRIVATE KEY", "DSA PRIVATE KEY":
+		//无法在这里解析,只校验PEM格式
+	default:
+		return fmt.Errorf("unsupported private key type \"%v\"", b.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("parse private key fail for %v", err)
+	}
+	if _, ok := b.Headers["Proc-Type"]; ok {
+		return fmt.Errorf("encrypted private key is not supported")
+	}
+	return nil
+}
+
+func NewSSHAuthSecret(name string, opt SSHAuthOption) (*corev1.Secret, error) {
+	if strings.TrimSpace(opt.PrivateKey) == "" {
+		return nil, fmt.Errorf("ssh-auth secret must offer private key")
+	}
+	blocks, err := decodePEMBlocks([]byte(opt.PrivateKey), "private key")
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) != 1 {
+		return nil, fmt.Errorf("private key must contain exactly one PEM block")
+	}
+	err = validatePrivateKey(blocks[0])
+	if err != nil {
+		return nil, err
+	}
+
+	s := newSecret(name, corev1.SecretTypeSSHAuth)
+	s.Data[corev1.SSHAuthPrivateKey] = []byte(opt.PrivateKey)
+	if strings.TrimSpace(opt.KnownHosts) != "" {
+		s.Data[SSHAuthKnownHostsKey] = []byte(opt.KnownHosts)
+	}
+	return s, nil
+}
+
+//由多个文件创建Opaque类型的Secret,文件名作为key
+func NewOpaqueSecretFromFiles(name string, files map[string][]byte) (*corev1.Secret, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("must offer at least one file")
+	}
+	s := newSecret(name, corev1.SecretTypeOpaque)
+	size := 0
+	for k, v := range files {
+		errs := validation.IsConfigMapKey(k)
+		if len(errs) != 0 {
+			return nil, fmt.Errorf("invalid file name \"%v\": %v", k, strings.Join(errs, ","))
+		}
+		size += len(v)
+		s.Data[k] = v
+	}
+	if size > corev1.MaxSecretSize {
+		return nil, fmt.Errorf("secret data size %v exceed limit %v", size, corev1.MaxSecretSize)
+	}
+	return s, nil
+}
+
+//隐藏Secret的数据,只保留key
+func RedactSecret(s *corev1.Secret) *corev1.Secret {
+	r := s.DeepCopy()
+	keys := secretData(s)
+	r.Data = nil
+	r.StringData = make(map[string]string)
+	for k := range keys {
+		r.StringData[k] = redactedValue
+	}
+	return r
+}
+
+//隐藏数据后的模板
+func GetRedactedTemplate(s *corev1.Secret) (string, error) {
+	r := RedactSecret(s)
+	r.Kind = resourceKind
+	r.APIVersion = "v1"
+	t, err := util.GetYamlTemplateFromObject(r)
+	if err != nil {
+		return "", err
+	}
+	return *t, nil
+}
@@ -0,0 +1,257 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/util"
+	"ufleet-deploy/pkg/resource/workload"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	redactedValue = "******"
+)
+
+//历史版本只对外展示key,值被隐藏
+type Revision struct {
+	Version int64             `json:"version"`
+	User    string            `json:"user"`
+	Time    int64             `json:"time"`
+	Comment string            `json:"comment"`
+	Keys    []string          `json:"keys"`
+	Data    map[string]string `json:"data"`
+}
+
+//stringData会覆盖data中相同的key
+func secretData(s *corev1.Secret) map[string][]byte {
+	data := make(map[string][]byte)
+	for k, v := range s.Data {
+		data[k] = v
+	}
+	for k, v := range s.StringData {
+		data[k] = []byte(v)
+	}
+	return data
+}
+
+func equalData(a, b map[string][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		bv, ok := b[k]
+		if !ok || !bytes.Equal(v, bv) {
+			return false
+		}
+	}
+	return true
+}
+
+//Secret的历史数据加密后保存
+func toBackendRevision(data map[string][]byte, user string, t int64, comment string) (*backend.Revision, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := backend.EncryptData(bs)
+	if err != nil {
+		return nil, err
+	}
+	return &backend.Revision{User: user, Time: t, Comment: comment, Data: enc}, nil
+}
+
+//没有配置密钥时历史数据是明文
+func decryptRevisionData(br backend.Revision) (map[string][]byte, error) {
+	bs := []byte(br.Data)
+	if backend.IsEncrypted(br.Data) {
+		var err error
+		bs, err = backend.DecryptData(br.Data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	data := make(map[string][]byte)
+	err := json.Unmarshal(bs, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func redactRevision(br backend.Revision) (*Revision, error) {
+	data, err := decryptRevisionData(br)
+	if err != nil {
+		return nil, err
+	}
+	r := Revision{Version: br.Version, User: br.User, Time: br.Time, Comment: br.Comment}
+	r.Keys = make([]string, 0, len(data))
+	r.Data = make(map[string]string)
+	for k := range data {
+		r.Keys = append(r.Keys, k)
+		r.Data[k] = redactedValue
+	}
+	sort.Strings(r.Keys)
+	return &r, nil
+}
+
+//更新成功后记录新版本,没有历史时先记录更新前的数据作为第一个版本
+//数据没有变化时不记录
+func recordHistory(old *Secret, oldr *corev1.Secret, newr *corev1.Secret, opt resource.UpdateOption) error {
+	rs, err := backend.GetHistory(backendKind, old.Group, old.Workspace, old.Name)
+	if err != nil {
+		return err
+	}
+
+	newData := secretData(newr)
+	brs := make([]backend.Revision, 0)
+	if len(rs) == 0 {
+		if oldr != nil {
+			oldData := secretData(oldr)
+			if !equalData(oldData, newData) {
+				or, err := toBackendRevision(oldData, old.User, old.CreateTime, old.Comment)
+				if err != nil {
+					return err
+				}
+				brs = append(brs, *or)
+			}
+		}
+	} else {
+		lastData, err := decryptRevisionData(rs[len(rs)-1])
+		if err != nil {
+			return err
+		}
+		if equalData(lastData, newData) {
+			return nil
+		}
+	}
+
+	nr, err := toBackendRevision(newData, opt.User, time.Now().Unix(), opt.Comment)
+	if err != nil {
+		return err
+	}
+	brs = append(brs, *nr)
+
+	return backend.AppendHistory(backendKind, old.Group, old.Workspace, old.Name, brs...)
+}
+
+//按版本从旧到新返回,数据被隐藏
+func ListHistory(group, workspace, name string) ([]Revision, error) {
+	_, err := rm.GetObject(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	brs, err := backend.GetHistory(backendKind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rs := make([]Revision, 0, len(brs))
+	for _, v := range brs {
+		r, err := redactRevision(v)
+		if err != nil {
+			return nil, log.DebugPrint(err)
+		}
+		rs = append(rs, *r)
+	}
+	return rs, nil
+}
+
+func getRevisionData(group, workspace, name string, version int64) (map[string][]byte, error) {
+	br, err := backend.GetRevision(backendKind, group, workspace, name, version)
+	if err != nil {
+		return nil, err
+	}
+	return decryptRevisionData(*br)
+}
+
+func getRuntime(group, workspace, name string) (*Runtime, error) {
+	obj, err := rm.GetObject(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	si, err := GetSecretInterface(obj)
+	if err != nil {
+		return nil, err
+	}
+	return si.GetRuntime()
+}
+
+//比较两个版本的数据,to为0时与当前数据比较
+//只返回变化的key,不返回值
+func DiffRevision(group, workspace, name string, from, to int64) ([]util.DataChange, error) {
+	fromData, err := getRevisionData(group, workspace, name, from)
+	if err != nil {
+		return nil, err
+	}
+
+	var toData map[string][]byte
+	if to == 0 {
+		runtime, err := getRuntime(group, workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		toData = secretData(runtime.Secret)
+	} else {
+		toData, err = getRevisionData(group, workspace, name, to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	toStrings := func(data map[string][]byte) map[string]string {
+		m := make(map[string]string)
+		for k, v := range data {
+			m[k] = string(v)
+		}
+		return m
+	}
+	cs := util.DiffData(toStrings(fromData), toStrings(toData))
+	for k := range cs {
+		cs[k].Old = ""
+		cs[k].New = ""
+		cs[k].Diff = ""
+	}
+	return cs, nil
+}
+
+//将数据恢复到指定版本,恢复本身也会产生一个新版本
+func Restore(group, workspace, name string, version int64, opt resource.UpdateOption) ([]workload.ReloadResult, error) {
+	data, err := getRevisionData(group, workspace, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime, err := getRuntime(group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+
+	newr := runtime.Secret.DeepCopy()
+	newr.Kind = resourceKind
+	newr.APIVersion = "v1"
+	newr.Data = data
+	newr.StringData = nil
+
+	bs, err := json.Marshal(newr)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	if opt.Comment == "" {
+		opt.Comment = fmt.Sprintf("restore from version %v", version)
+	}
+	err = Controller.UpdateObject(group, workspace, name, bs, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	return Reload(group, workspace, name, opt)
+}
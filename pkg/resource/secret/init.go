@@ -24,6 +24,10 @@ func Init() {
 	if err != nil {
 		panic(err.Error())
 	}
+	err = watchRegistryChange()
+	if err != nil {
+		panic(err.Error())
+	}
 
 	go resource.HandleEventWatchFromK8sCluster(cluster.SecretEventChan, resourceKind, rm)
 }
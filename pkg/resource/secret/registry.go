@@ -0,0 +1,162 @@
+package secret
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+	"ufleet-deploy/pkg/audit"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	registrySyncUser   = "registry-sync"
+	registryLeaderName = "registrysync"
+)
+
+type registrySecret struct {
+	workspace string
+	name      string
+}
+
+//监听镜像仓库的变化,重新生成由该仓库生成的dockercfg类型的Secret
+//多副本部署时只有leader同步,避免重复更新和审计
+func watchRegistryChange() error {
+	c, err := backend.RegisterRegistryNoticer(resourceKind)
+	if err != nil {
+		return err
+	}
+
+	leader := kv.NewLeader(registryLeaderName)
+	go func() {
+		for e := range c {
+			if !leader.IsLeader() {
+				continue
+			}
+			handleRegistryEvent(e)
+		}
+	}()
+	return nil
+}
+
+func listGroupSecrets(group string) []registrySecret {
+	rm.locker.Lock()
+	defer rm.locker.Unlock()
+
+	ss := make([]registrySecret, 0)
+	g, ok := rm.Groups[group]
+	if !ok {
+		return ss
+	}
+	for ws, v := range g.Workspaces {
+		for name := range v.Secrets {
+			ss = append(ss, registrySecret{workspace: ws, name: name})
+		}
+	}
+	return ss
+}
+
+func handleRegistryEvent(e backend.RegistryEvent) {
+	if e.Registry == nil {
+		//仓库删除后保留已生成的Secret
+		log.DebugPrint("registry \"%v/%v\" removed, keep secrets generated from it", e.Group, e.RegistryID)
+		return
+	}
+
+	for _, v := range listGroupSecrets(e.Group) {
+		err := syncRegistrySecret(e.Group, v.workspace, v.name, e.Registry)
+		if err != nil {
+			log.ErrorPrint("sync secret \"%v/%v/%v\" with registry \"%v\" fail for %v", e.Group, v.workspace, v.name, e.Registry.Name, err)
+		}
+	}
+}
+
+//保留原有账号中的邮箱
+func dockercfgEmail(data []byte) string {
+	account := make(map[string]DockerRegistryAccount)
+	err := json.Unmarshal(data, &account)
+	if err != nil {
+		return ""
+	}
+	for _, v := range account {
+		if v.Email != "" {
+			return v.Email
+		}
+	}
+	return ""
+}
+
+func syncRegistrySecret(group, workspace, name string, reg *backend.Registry) error {
+	obj, err := Controller.GetObject(group, workspace, name)
+	if err != nil {
+		if err == resource.ErrResourceNotFound {
+			return nil
+		}
+		return err
+	}
+	s, _ := GetSecretInterface(obj)
+	runtime, err := s.GetRuntime()
+	if err != nil {
+		return err
+	}
+	if !IsFromRegistry(runtime.Secret, reg.ID, reg.Name) {
+		return nil
+	}
+
+	old := runtime.Data[corev1.DockerConfigKey]
+	data, err := NewDockercfgData(reg.Address, reg.User, reg.DecodedPassword(), dockercfgEmail(old))
+	if err != nil {
+		return err
+	}
+	//仓库改名或者旧Secret没有记录仓库ID时也需要更新
+	if bytes.Equal(old, data) && runtime.Annotations[sign.SignUfleetRegistryID] == reg.ID && runtime.Annotations[sign.SignUfleetRegistry] == reg.Name {
+		return nil
+	}
+
+	newr := runtime.Secret.DeepCopy()
+	SetRegistry(newr, reg.ID, reg.Name)
+	newr.Kind = resourceKind
+	newr.APIVersion = "v1"
+	if newr.Data == nil {
+		newr.Data = make(map[string][]byte)
+	}
+	newr.Data[corev1.DockerConfigKey] = data
+	bytedata, err := json.Marshal(newr)
+	if err != nil {
+		return err
+	}
+
+	var opt resource.UpdateOption
+	opt.User = registrySyncUser
+	opt.Comment = fmt.Sprintf("registry \"%v\" changed", reg.Name)
+	err = Controller.UpdateObject(group, workspace, name, bytedata, opt)
+	auditRegistrySync(name, err != nil)
+	if err != nil {
+		return err
+	}
+	log.DebugPrint("secret \"%v/%v/%v\" regenerated from registry \"%v\"", group, workspace, name, reg.Name)
+
+	_, err = Reload(group, workspace, name, opt)
+	return err
+}
+
+func auditRegistrySync(name string, meetError bool) {
+	var ad audit.AuditObj
+	ad.Time = time.Now()
+	ad.Object = resourceKind
+	ad.Operate = "update"
+	ad.Operator = registrySyncUser
+	ad.ObjectName = name
+	if meetError {
+		ad.Level = audit.AuditLevelError
+	} else {
+		ad.Level = audit.AuditLevelInfo
+	}
+	audit.Audit(ad)
+}
@@ -0,0 +1,40 @@
+package secret
+
+import (
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/workload"
+)
+
+//Secret更新后调用
+//重载策略和配置内容都取自集群中的Secret,而不是请求的数据;
+//滚动重启开启了重载的工作负载,返回各工作负载的处理结果
+func Reload(group, workspace, name string, opt resource.UpdateOption) ([]workload.ReloadResult, error) {
+	obj, err := rm.GetObject(group, workspace, name)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+	s, err := GetSecretInterface(obj)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	runtime, err := s.GetRuntime()
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	enabled, err := workload.ReloadEnabled(runtime.Annotations, group, workspace, s.Info().App)
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	refs, err := s.GetReferenceObjects()
+	if err != nil {
+		return nil, log.DebugPrint(err)
+	}
+
+	hash := workload.ContentHash(secretData(runtime.Secret))
+
+	return workload.RestartForConfig(resourceKind, group, workspace, name, hash, refs, enabled, opt), nil
+}
@@ -28,6 +28,7 @@ type SecretInterface interface {
 	Info() *Secret
 	GetRuntime() (*Runtime, error)
 	GetTemplate() (string, error)
+	GetRedactedTemplate() (string, error)
 	GetStatus() *Status
 	Event() ([]corev1.Event, error)
 	GetReferenceObjects() ([]resource.ObjectReference, error)
@@ -141,6 +142,10 @@ func (p *SecretManager) DeleteGroup(groupName string) error {
 	}
 
 	delete(p.Groups, groupName)
+	err := backend.DeleteHistories(backendKind, groupName, "")
+	if err != nil {
+		log.ErrorPrint(err)
+	}
 	return nil
 }
 
@@ -245,6 +250,10 @@ func (p *SecretManager) DeleteWorkspace(groupName string, workspaceName string)
 	}
 	delete(group.Workspaces, workspaceName)
 	p.Groups[groupName] = group
+	err := backend.DeleteHistories(backendKind, groupName, workspaceName)
+	if err != nil {
+		log.ErrorPrint(err)
+	}
 	return nil
 }
 
@@ -429,6 +438,11 @@ func (p *SecretManager) DeleteObject(group, workspace, resourceName string, opt
 	p.locker.Lock()
 	defer p.locker.Unlock()
 	if opt.MemoryOnly {
+		//etcd中的记录可能由其他途径删除,如删除应用,一并清理历史版本
+		err := backend.DeleteHistory(backendKind, group, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		return p.delete(group, workspace, resourceName)
 	}
 
@@ -458,6 +472,10 @@ func (p *SecretManager) DeleteObject(group, workspace, resourceName string, opt
 		if err != nil {
 			return log.DebugPrint(err)
 		}
+		err = backend.DeleteHistory(backendKind, group, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		err = ph.Delete(workspace, resourceName)
 		if err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -525,6 +543,12 @@ func (p *SecretManager) UpdateObject(groupName, workspaceName string, resourceNa
 		return nil
 	}
 
+	//更新前的数据,用于记录历史版本
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		oldr = nil
+	}
+
 	old := *res
 	res.Comment = opt.Comment
 	be := backend.NewBackendHandler()
@@ -543,6 +567,11 @@ func (p *SecretManager) UpdateObject(groupName, workspaceName string, resourceNa
 		return log.DebugPrint(err)
 	}
 
+	err = recordHistory(&old, oldr, &newr, opt)
+	if err != nil {
+		log.ErrorPrint(err)
+	}
+
 	return nil
 }
 func (secret *Secret) Info() *Secret {
@@ -578,6 +607,14 @@ func (s *Secret) GetTemplate() (string, error) {
 
 }
 
+func (s *Secret) GetRedactedTemplate() (string, error) {
+	runtime, err := s.GetRuntime()
+	if err != nil {
+		return "", err
+	}
+	return GetRedactedTemplate(runtime.Secret)
+}
+
 type Status struct {
 	resource.ObjectMeta
 	Reason     string            `json:"reason"`
@@ -585,6 +622,10 @@ type Status struct {
 	Data       map[string][]byte `json:"data"`
 	StringData map[string]string `json:"stringdata"`
 	DataString string            `json:"datastring"`
+	//TLS类型时的证书信息
+	Certificate *CertificateInfo `json:"certificate,omitempty"`
+	//dockercfg类型时生成它的镜像仓库
+	Registry string `json:"registry,omitempty"`
 }
 
 func (s *Secret) ObjectStatus() resource.ObjectStatus {
@@ -615,6 +656,16 @@ func (s *Secret) GetStatus() *Status {
 		return &js
 	}
 	js.DataString = string(bc)
+	js.Registry = runtime.Annotations[sign.SignUfleetRegistry]
+
+	if runtime.Type == corev1.SecretTypeTLS {
+		ci, err := ParseCertificatePEM(runtime.Data[corev1.TLSCertKey])
+		if err != nil {
+			js.Reason = err.Error()
+			return &js
+		}
+		js.Certificate = ci
+	}
 	return &js
 }
 
@@ -9,6 +9,7 @@ import (
 	"ufleet-deploy/pkg/backend"
 	"ufleet-deploy/pkg/cluster"
 	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/quota"
 	"ufleet-deploy/pkg/resource"
 	"ufleet-deploy/pkg/resource/util"
 	"ufleet-deploy/pkg/sign"
@@ -32,6 +33,7 @@ type StatefulSetInterface interface {
 	GetStatus() *Status
 	Event() ([]corev1.Event, error)
 	GetServices() ([]*corev1.Service, error)
+	Scale(num int) error
 }
 
 type StatefulSetManager struct {
@@ -368,6 +370,12 @@ func (p *StatefulSetManager) CreateObject(groupName, workspaceName string, data
 		return log.DebugPrint("must and  offer one resource json/yaml data")
 	}
 	obj.ResourceVersion = ""
+
+	err = quota.Admit(groupName, workspaceName, &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas), nil, 0)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
 	if obj.Annotations == nil {
 		obj.Annotations = make(map[string]string)
 	}
@@ -436,6 +444,11 @@ func (p *StatefulSetManager) DeleteObject(group, workspace, resourceName string,
 	defer p.locker.Unlock()
 
 	if opt.MemoryOnly {
+		//集群中的工作负载已删除,一并清理定时伸缩策略
+		err := backend.DeleteScaleSchedule(group, resourceKind, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		return p.delete(group, workspace, resourceName)
 	}
 
@@ -465,6 +478,10 @@ func (p *StatefulSetManager) DeleteObject(group, workspace, resourceName string,
 		if err != nil {
 			return log.DebugPrint(err)
 		}
+		err = backend.DeleteScaleSchedule(group, resourceKind, workspace, resourceName)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
 		err = ph.Delete(workspace, resourceName)
 		if err != nil {
 			if !apierrors.IsNotFound(err) {
@@ -528,6 +545,17 @@ func (p *StatefulSetManager) UpdateObject(groupName, workspaceName string, resou
 		return log.DebugPrint(err)
 	}
 
+	oldr, err := ph.Get(workspaceName, resourceName)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+	err = quota.Admit(groupName, workspaceName, &newr.Spec.Template.Spec, quota.Replicas(newr.Spec.Replicas), &oldr.Spec.Template.Spec, quota.Replicas(oldr.Spec.Replicas))
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	opt.RecordChange(&newr.ObjectMeta, &newr.Spec.Template, &oldr.Spec.Template)
+
 	if res.MemoryOnly {
 		err = ph.Update(workspaceName, &newr)
 		if err != nil {
@@ -703,6 +731,28 @@ func (s *StatefulSet) Event() ([]corev1.Event, error) {
 	return e, nil
 }
 
+func (s *StatefulSet) Scale(num int) error {
+	sh, err := cluster.NewStatefulSetHandler(s.Group, s.Workspace)
+	if err != nil {
+		return err
+	}
+
+	obj, err := sh.Get(s.Workspace, s.Name)
+	if err != nil {
+		return err
+	}
+	spec := obj.Spec.Template.Spec.DeepCopy()
+	err = quota.Admit(s.Group, s.Workspace, spec, int32(num), &obj.Spec.Template.Spec, quota.Replicas(obj.Spec.Replicas))
+	if err != nil {
+		return err
+	}
+
+	newObj := obj.DeepCopy()
+	replicas := int32(num)
+	newObj.Spec.Replicas = &replicas
+	return sh.Update(s.Workspace, newObj)
+}
+
 func (p *StatefulSet) GetServices() ([]*corev1.Service, error) {
 	ph, err := cluster.NewStatefulSetHandler(p.Group, p.Workspace)
 	if err != nil {
@@ -0,0 +1,14 @@
+package resource
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	k8sapiv1 "k8s.io/kubernetes/pkg/api/v1"
+)
+
+//返回注入了apiserver默认值的Pod模板副本,用于和集群中的模板比较
+//提交的模板通常不带默认值,直接比较几乎总是不同
+func DefaultPodTemplate(tpl *corev1.PodTemplateSpec) *corev1.PodTemplateSpec {
+	pt := corev1.PodTemplate{Template: *tpl.DeepCopy()}
+	k8sapiv1.SetObjectDefaults_PodTemplate(&pt)
+	return &pt.Template
+}
@@ -0,0 +1,236 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	diffContextLines = 3
+	//求最长公共子序列的表最多的单元数,约16MB
+	maxDiffCells = 4 << 20
+
+	DataChangeAdded   = "added"
+	DataChangeRemoved = "removed"
+	DataChangeChanged = "changed"
+)
+
+//配置数据中一个key的变化
+type DataChange struct {
+	Key    string `json:"key"`
+	Action string `json:"action"`
+	Old    string `json:"old,omitempty"`
+	New    string `json:"new,omitempty"`
+	Diff   string `json:"diff,omitempty"` //值的统一格式diff
+}
+
+//比较两份配置数据,按key排序返回变化
+func DiffData(old, new map[string]string) []DataChange {
+	keys := make(map[string]struct{})
+	for k := range old {
+		keys[k] = struct{}{}
+	}
+	for k := range new {
+		keys[k] = struct{}{}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	cs := make([]DataChange, 0)
+	for _, k := range sorted {
+		ov, inOld := old[k]
+		nv, inNew := new[k]
+		switch {
+		case !inOld:
+			cs = append(cs, DataChange{Key: k, Action: DataChangeAdded, New: nv})
+		case !inNew:
+			cs = append(cs, DataChange{Key: k, Action: DataChangeRemoved, Old: ov})
+		case ov != nv:
+			cs = append(cs, DataChange{Key: k, Action: DataChangeChanged, Old: ov, New: nv, Diff: UnifiedDiff(k, k, ov, nv)})
+		}
+	}
+	return cs
+}
+
+type diffLine struct {
+	op   byte //' ','-','+'
+	text string
+}
+
+//逐行diff:去掉相同的首尾行后,对中间部分求最长公共子序列
+//中间部分过大时不再求最长公共子序列,直接作为整体替换,避免占用过多内存
+func diffLines(a, b []string) []diffLine {
+	ls := make([]diffLine, 0, len(a)+len(b))
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		ls = append(ls, diffLine{' ', a[prefix]})
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ls = append(ls, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, v := range a[len(a)-suffix:] {
+		ls = append(ls, diffLine{' ', v})
+	}
+	return ls
+}
+
+func diffMiddle(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	ls := make([]diffLine, 0, n+m)
+	if n == 0 || m == 0 || (n+1)*(m+1) > maxDiffCells {
+		for _, v := range a {
+			ls = append(ls, diffLine{'-', v})
+		}
+		for _, v := range b {
+			ls = append(ls, diffLine{'+', v})
+		}
+		return ls
+	}
+
+	//lcs[i*w+j]为a[i:]和b[j:]的最长公共子序列长度
+	w := m + 1
+	lcs := make([]int32, (n+1)*w)
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+			} else if lcs[(i+1)*w+j] >= lcs[i*w+j+1] {
+				lcs[i*w+j] = lcs[(i+1)*w+j]
+			} else {
+				lcs[i*w+j] = lcs[i*w+j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ls = append(ls, diffLine{' ', a[i]})
+			i++
+			j++
+		case lcs[(i+1)*w+j] >= lcs[i*w+j+1]:
+			ls = append(ls, diffLine{'-', a[i]})
+			i++
+		default:
+			ls = append(ls, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ls = append(ls, diffLine{'-', a[i]})
+	}
+	for ; j < m; j++ {
+		ls = append(ls, diffLine{'+', b[j]})
+	}
+	return ls
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+//生成统一格式(unified)的diff,没有变化时返回空字符串
+func UnifiedDiff(oldName, newName, old, new string) string {
+	ls := diffLines(splitLines(old), splitLines(new))
+
+	changed := false
+	for _, v := range ls {
+		if v.op != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var sb bytes.Buffer
+	fmt.Fprintf(&sb, "--- %v\n+++ %v\n", oldName, newName)
+
+	//oldLine/newLine为ls[k]之前的行数
+	oldLine, newLine := 0, 0
+	k := 0
+	for k < len(ls) {
+		if ls[k].op == ' ' {
+			oldLine++
+			newLine++
+			k++
+			continue
+		}
+
+		//找到hunk的范围:变化前后各保留diffContextLines行上下文,间隔较近的变化合并
+		start := k - diffContextLines
+		if start < 0 {
+			start = 0
+		}
+		end := k
+		for end < len(ls) {
+			if ls[end].op != ' ' {
+				end++
+				continue
+			}
+			same := 0
+			for end+same < len(ls) && ls[end+same].op == ' ' {
+				same++
+			}
+			if end+same == len(ls) || same > 2*diffContextLines {
+				end += same
+				if same > diffContextLines {
+					end -= same - diffContextLines
+				}
+				break
+			}
+			end += same
+		}
+
+		hunkOld, hunkNew := oldLine-(k-start), newLine-(k-start)
+		oldCount, newCount := 0, 0
+		for _, v := range ls[start:end] {
+			if v.op != '+' {
+				oldCount++
+			}
+			if v.op != '-' {
+				newCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%v,%v +%v,%v @@\n", hunkStart(hunkOld, oldCount), oldCount, hunkStart(hunkNew, newCount), newCount)
+		for _, v := range ls[start:end] {
+			sb.WriteByte(v.op)
+			sb.WriteString(v.text)
+			sb.WriteByte('\n')
+		}
+
+		for _, v := range ls[k:end] {
+			if v.op != '+' {
+				oldLine++
+			}
+			if v.op != '-' {
+				newLine++
+			}
+		}
+		k = end
+	}
+	return sb.String()
+}
+
+//统一格式中行号从1开始,空范围时为前一行的行号
+func hunkStart(line, count int) int {
+	if count == 0 {
+		return line
+	}
+	return line + 1
+}
@@ -0,0 +1,45 @@
+package workload
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/resource"
+)
+
+//伸缩,回滚等不修改Pod模板的操作只在工作负载上记录修改,不产生新版本
+func RecordChange(kind, group, workspace, name string, opt resource.UpdateOption) error {
+	as := opt.ChangeAnnotations()
+	switch kind {
+	case KindDeployment:
+		h, err := cluster.NewDeploymentHandler(group, workspace)
+		if err != nil {
+			return err
+		}
+		return h.PatchAnnotations(workspace, name, as)
+	case KindDaemonSet:
+		h, err := cluster.NewDaemonSetHandler(group, workspace)
+		if err != nil {
+			return err
+		}
+		return h.PatchAnnotations(workspace, name, as)
+	case KindStatefulSet:
+		h, err := cluster.NewStatefulSetHandler(group, workspace)
+		if err != nil {
+			return err
+		}
+		return h.PatchAnnotations(workspace, name, as)
+	case KindReplicaSet:
+		h, err := cluster.NewReplicaSetHandler(group, workspace)
+		if err != nil {
+			return err
+		}
+		return h.PatchAnnotations(workspace, name, as)
+	case KindReplicationController:
+		h, err := cluster.NewReplicationControllerHandler(group, workspace)
+		if err != nil {
+			return err
+		}
+		return h.PatchAnnotations(workspace, name, as)
+	}
+	return fmt.Errorf("kind '%v' doesn't support change record", kind)
+}
@@ -0,0 +1,269 @@
+package workload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	defaultImageTag       = "latest"
+	DefaultRolloutTimeout = 300 //秒
+	AllContainers         = "*"
+)
+
+type ImageWorkload struct {
+	Workload
+	Container string `json:"container"`
+	Init      bool   `json:"init"` //是否是初始化容器
+}
+
+type ImageUsage struct {
+	Image      string          `json:"image"`
+	Repository string          `json:"repository"`
+	Tag        string          `json:"tag"`
+	Count      int             `json:"count"`
+	Workloads  []ImageWorkload `json:"workloads"`
+}
+
+//拆分镜像为仓库和标签(或摘要)
+func ParseImage(image string) (string, string) {
+	if i := strings.Index(image, "@"); i >= 0 {
+		return image[:i], image[i+1:]
+	}
+	i := strings.LastIndex(image, ":")
+	//冒号在最后一个'/'之前,是仓库地址的端口
+	if i < 0 || i < strings.LastIndex(image, "/") {
+		return image, defaultImageTag
+	}
+	return image[:i], image[i+1:]
+}
+
+//统计组(工作区,应用)中正在使用的镜像
+//被其他资源控制的工作负载(如Deployment的ReplicaSet)不重复统计
+func ImageInventory(group, workspace, app string) ([]ImageUsage, error) {
+	ws, err := List(group, workspace)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]*ImageUsage)
+	for _, w := range ws {
+		if app != "" && w.App != app {
+			continue
+		}
+		obj, err := GetObject(w.Kind, w.Group, w.Workspace, w.Name)
+		if err != nil {
+			log.DebugPrint(err)
+			continue
+		}
+		if IsControlled(obj) {
+			continue
+		}
+		tpl, err := PodTemplateOf(obj)
+		if err != nil {
+			continue
+		}
+
+		add := func(c corev1.Container, init bool) {
+			u, ok := usages[c.Image]
+			if !ok {
+				repo, tag := ParseImage(c.Image)
+				u = &ImageUsage{Image: c.Image, Repository: repo, Tag: tag, Workloads: make([]ImageWorkload, 0)}
+				usages[c.Image] = u
+			}
+			u.Count++
+			u.Workloads = append(u.Workloads, ImageWorkload{Workload: w, Container: c.Name, Init: init})
+		}
+		for _, c := range tpl.Spec.InitContainers {
+			add(c, true)
+		}
+		for _, c := range tpl.Spec.Containers {
+			add(c, false)
+		}
+	}
+
+	result := make([]ImageUsage, 0, len(usages))
+	for _, v := range usages {
+		result = append(result, *v)
+	}
+	sort.Sort(sortableImageUsages(result))
+	return result, nil
+}
+
+type sortableImageUsages []ImageUsage
+
+func (s sortableImageUsages) Len() int           { return len(s) }
+func (s sortableImageUsages) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortableImageUsages) Less(i, j int) bool { return s[i].Image < s[j].Image }
+
+type SetImageOption struct {
+	Workspace string   `json:"workspace"` //为空表示组下所有工作区
+	App       string   `json:"app"`
+	Kinds     []string `json:"kinds"` //为空表示所有工作负载类型
+	Names     []string `json:"names"`
+
+	//容器名->新镜像,容器名为"*"表示所有容器
+	Containers map[string]string `json:"containers"`
+	//替换所有与该镜像仓库相同的容器的镜像
+	Image string `json:"image"`
+
+	Wait    bool   `json:"wait"`    //是否等待滚动升级完成
+	Timeout int    `json:"timeout"` //等待超时,秒
+	Comment string `json:"comment"`
+
+	//修改记录
+	User      string `json:"-"`
+	Operation string `json:"-"`
+}
+
+type ImageChange struct {
+	Container string `json:"container"`
+	Old       string `json:"old"`
+	New       string `json:"new"`
+}
+
+type SetImageResult struct {
+	Workload
+	Changes []ImageChange  `json:"changes"`
+	Updated bool           `json:"updated"`
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+func (opt *SetImageOption) validate() error {
+	if len(opt.Containers) == 0 && strings.TrimSpace(opt.Image) == "" {
+		return fmt.Errorf("must specify containers' image or image")
+	}
+	for _, v := range opt.Kinds {
+		if !IsWorkloadKind(v) {
+			return fmt.Errorf("kind '%v' is not workload", v)
+		}
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = DefaultRolloutTimeout
+	}
+	return nil
+}
+
+func (opt *SetImageOption) newImage(c corev1.Container) (string, bool) {
+	if img, ok := opt.Containers[c.Name]; ok {
+		return img, img != c.Image
+	}
+	if img, ok := opt.Containers[AllContainers]; ok {
+		return img, img != c.Image
+	}
+	if opt.Image != "" {
+		repo, _ := ParseImage(opt.Image)
+		old, _ := ParseImage(c.Image)
+		if repo == old {
+			return opt.Image, opt.Image != c.Image
+		}
+	}
+	return "", false
+}
+
+func (opt *SetImageOption) changes(spec *corev1.PodSpec) []ImageChange {
+	cs := make([]ImageChange, 0)
+	for _, c := range append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...) {
+		if img, ok := opt.newImage(c); ok {
+			cs = append(cs, ImageChange{Container: c.Name, Old: c.Image, New: img})
+		}
+	}
+	return cs
+}
+
+func setContainersImage(cs []corev1.Container, changes []ImageChange) {
+	for k := range cs {
+		for _, v := range changes {
+			if cs[k].Name == v.Container {
+				cs[k].Image = v.New
+			}
+		}
+	}
+}
+
+//批量修改组中匹配的工作负载的镜像,类似于kubectl set image
+//只返回需要修改镜像的工作负载的结果
+func SetImage(group string, opt SetImageOption) ([]SetImageResult, error) {
+	err := opt.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	ws, err := List(group, opt.Workspace, opt.Kinds...)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for _, v := range opt.Names {
+		names[v] = true
+	}
+
+	results := make([]SetImageResult, 0)
+	for _, w := range ws {
+		if opt.App != "" && w.App != opt.App {
+			continue
+		}
+		if len(names) != 0 && !names[w.Name] {
+			continue
+		}
+		obj, err := GetObject(w.Kind, w.Group, w.Workspace, w.Name)
+		if err != nil {
+			log.DebugPrint(err)
+			continue
+		}
+		if IsControlled(obj) {
+			continue
+		}
+		tpl, err := PodTemplateOf(obj)
+		if err != nil {
+			continue
+		}
+		cs := opt.changes(&tpl.Spec)
+		if len(cs) == 0 {
+			continue
+		}
+		results = append(results, SetImageResult{Workload: w, Changes: cs})
+	}
+
+	var wg sync.WaitGroup
+	for k := range results {
+		wg.Add(1)
+		go func(r *SetImageResult) {
+			defer wg.Done()
+			err := UpdatePodTemplate(r.Kind, r.Group, r.Workspace, r.Name, func(tpl *corev1.PodTemplateSpec) error {
+				setContainersImage(tpl.Spec.InitContainers, r.Changes)
+				setContainersImage(tpl.Spec.Containers, r.Changes)
+				return nil
+			}, resource.UpdateOption{Comment: opt.Comment, User: opt.User, Operation: opt.Operation})
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.Updated = true
+
+			if !opt.Wait {
+				return
+			}
+			//等待informer缓存同步到更新后的对象
+			time.Sleep(rolloutPollInterval)
+			s, err := WaitRollout(r.Kind, r.Group, r.Workspace, r.Name, time.Duration(opt.Timeout)*time.Second)
+			if err != nil {
+				r.Error = err.Error()
+				return
+			}
+			r.Rollout = s
+		}(&results[k])
+	}
+	wg.Wait()
+
+	return results, nil
+}
@@ -0,0 +1,359 @@
+package workload
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/cluster"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+//一个容器的日志
+type LogTarget struct {
+	Pod       string `json:"pod"`
+	Container string `json:"container"`
+}
+
+func (t LogTarget) prefix() string {
+	return fmt.Sprintf("[%v/%v] ", t.Pod, t.Container)
+}
+
+type AggregateLogOption struct {
+	cluster.LogOption
+	Container string //为空时为所有容器
+	Grep      string //正则表达式,只保留匹配的行
+}
+
+//Pod中的容器(不含初始化容器),按Pod名排序
+func LogTargets(pods []*corev1.Pod, container string) []LogTarget {
+	ps := make([]*corev1.Pod, len(pods))
+	copy(ps, pods)
+	sort.Sort(sortablePods(ps))
+
+	ts := make([]LogTarget, 0)
+	for _, p := range ps {
+		for _, c := range p.Spec.Containers {
+			if container != "" && c.Name != container {
+				continue
+			}
+			ts = append(ts, LogTarget{Pod: p.Name, Container: c.Name})
+		}
+	}
+	return ts
+}
+
+type sortablePods []*corev1.Pod
+
+func (s sortablePods) Len() int           { return len(s) }
+func (s sortablePods) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortablePods) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type logLine struct {
+	time   time.Time
+	target LogTarget
+	stamp  string
+	text   string
+}
+
+//拆分带时间戳的日志行,如"2017-08-06T23:08:21.123456789Z hello"
+func parseLogLine(t LogTarget, line string) logLine {
+	l := logLine{target: t, text: line}
+	i := strings.Index(line, " ")
+	if i < 0 {
+		return l
+	}
+	ts, err := time.Parse(time.RFC3339Nano, line[:i])
+	if err != nil {
+		return l
+	}
+	l.time = ts
+	l.stamp = line[:i]
+	l.text = line[i+1:]
+	return l
+}
+
+type logAggregator struct {
+	group     string
+	workspace string
+	opt       AggregateLogOption
+	grep      *regexp.Regexp
+	ph        cluster.PodHandler
+}
+
+func newLogAggregator(group, workspace string, opt AggregateLogOption) (*logAggregator, error) {
+	la := logAggregator{group: group, workspace: workspace, opt: opt}
+	if opt.Grep != "" {
+		re, err := regexp.Compile(opt.Grep)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grep '%v': %v", opt.Grep, err)
+		}
+		la.grep = re
+	}
+	ph, err := cluster.NewPodHandler(group, workspace)
+	if err != nil {
+		return nil, err
+	}
+	la.ph = ph
+	return &la, nil
+}
+
+func (la *logAggregator) match(text string) bool {
+	return la.grep == nil || la.grep.MatchString(text)
+}
+
+//带前缀的输出行,请求了时间戳时保留时间戳
+func (la *logAggregator) format(l logLine) string {
+	return l.target.prefix() + la.formatText(l)
+}
+
+//不带前缀的输出行
+func (la *logAggregator) formatText(l logLine) string {
+	if la.opt.Timestamps && l.stamp != "" {
+		return l.stamp + " " + l.text + "\n"
+	}
+	return l.text + "\n"
+}
+
+func errorLine(t LogTarget, err error) logLine {
+	return logLine{target: t, text: fmt.Sprintf("get log fail for %v", err)}
+}
+
+//逐行读取一个容器的日志,总是带时间戳用于排序
+type logCursor struct {
+	la     *logAggregator
+	target LogTarget
+	rc     io.ReadCloser
+	r      *bufio.Reader
+	head   *logLine //下一行匹配的日志,为nil时已经读完
+}
+
+//打开日志流并读取第一行,打开失败时第一行为错误信息
+func (la *logAggregator) openCursor(t LogTarget) *logCursor {
+	c := &logCursor{la: la, target: t}
+	opt := la.opt.LogOption
+	opt.Follow = false
+	opt.Timestamps = true
+	rc, err := la.ph.LogStream(la.workspace, t.Pod, t.Container, opt)
+	if err != nil {
+		l := errorLine(t, err)
+		c.head = &l
+		return c
+	}
+	c.rc = rc
+	c.r = bufio.NewReader(rc)
+	c.next()
+	return c
+}
+
+func (c *logCursor) next() {
+	c.head = nil
+	if c.r == nil {
+		return
+	}
+	for {
+		line, err := c.r.ReadString('\n')
+		line = strings.TrimSuffix(line, "\n")
+		if line != "" {
+			l := parseLogLine(c.target, line)
+			if c.la.match(l.text) {
+				c.head = &l
+				return
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				l := errorLine(c.target, err)
+				c.head = &l
+			}
+			c.r = nil
+			return
+		}
+	}
+}
+
+func (c *logCursor) Close() {
+	if c.rc != nil {
+		c.rc.Close()
+	}
+}
+
+//按时间戳归并所有容器的日志,每个容器只在内存中保留一行
+//时间相同时保持容器顺序
+func (la *logAggregator) merge(targets []LogTarget) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		cs := make([]*logCursor, 0, len(targets))
+		defer func() {
+			for _, c := range cs {
+				c.Close()
+			}
+		}()
+		for _, t := range targets {
+			cs = append(cs, la.openCursor(t))
+		}
+
+		for {
+			var min *logCursor
+			for _, c := range cs {
+				if c.head != nil && (min == nil || c.head.time.Before(min.head.time)) {
+					min = c
+				}
+			}
+			if min == nil {
+				pw.Close()
+				return
+			}
+			_, err := io.WriteString(pw, la.format(*min.head))
+			if err != nil {
+				return
+			}
+			min.next()
+		}
+	}()
+	return pr
+}
+
+type aggregatedLogStream struct {
+	*io.PipeReader
+	once    sync.Once
+	streams []io.ReadCloser
+}
+
+func (s *aggregatedLogStream) closeStreams() {
+	s.once.Do(func() {
+		for _, v := range s.streams {
+			v.Close()
+		}
+	})
+}
+
+func (s *aggregatedLogStream) Close() error {
+	s.closeStreams()
+	return s.PipeReader.Close()
+}
+
+//持续输出所有容器的日志,按到达顺序输出
+func (la *logAggregator) follow(targets []LogTarget) io.ReadCloser {
+	pr, pw := io.Pipe()
+	s := &aggregatedLogStream{PipeReader: pr}
+
+	opt := la.opt.LogOption
+	opt.Follow = true
+	opt.Timestamps = true
+
+	var mu sync.Mutex
+	write := func(line string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := pw.Write([]byte(line))
+		return err
+	}
+
+	//错误信息的输出也要在关闭管道前完成
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		rc, err := la.ph.LogStream(la.workspace, t.Pod, t.Container, opt)
+		if err != nil {
+			//Pod可能还没有运行,不影响其他容器
+			wg.Add(1)
+			go func(line string) {
+				defer wg.Done()
+				write(line)
+			}(la.format(errorLine(t, err)))
+			continue
+		}
+		s.streams = append(s.streams, rc)
+
+		wg.Add(1)
+		go func(t LogTarget, rc io.ReadCloser) {
+			defer wg.Done()
+			r := bufio.NewReader(rc)
+			for {
+				line, err := r.ReadString('\n')
+				line = strings.TrimSuffix(line, "\n")
+				if line != "" {
+					l := parseLogLine(t, line)
+					if la.match(l.text) {
+						if werr := write(la.format(l)); werr != nil {
+							s.closeStreams()
+							return
+						}
+					}
+				}
+				if err != nil {
+					return
+				}
+			}
+		}(t, rc)
+	}
+
+	go func() {
+		wg.Wait()
+		pw.Close()
+	}()
+	return s
+}
+
+//合并多个容器的日志,每行以[pod/container]开头
+//非follow模式时按时间戳排序,follow模式时按到达顺序
+func OpenAggregatedLogs(group, workspace string, targets []LogTarget, opt AggregateLogOption) (io.ReadCloser, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no container found")
+	}
+	la, err := newLogAggregator(group, workspace, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Follow {
+		return la.follow(targets), nil
+	}
+	return la.merge(targets), nil
+}
+
+//打包下载日志,每个容器一个文件<pod>/<container>.log
+//边读取边压缩,通过返回的流输出;读取某个容器的日志失败时错误信息写入该容器的文件
+func OpenLogsZip(group, workspace string, targets []LogTarget, opt AggregateLogOption) (io.ReadCloser, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no container found")
+	}
+	la, err := newLogAggregator(group, workspace, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(la.writeZip(targets, pw))
+	}()
+	return pr, nil
+}
+
+func (la *logAggregator) writeZip(targets []LogTarget, w io.Writer) error {
+	zw := zip.NewWriter(w)
+	for _, t := range targets {
+		f, err := zw.Create(t.Pod + "/" + t.Container + ".log")
+		if err != nil {
+			return err
+		}
+		c := la.openCursor(t)
+		for ; c.head != nil; c.next() {
+			_, err = io.WriteString(f, la.formatText(*c.head))
+			if err != nil {
+				break
+			}
+		}
+		c.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
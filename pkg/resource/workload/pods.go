@@ -0,0 +1,88 @@
+package workload
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/cluster"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	KindPod = "Pod"
+)
+
+//获取工作负载的Pod,CronJob为它所有Job的Pod
+//kind为Pod时返回该Pod
+func GetPods(kind, group, workspace, name string) ([]*corev1.Pod, error) {
+	switch kind {
+	case KindPod:
+		h, err := cluster.NewPodHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		p, err := h.Get(workspace, name, cluster.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []*corev1.Pod{p}, nil
+	case KindDeployment:
+		h, err := cluster.NewDeploymentHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindDaemonSet:
+		h, err := cluster.NewDaemonSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindStatefulSet:
+		h, err := cluster.NewStatefulSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindReplicaSet:
+		h, err := cluster.NewReplicaSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindReplicationController:
+		h, err := cluster.NewReplicationControllerHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindJob:
+		h, err := cluster.NewJobHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		return h.GetPods(workspace, name)
+	case KindCronJob:
+		h, err := cluster.NewCronJobHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		jobs, err := h.GetJobs(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		jh, err := cluster.NewJobHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		pods := make([]*corev1.Pod, 0)
+		for _, j := range jobs {
+			ps, err := jh.GetPods(workspace, j.Name)
+			if err != nil {
+				return nil, err
+			}
+			pods = append(pods, ps...)
+		}
+		return pods, nil
+	}
+	return nil, fmt.Errorf("kind '%v' doesn't have pods", kind)
+}
@@ -0,0 +1,162 @@
+package workload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//注解名(不含前缀)最长63个字符
+const maxAnnotationNameLen = 63
+
+type ReloadResult struct {
+	Workload
+	Restarted bool   `json:"restarted"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+//配置内容的哈希,与key的顺序无关
+func ContentHash(data map[string][]byte) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(data[k])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//Pod模板中记录配置哈希的注解,每个配置一个注解
+func ConfigHashAnnotation(kind, name string) string {
+	n := strings.ToLower(kind) + "-" + name
+	if len(n) > maxAnnotationNameLen {
+		sum := sha256.Sum256([]byte(name))
+		n = strings.ToLower(kind) + "-" + hex.EncodeToString(sum[:])[:16]
+	}
+	return sign.SignUfleetConfigHashPrefix + n
+}
+
+//配置上的注解优先,没有注解时使用所属应用的策略,默认不开启
+func ReloadEnabled(annotations map[string]string, group, workspace, app string) (bool, error) {
+	if v, ok := annotations[sign.SignUfleetReload]; ok {
+		return v == "true", nil
+	}
+	if app == resource.DefaultAppBelong {
+		return false, nil
+	}
+	rp, err := backend.GetAppReloadPolicy(group, workspace, app)
+	if err != nil {
+		return false, err
+	}
+	return rp.Enabled, nil
+}
+
+//修改Pod模板就会滚动重启Pod的类型
+func isRollingRestartKind(kind string) bool {
+	switch kind {
+	case KindDeployment, KindDaemonSet, KindStatefulSet:
+		return true
+	}
+	return false
+}
+
+//工作负载上的注解优先于配置的重载策略
+func workloadReloadEnabled(obj runtime.Object, enabled bool) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return enabled
+	}
+	if v, ok := accessor.GetAnnotations()[sign.SignUfleetReload]; ok {
+		return v == "true"
+	}
+	return enabled
+}
+
+//在引用配置的工作负载的Pod模板中写入配置的哈希,触发滚动重启
+//kind/name为配置的类型和名字,enabled为配置的重载策略;哈希没有变化的工作负载不会重启
+func RestartForConfig(kind, group, workspace, name, hash string, refs []resource.ObjectReference, enabled bool, opt resource.UpdateOption) []ReloadResult {
+	key := ConfigHashAnnotation(kind, name)
+	opt.Operation = fmt.Sprintf("reload %v %v", strings.ToLower(kind), name)
+
+	results := make([]ReloadResult, 0)
+	for _, ref := range refs {
+		//直接引用配置的Pod不属于工作负载
+		if !IsWorkloadKind(ref.Kind) {
+			continue
+		}
+
+		r := ReloadResult{Workload: Workload{Kind: ref.Kind, Group: group, Workspace: workspace, Name: ref.Name}}
+		obj, err := GetObject(ref.Kind, group, workspace, ref.Name)
+		if err != nil {
+			r.Reason = err.Error()
+			results = append(results, r)
+			continue
+		}
+		//由控制者负责重启
+		if IsControlled(obj) {
+			continue
+		}
+		if !workloadReloadEnabled(obj, enabled) {
+			continue
+		}
+
+		rc, err := resource.GetResourceController(ref.Kind)
+		if err == nil {
+			o, err := rc.GetObject(group, workspace, ref.Name)
+			if err == nil {
+				m := o.Metadata()
+				r.App = m.App
+				r.User = m.User
+			}
+		}
+
+		if !isRollingRestartKind(ref.Kind) {
+			r.Reason = fmt.Sprintf("%v doesn't support rolling restart", ref.Kind)
+			results = append(results, r)
+			continue
+		}
+
+		tpl, err := PodTemplateOf(obj)
+		if err != nil {
+			r.Reason = err.Error()
+			results = append(results, r)
+			continue
+		}
+		if tpl.Annotations[key] == hash {
+			r.Reason = "config hash doesn't change"
+			results = append(results, r)
+			continue
+		}
+
+		err = UpdatePodTemplate(ref.Kind, group, workspace, ref.Name, func(tpl *corev1.PodTemplateSpec) error {
+			if tpl.Annotations == nil {
+				tpl.Annotations = make(map[string]string)
+			}
+			tpl.Annotations[key] = hash
+			return nil
+		}, opt)
+		if err != nil {
+			r.Reason = err.Error()
+		} else {
+			r.Restarted = true
+		}
+		results = append(results, r)
+	}
+	return results
+}
@@ -0,0 +1,376 @@
+package workload
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/sign"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	RestartStrategyTemplate = "template" //在Pod模板中写入重启时间,由控制器滚动重启
+	RestartStrategyBatch    = "batch"    //分批删除Pod,由控制器重建
+
+	defaultRestartMaxUnavailable = "25%"
+
+	//结束的重启进度保留的时间
+	restartProgressTTL = time.Hour
+)
+
+type RestartOption struct {
+	//分批删除Pod时每批最多不可用的Pod数,可以是数目或者百分比,默认25%
+	//Deployment等按模板滚动重启的资源使用自身的滚动升级策略
+	MaxUnavailable string `json:"maxunavailable"`
+	Wait           bool   `json:"wait"`    //是否等待重启完成
+	Timeout        int    `json:"timeout"` //等待超时,秒;分批删除时为每批的超时
+	Comment        string `json:"comment"`
+
+	//修改记录
+	User      string `json:"-"`
+	Operation string `json:"-"`
+}
+
+type RestartProgress struct {
+	Workload
+	Strategy    string `json:"strategy"`
+	StartTime   int64  `json:"starttime"`
+	RestartedAt string `json:"restartedat"` //Pod模板中写入的重启时间
+
+	MaxUnavailable int `json:"maxunavailable"` //分批删除时每批的Pod数
+	Total          int `json:"total"`          //分批删除时需要重启的Pod数
+	Restarted      int `json:"restarted"`      //分批删除时已经重建并就绪的Pod数
+
+	Done    bool           `json:"done"`
+	Failed  bool           `json:"failed"`
+	Message string         `json:"message"`
+	Rollout *RolloutStatus `json:"rollout,omitempty"`
+	//进度结束的时间,按模板重启时为写入模板的时间
+	EndTime int64 `json:"endtime"`
+
+	finished chan struct{}
+}
+
+func IsRestartKind(kind string) bool {
+	return isRollingRestartKind(kind) || kind == KindReplicaSet || kind == KindReplicationController
+}
+
+//重启期间持有的跨副本锁,分批删除时持有到结束
+func restartLockName(kind, group, workspace, name string) string {
+	return "restart/" + group + "/" + workspace + "/" + kind + "/" + name
+}
+
+//进度保存在etcd中,各副本都能查询;只由持有锁的副本修改
+func (p *RestartProgress) save() {
+	err := backend.SetRestartProgress(p.Group, p.Kind, p.Workspace, p.Name, p)
+	if err != nil {
+		log.ErrorPrint("save restart progress of %v %v/%v fail: %v", p.Kind, p.Workspace, p.Name, err)
+	}
+}
+
+func (p *RestartProgress) end(failed bool, msg string) {
+	p.Done = !failed
+	p.Failed = failed
+	p.Message = msg
+	p.EndTime = time.Now().Unix()
+	p.save()
+}
+
+func (opt *RestartOption) validate() error {
+	if opt.MaxUnavailable == "" {
+		opt.MaxUnavailable = defaultRestartMaxUnavailable
+	}
+	v := intstr.Parse(opt.MaxUnavailable)
+	if v.Type == intstr.String && !strings.HasSuffix(v.StrVal, "%") {
+		return fmt.Errorf("invalid maxunavailable '%v'", opt.MaxUnavailable)
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = DefaultRolloutTimeout
+	}
+	return nil
+}
+
+//每批删除的Pod数,至少为1
+func (opt *RestartOption) batchSize(total int) (int, error) {
+	v := intstr.Parse(opt.MaxUnavailable)
+	n, err := intstr.GetValueFromIntOrPercent(&v, total, true)
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 {
+		n = 1
+	}
+	return n, nil
+}
+
+//滚动重启工作负载的Pod
+//Deployment,DaemonSet,StatefulSet在Pod模板中写入重启时间;ReplicaSet,ReplicationController在后台分批删除Pod
+func Restart(kind, group, workspace, name string, opt RestartOption) (*RestartProgress, error) {
+	if !IsRestartKind(kind) {
+		return nil, fmt.Errorf("kind '%v' doesn't support restart", kind)
+	}
+	err := opt.validate()
+	if err != nil {
+		return nil, err
+	}
+	obj, err := GetObject(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	if IsControlled(obj) {
+		return nil, fmt.Errorf("%v '%v' is controlled by other resource, restart its controller instead", kind, name)
+	}
+
+	lock, ok, err := kv.TryLock(restartLockName(kind, group, workspace, name))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("%v '%v' is restarting", kind, name)
+	}
+	p := &RestartProgress{
+		Workload:  Workload{Kind: kind, Group: group, Workspace: workspace, Name: name},
+		StartTime: time.Now().Unix(),
+		finished:  make(chan struct{}),
+	}
+
+	ropt := resource.UpdateOption{Comment: opt.Comment, User: opt.User, Operation: opt.Operation}
+	if isRollingRestartKind(kind) {
+		return restartTemplate(p, lock, ropt, opt)
+	}
+	return restartBatch(p, lock, ropt, opt)
+}
+
+func restartTemplate(p *RestartProgress, lock *kv.Lock, ropt resource.UpdateOption, opt RestartOption) (*RestartProgress, error) {
+	restartedAt := time.Now().Format(time.RFC3339)
+	err := UpdatePodTemplate(p.Kind, p.Group, p.Workspace, p.Name, func(tpl *corev1.PodTemplateSpec) error {
+		if tpl.Annotations == nil {
+			tpl.Annotations = make(map[string]string)
+		}
+		tpl.Annotations[sign.SignRestartedAt] = restartedAt
+		return nil
+	}, ropt)
+
+	p.Strategy = RestartStrategyTemplate
+	p.RestartedAt = restartedAt
+	p.EndTime = time.Now().Unix()
+	if err != nil {
+		p.Failed = true
+		p.Message = err.Error()
+	}
+	p.save()
+	lock.Unlock()
+	close(p.finished)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Wait {
+		_, err = WaitRollout(p.Kind, p.Group, p.Workspace, p.Name, time.Duration(opt.Timeout)*time.Second)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return GetRestartProgress(p.Kind, p.Group, p.Workspace, p.Name)
+}
+
+func restartBatch(p *RestartProgress, lock *kv.Lock, ropt resource.UpdateOption, opt RestartOption) (*RestartProgress, error) {
+	pods, err := GetPods(p.Kind, p.Group, p.Workspace, p.Name)
+	if err == nil {
+		err = RecordChange(p.Kind, p.Group, p.Workspace, p.Name, ropt)
+		if err != nil {
+			log.ErrorPrint("record change of %v %v/%v fail: %v", p.Kind, p.Workspace, p.Name, err)
+			err = nil
+		}
+	}
+	batch := 0
+	if err == nil {
+		batch, err = opt.batchSize(len(pods))
+	}
+
+	p.Strategy = RestartStrategyBatch
+	p.Total = len(pods)
+	p.MaxUnavailable = batch
+	if err != nil {
+		p.end(true, err.Error())
+		lock.Unlock()
+		close(p.finished)
+		return nil, err
+	}
+	p.save()
+
+	//重启前没有就绪的Pod重建后也可能不就绪,按重启前就绪的数目判断
+	readyBefore := 0
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && isPodReady(pod) {
+			readyBefore++
+		}
+	}
+	go func() {
+		defer close(p.finished)
+		defer lock.Unlock()
+		runBatches(p, pods, batch, readyBefore, time.Duration(opt.Timeout)*time.Second)
+	}()
+
+	if opt.Wait {
+		<-p.finished
+	}
+	return GetRestartProgress(p.Kind, p.Group, p.Workspace, p.Name)
+}
+
+type podsByCreation []*corev1.Pod
+
+func (l podsByCreation) Len() int      { return len(l) }
+func (l podsByCreation) Swap(i, j int) { l[i], l[j] = l[j], l[i] }
+func (l podsByCreation) Less(i, j int) bool {
+	return l[i].CreationTimestamp.Before(&l[j].CreationTimestamp)
+}
+
+//从最旧的Pod开始每批删除batch个
+//删除前其余Pod中就绪的数目不少于重启前就绪的数目减去本批的数目,即不可用的Pod不超过一批
+func runBatches(p *RestartProgress, pods []*corev1.Pod, batch, readyBefore int, timeout time.Duration) {
+	finish := p.end
+	restarted := func(n int) {
+		p.Restarted = n
+		p.Message = fmt.Sprintf("%d of %d pods have been restarted", n, len(pods))
+		p.save()
+	}
+
+	h, err := cluster.NewPodHandler(p.Group, p.Workspace)
+	if err != nil {
+		finish(true, err.Error())
+		return
+	}
+
+	sort.Sort(podsByCreation(pods))
+	for i := 0; i < len(pods); i += batch {
+		end := i + batch
+		if end > len(pods) {
+			end = len(pods)
+		}
+		next := make(map[string]bool)
+		for _, pod := range pods[i:end] {
+			next[string(pod.UID)] = true
+		}
+
+		err := waitPodsReady(p, next, readyBefore-len(next), timeout)
+		if err != nil {
+			finish(true, err.Error())
+			return
+		}
+		restarted(i)
+
+		for _, pod := range pods[i:end] {
+			err := h.Delete(p.Workspace, pod.Name)
+			if err != nil {
+				finish(true, fmt.Sprintf("delete pod '%v' fail: %v", pod.Name, err))
+				return
+			}
+		}
+	}
+
+	//最后一批删除后等待所有Pod恢复
+	err = waitPodsReady(p, nil, readyBefore, timeout)
+	if err != nil {
+		finish(true, err.Error())
+		return
+	}
+	restarted(len(pods))
+	finish(false, "successfully restarted")
+}
+
+//excluded中的Pod都已删除或者不计入,其余Pod中就绪的数目不少于minReady
+//期间缩容时minReady不超过期望的副本数
+func waitPodsReady(p *RestartProgress, excluded map[string]bool, minReady int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		desired, err := GetReplicas(p.Kind, p.Group, p.Workspace, p.Name)
+		if err != nil {
+			return err
+		}
+		target := minReady
+		if target > desired {
+			target = desired
+		}
+		pods, err := GetPods(p.Kind, p.Group, p.Workspace, p.Name)
+		if err != nil {
+			return err
+		}
+		ready := 0
+		for _, pod := range pods {
+			if excluded[string(pod.UID)] {
+				continue
+			}
+			if pod.DeletionTimestamp == nil && isPodReady(pod) {
+				ready++
+			}
+		}
+		if ready >= target {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timeout after %v: %d pods are ready, need %d", timeout, ready, target)
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+//按模板重启时进度取自滚动升级状态
+func GetRestartProgress(kind, group, workspace, name string) (*RestartProgress, error) {
+	var result RestartProgress
+	found, err := backend.GetRestartProgress(group, kind, workspace, name, &result)
+	if err != nil {
+		return nil, err
+	}
+	if found && result.EndTime != 0 && time.Since(time.Unix(result.EndTime, 0)) > restartProgressTTL {
+		err = backend.DeleteRestartProgress(group, kind, workspace, name)
+		if err != nil {
+			log.ErrorPrint(err)
+		}
+		found = false
+	}
+	if !found {
+		return nil, fmt.Errorf("no restart of %v '%v' found", kind, name)
+	}
+
+	//执行分批删除的副本退出后锁会释放,进度不会再更新
+	if result.Strategy == RestartStrategyBatch && !result.Done && !result.Failed {
+		locked, err := kv.IsLocked(restartLockName(kind, group, workspace, name))
+		if err != nil {
+			return nil, err
+		}
+		if !locked {
+			result.Failed = true
+			result.Message = "restart was interrupted: " + result.Message
+		}
+	}
+
+	if result.Strategy == RestartStrategyTemplate && !result.Failed {
+		s, err := GetRolloutStatus(kind, group, workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		result.Rollout = s
+		result.Done = s.Done
+		result.Failed = s.Failed
+		result.Message = s.Message
+	}
+	return &result, nil
+}
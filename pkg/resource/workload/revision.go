@@ -0,0 +1,307 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/resource/util"
+	"ufleet-deploy/pkg/sign"
+
+	ghyaml "github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+const (
+	TemplateFieldContainer = "container"
+	TemplateFieldImage     = "image"
+	TemplateFieldEnv       = "env"
+	TemplateFieldResources = "resources"
+)
+
+//版本及其修改记录
+type RevisionInfo struct {
+	Revision    int64  `json:"revision"`
+	Live        bool   `json:"live"` //集群中当前的Pod模板
+	ChangeCause string `json:"changecause"`
+	User        string `json:"user"`
+	Comment     string `json:"comment"`
+	CreateTime  int64  `json:"createtime"`
+}
+
+type revision struct {
+	RevisionInfo
+	template *corev1.PodTemplateSpec
+}
+
+//Pod模板中一项的变化,Container为空时是Pod级别的变化
+type TemplateChange struct {
+	Container string `json:"container"`
+	Field     string `json:"field"`
+	util.DataChange
+}
+
+type RevisionDiff struct {
+	From    RevisionInfo     `json:"from"`
+	To      RevisionInfo     `json:"to"`
+	Unified string           `json:"unified"` //Pod模板yaml的统一格式diff
+	Changes []TemplateChange `json:"changes"`
+}
+
+//按顺序取第一个不为空的记录,模板上的优先
+func fillChangeInfo(info *RevisionInfo, annotations ...map[string]string) {
+	for _, as := range annotations {
+		if info.ChangeCause == "" {
+			info.ChangeCause = as[sign.SignChangeCause]
+		}
+		if info.User == "" {
+			info.User = as[sign.SignUfleetChangeUser]
+		}
+		if info.Comment == "" {
+			info.Comment = as[sign.SignUfleetChangeComment]
+		}
+	}
+}
+
+//Deployment的版本来自它的ReplicaSet,DaemonSet的版本来自ControllerRevision
+func listRevisions(kind, group, workspace, name string) (map[int64]*revision, error) {
+	rm := make(map[int64]*revision)
+	switch kind {
+	case KindDeployment:
+		h, err := cluster.NewDeploymentHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		rss, err := h.GetRevisionsAndReplicas(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		for v, rs := range rss {
+			tpl := rs.Spec.Template.DeepCopy()
+			delete(tpl.Labels, extensionsv1beta1.DefaultDeploymentUniqueLabelKey)
+			r := &revision{template: tpl}
+			r.Revision = v
+			r.CreateTime = rs.CreationTimestamp.Unix()
+			fillChangeInfo(&r.RevisionInfo, tpl.Annotations, rs.Annotations)
+			rm[v] = r
+		}
+	case KindDaemonSet:
+		h, err := cluster.NewDaemonSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		tpls, err := h.GetRevisionsAndDescribe(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		_, crs, err := h.GetControllerRevisions(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		for v, tpl := range tpls {
+			r := &revision{template: tpl}
+			r.Revision = v
+			var as map[string]string
+			if cr, ok := crs[v]; ok {
+				r.CreateTime = cr.CreationTimestamp.Unix()
+				as = cr.Annotations
+			}
+			fillChangeInfo(&r.RevisionInfo, tpl.Annotations, as)
+			rm[v] = r
+		}
+	default:
+		return nil, fmt.Errorf("kind '%v' doesn't support revision", kind)
+	}
+	return rm, nil
+}
+
+type revisionInfoList []RevisionInfo
+
+func (l revisionInfoList) Len() int           { return len(l) }
+func (l revisionInfoList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l revisionInfoList) Less(i, j int) bool { return l[i].Revision > l[j].Revision }
+
+//所有版本的修改记录,按版本从新到旧排列
+func ListRevisions(kind, group, workspace, name string) ([]RevisionInfo, error) {
+	rm, err := listRevisions(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	rs := make(revisionInfoList, 0, len(rm))
+	for _, v := range rm {
+		rs = append(rs, v.RevisionInfo)
+	}
+	sort.Sort(rs)
+	return rs, nil
+}
+
+//集群中当前的Pod模板,版本号为最新的版本
+func liveRevision(kind, group, workspace, name string, rm map[int64]*revision) (*revision, error) {
+	tpl, err := GetPodTemplate(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	r := &revision{template: tpl}
+	r.Live = true
+	for v, old := range rm {
+		if v > r.Revision {
+			r.Revision = v
+			r.CreateTime = old.CreateTime
+		}
+	}
+	fillChangeInfo(&r.RevisionInfo, tpl.Annotations)
+	return r, nil
+}
+
+func getRevision(rm map[int64]*revision, v int64) (*revision, error) {
+	r, ok := rm[v]
+	if !ok {
+		return nil, fmt.Errorf("revision %v not found", v)
+	}
+	return r, nil
+}
+
+//比较两个版本的Pod模板,to不大于0时与集群中当前的Pod模板比较
+func DiffRevisions(kind, group, workspace, name string, from, to int64) (*RevisionDiff, error) {
+	rm, err := listRevisions(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	fr, err := getRevision(rm, from)
+	if err != nil {
+		return nil, err
+	}
+	var tr *revision
+	if to > 0 {
+		tr, err = getRevision(rm, to)
+	} else {
+		tr, err = liveRevision(kind, group, workspace, name, rm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	fromYaml, err := templateYaml(fr.template)
+	if err != nil {
+		return nil, err
+	}
+	toYaml, err := templateYaml(tr.template)
+	if err != nil {
+		return nil, err
+	}
+
+	d := RevisionDiff{
+		From:    fr.RevisionInfo,
+		To:      tr.RevisionInfo,
+		Unified: util.UnifiedDiff(revisionName(fr), revisionName(tr), fromYaml, toYaml),
+		Changes: diffTemplates(fr.template, tr.template),
+	}
+	return &d, nil
+}
+
+func revisionName(r *revision) string {
+	if r.Live {
+		return "live"
+	}
+	return fmt.Sprintf("revision %v", r.Revision)
+}
+
+func templateYaml(tpl *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(tpl)
+	if err != nil {
+		return "", err
+	}
+	data, err = ghyaml.JSONToYAML(data)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+//逐个容器比较镜像,环境变量和资源,以及容器的增删
+func diffTemplates(old, new *corev1.PodTemplateSpec) []TemplateChange {
+	cs := make([]TemplateChange, 0)
+	oldContainers := containersByName(old)
+	newContainers := containersByName(new)
+	names := make([]string, 0)
+	for k := range oldContainers {
+		names = append(names, k)
+	}
+	for k := range newContainers {
+		if _, ok := oldContainers[k]; !ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, n := range names {
+		oc, inOld := oldContainers[n]
+		nc, inNew := newContainers[n]
+		switch {
+		case !inOld:
+			cs = append(cs, TemplateChange{Container: n, Field: TemplateFieldContainer, DataChange: util.DataChange{Key: n, Action: util.DataChangeAdded, New: nc.Image}})
+			continue
+		case !inNew:
+			cs = append(cs, TemplateChange{Container: n, Field: TemplateFieldContainer, DataChange: util.DataChange{Key: n, Action: util.DataChangeRemoved, Old: oc.Image}})
+			continue
+		}
+
+		if oc.Image != nc.Image {
+			cs = append(cs, TemplateChange{Container: n, Field: TemplateFieldImage, DataChange: util.DataChange{Key: TemplateFieldImage, Action: util.DataChangeChanged, Old: oc.Image, New: nc.Image}})
+		}
+		for _, v := range util.DiffData(envMap(oc), envMap(nc)) {
+			cs = append(cs, TemplateChange{Container: n, Field: TemplateFieldEnv, DataChange: v})
+		}
+		for _, v := range util.DiffData(resourceMap(oc), resourceMap(nc)) {
+			cs = append(cs, TemplateChange{Container: n, Field: TemplateFieldResources, DataChange: v})
+		}
+	}
+	return cs
+}
+
+func containersByName(tpl *corev1.PodTemplateSpec) map[string]corev1.Container {
+	cs := make(map[string]corev1.Container)
+	for _, v := range tpl.Spec.InitContainers {
+		cs[v.Name] = v
+	}
+	for _, v := range tpl.Spec.Containers {
+		cs[v.Name] = v
+	}
+	return cs
+}
+
+//引用ConfigMap,Secret等的环境变量以json表示引用
+func envMap(c corev1.Container) map[string]string {
+	m := make(map[string]string)
+	for _, v := range c.Env {
+		if v.ValueFrom == nil {
+			m[v.Name] = v.Value
+			continue
+		}
+		data, err := json.Marshal(v.ValueFrom)
+		if err != nil {
+			continue
+		}
+		m[v.Name] = string(data)
+	}
+	for _, v := range c.EnvFrom {
+		data, err := json.Marshal(v)
+		if err != nil {
+			continue
+		}
+		m["envFrom:"+string(data)] = ""
+	}
+	return m
+}
+
+func resourceMap(c corev1.Container) map[string]string {
+	m := make(map[string]string)
+	for k, v := range c.Resources.Limits {
+		m["limits."+string(k)] = v.String()
+	}
+	for k, v := range c.Resources.Requests {
+		m["requests."+string(k)] = v.String()
+	}
+	return m
+}
@@ -0,0 +1,287 @@
+package workload
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"ufleet-deploy/pkg/cluster"
+
+	appv1beta2 "k8s.io/api/apps/v1beta2"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	//参考自kubectl rollout status
+	deploymentProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+	rolloutPollInterval = 2 * time.Second
+)
+
+type RolloutStatus struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Supported bool   `json:"supported"` //该类资源是否支持滚动升级
+	Done      bool   `json:"done"`
+	Failed    bool   `json:"failed"`
+	Message   string `json:"message"`
+
+	Generation         int64 `json:"generation"`
+	ObservedGeneration int64 `json:"observedgeneration"`
+	Desired            int32 `json:"desired"`
+	Updated            int32 `json:"updated"`
+	Ready              int32 `json:"ready"`
+	Available          int32 `json:"available"`
+
+	//Deployment
+	Revision                int64               `json:"revision"`
+	ProgressDeadlineSeconds int32               `json:"progressdeadlineseconds"`
+	Progressing             *RolloutCondition   `json:"progressing"`
+	NewReplicaSet           *RolloutReplicaSet  `json:"newreplicaset"`
+	OldReplicaSets          []RolloutReplicaSet `json:"oldreplicasets"`
+
+	//StatefulSet
+	CurrentRevision string `json:"currentrevision"`
+	UpdateRevision  string `json:"updaterevision"`
+}
+
+//Deployment的Progressing状态,超过ProgressDeadlineSeconds没有进展时Reason为ProgressDeadlineExceeded
+type RolloutCondition struct {
+	Status         string `json:"status"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	LastUpdateTime int64  `json:"lastupdatetime"`
+}
+
+type RolloutReplicaSet struct {
+	Name      string `json:"name"`
+	Revision  int64  `json:"revision"`
+	Replicas  int32  `json:"replicas"`
+	Ready     int32  `json:"ready"`
+	Available int32  `json:"available"`
+}
+
+type rolloutReplicaSetList []RolloutReplicaSet
+
+func (l rolloutReplicaSetList) Len() int           { return len(l) }
+func (l rolloutReplicaSetList) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+func (l rolloutReplicaSetList) Less(i, j int) bool { return l[i].Revision > l[j].Revision }
+
+func GetRolloutStatus(kind, group, workspace, name string) (*RolloutStatus, error) {
+	obj, err := GetObject(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	s := RolloutStatusOf(obj)
+	s.Kind = kind
+	s.Name = name
+	if kind == KindDeployment {
+		err = deploymentReplicaSets(group, workspace, name, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+//新旧ReplicaSet,旧的按版本从新到旧排列
+func deploymentReplicaSets(group, workspace, name string, s *RolloutStatus) error {
+	h, err := cluster.NewDeploymentHandler(group, workspace)
+	if err != nil {
+		return err
+	}
+	rev, newRS, err := h.GetCurrentRevisionAndReplicaSet(workspace, name)
+	if err != nil {
+		return err
+	}
+	rss, err := h.GetRevisionsAndReplicas(workspace, name)
+	if err != nil {
+		return err
+	}
+
+	if rev != nil {
+		s.Revision = *rev
+	}
+	olds := make(rolloutReplicaSetList, 0)
+	for v, rs := range rss {
+		r := RolloutReplicaSet{
+			Name:      rs.Name,
+			Revision:  v,
+			Replicas:  rs.Status.Replicas,
+			Ready:     rs.Status.ReadyReplicas,
+			Available: rs.Status.AvailableReplicas,
+		}
+		if newRS != nil && rs.UID == newRS.UID {
+			s.NewReplicaSet = &r
+			continue
+		}
+		olds = append(olds, r)
+	}
+	sort.Sort(olds)
+	s.OldReplicaSets = olds
+	return nil
+}
+
+//等待滚动升级完成,失败或者超时
+func WaitRollout(kind, group, workspace, name string, timeout time.Duration) (*RolloutStatus, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		s, err := GetRolloutStatus(kind, group, workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		if s.Done || s.Failed {
+			return s, nil
+		}
+		if time.Now().After(deadline) {
+			s.Message = fmt.Sprintf("timeout after %v: %v", timeout, s.Message)
+			return s, nil
+		}
+		time.Sleep(rolloutPollInterval)
+	}
+}
+
+func RolloutStatusOf(obj runtime.Object) *RolloutStatus {
+	var s RolloutStatus
+	switch v := obj.(type) {
+	case *extensionsv1beta1.Deployment:
+		s.Supported = true
+		s.Generation = v.Generation
+		s.ObservedGeneration = v.Status.ObservedGeneration
+		s.Desired = 1
+		if v.Spec.Replicas != nil {
+			s.Desired = *v.Spec.Replicas
+		}
+		s.Updated = v.Status.UpdatedReplicas
+		s.Ready = v.Status.ReadyReplicas
+		s.Available = v.Status.AvailableReplicas
+		if v.Spec.ProgressDeadlineSeconds != nil {
+			s.ProgressDeadlineSeconds = *v.Spec.ProgressDeadlineSeconds
+		}
+		for _, c := range v.Status.Conditions {
+			if c.Type == extensionsv1beta1.DeploymentProgressing {
+				s.Progressing = &RolloutCondition{
+					Status:         string(c.Status),
+					Reason:         c.Reason,
+					Message:        c.Message,
+					LastUpdateTime: c.LastUpdateTime.Unix(),
+				}
+			}
+		}
+		deploymentRolloutStatus(v, &s)
+	case *extensionsv1beta1.DaemonSet:
+		s.Supported = true
+		s.Generation = v.Generation
+		s.ObservedGeneration = v.Status.ObservedGeneration
+		s.Desired = v.Status.DesiredNumberScheduled
+		s.Updated = v.Status.UpdatedNumberScheduled
+		s.Ready = v.Status.NumberReady
+		s.Available = v.Status.NumberAvailable
+		daemonsetRolloutStatus(v, &s)
+	case *appv1beta2.StatefulSet:
+		s.Supported = true
+		s.Generation = v.Generation
+		s.ObservedGeneration = v.Status.ObservedGeneration
+		s.Desired = 1
+		if v.Spec.Replicas != nil {
+			s.Desired = *v.Spec.Replicas
+		}
+		s.Updated = v.Status.UpdatedReplicas
+		s.Ready = v.Status.ReadyReplicas
+		s.Available = v.Status.ReadyReplicas
+		s.CurrentRevision = v.Status.CurrentRevision
+		s.UpdateRevision = v.Status.UpdateRevision
+		statefulsetRolloutStatus(v, &s)
+	default:
+		//ReplicaSet等修改模板后不会重建Pod
+		s.Done = true
+		s.Message = "resource doesn't support rollout, only new pods use the new template"
+	}
+	return &s
+}
+
+func deploymentRolloutStatus(d *extensionsv1beta1.Deployment, s *RolloutStatus) {
+	if d.Generation > d.Status.ObservedGeneration {
+		s.Message = "waiting for deployment spec update to be observed"
+		return
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == extensionsv1beta1.DeploymentProgressing && c.Reason == deploymentProgressDeadlineExceeded {
+			s.Failed = true
+			s.Message = fmt.Sprintf("deployment %q exceeded its progress deadline", d.Name)
+			return
+		}
+	}
+	if d.Spec.Paused {
+		s.Message = "deployment is paused"
+		return
+	}
+	if s.Updated < s.Desired {
+		s.Message = fmt.Sprintf("%d out of %d new replicas have been updated", s.Updated, s.Desired)
+		return
+	}
+	if d.Status.Replicas > s.Updated {
+		s.Message = fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-s.Updated)
+		return
+	}
+	if s.Available < s.Updated {
+		s.Message = fmt.Sprintf("%d of %d updated replicas are available", s.Available, s.Updated)
+		return
+	}
+	s.Done = true
+	s.Message = "successfully rolled out"
+}
+
+func daemonsetRolloutStatus(d *extensionsv1beta1.DaemonSet, s *RolloutStatus) {
+	if d.Spec.UpdateStrategy.Type != extensionsv1beta1.RollingUpdateDaemonSetStrategyType {
+		s.Done = true
+		s.Message = "update strategy is not rolling update, pods are updated on delete"
+		return
+	}
+	if d.Generation > d.Status.ObservedGeneration {
+		s.Message = "waiting for daemon set spec update to be observed"
+		return
+	}
+	if s.Updated < s.Desired {
+		s.Message = fmt.Sprintf("%d out of %d new pods have been updated", s.Updated, s.Desired)
+		return
+	}
+	if s.Available < s.Desired {
+		s.Message = fmt.Sprintf("%d of %d updated pods are available", s.Available, s.Desired)
+		return
+	}
+	s.Done = true
+	s.Message = "successfully rolled out"
+}
+
+func statefulsetRolloutStatus(ss *appv1beta2.StatefulSet, s *RolloutStatus) {
+	if ss.Spec.UpdateStrategy.Type != appv1beta2.RollingUpdateStatefulSetStrategyType {
+		s.Done = true
+		s.Message = "update strategy is not rolling update, pods are updated on delete"
+		return
+	}
+	if ss.Status.ObservedGeneration == 0 || ss.Generation > ss.Status.ObservedGeneration {
+		s.Message = "waiting for statefulset spec update to be observed"
+		return
+	}
+	if s.Ready < s.Desired {
+		s.Message = fmt.Sprintf("%d of %d pods are ready", s.Ready, s.Desired)
+		return
+	}
+	ru := ss.Spec.UpdateStrategy.RollingUpdate
+	if ru != nil && ru.Partition != nil && *ru.Partition > 0 {
+		if s.Updated < s.Desired-*ru.Partition {
+			s.Message = fmt.Sprintf("%d of %d pods above partition have been updated", s.Updated, s.Desired-*ru.Partition)
+			return
+		}
+		s.Done = true
+		s.Message = fmt.Sprintf("partitioned roll out complete: %d new pods have been updated", s.Updated)
+		return
+	}
+	if ss.Status.UpdateRevision != ss.Status.CurrentRevision {
+		s.Message = fmt.Sprintf("waiting for pods to be updated to revision %v", ss.Status.UpdateRevision)
+		return
+	}
+	s.Done = true
+	s.Message = "successfully rolled out"
+}
@@ -0,0 +1,80 @@
+package workload
+
+import (
+	"fmt"
+	"ufleet-deploy/pkg/resource"
+
+	appv1beta2 "k8s.io/api/apps/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+var (
+	//可以伸缩副本数的工作负载
+	ScalableKinds = []string{
+		KindDeployment,
+		KindStatefulSet,
+		KindReplicaSet,
+		KindReplicationController,
+	}
+)
+
+type scaler interface {
+	Scale(num int) error
+}
+
+func IsScalableKind(kind string) bool {
+	for _, v := range ScalableKinds {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+//通过资源自身的Scale伸缩,会检查配额
+func Scale(kind, group, workspace, name string, replicas int) error {
+	if !IsScalableKind(kind) {
+		return fmt.Errorf("kind '%v' can't be scaled", kind)
+	}
+	rc, err := resource.GetResourceController(kind)
+	if err != nil {
+		return err
+	}
+	obj, err := rc.GetObject(group, workspace, name)
+	if err != nil {
+		return err
+	}
+	s, ok := obj.(scaler)
+	if !ok {
+		return fmt.Errorf("%v '%v' can't be scaled", kind, name)
+	}
+	return s.Scale(replicas)
+}
+
+//工作负载在集群中期望的副本数
+func GetReplicas(kind, group, workspace, name string) (int, error) {
+	if !IsScalableKind(kind) {
+		return 0, fmt.Errorf("kind '%v' can't be scaled", kind)
+	}
+	obj, err := GetObject(kind, group, workspace, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var replicas *int32
+	switch v := obj.(type) {
+	case *extensionsv1beta1.Deployment:
+		replicas = v.Spec.Replicas
+	case *appv1beta2.StatefulSet:
+		replicas = v.Spec.Replicas
+	case *extensionsv1beta1.ReplicaSet:
+		replicas = v.Spec.Replicas
+	case *corev1.ReplicationController:
+		replicas = v.Spec.Replicas
+	}
+	if replicas == nil {
+		return 1, nil
+	}
+	return int(*replicas), nil
+}
@@ -0,0 +1,294 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+
+	appv1beta2 "k8s.io/api/apps/v1beta2"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv2alpha1 "k8s.io/api/batch/v2alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+//带有Pod模板的资源,统一对它们的Pod模板进行读写
+const (
+	KindDeployment            = "Deployment"
+	KindDaemonSet             = "DaemonSet"
+	KindStatefulSet           = "StatefulSet"
+	KindReplicaSet            = "ReplicaSet"
+	KindReplicationController = "ReplicationController"
+	KindJob                   = "Job"
+	KindCronJob               = "CronJob"
+)
+
+var (
+	Kinds = []string{
+		KindDeployment,
+		KindDaemonSet,
+		KindStatefulSet,
+		KindReplicaSet,
+		KindReplicationController,
+		KindJob,
+		KindCronJob,
+	}
+)
+
+type Workload struct {
+	Kind      string `json:"kind"`
+	Group     string `json:"group"`
+	Workspace string `json:"workspace"`
+	Name      string `json:"name"`
+	App       string `json:"app"`
+	User      string `json:"user"`
+}
+
+func IsWorkloadKind(kind string) bool {
+	for _, v := range Kinds {
+		if v == kind {
+			return true
+		}
+	}
+	return false
+}
+
+//列出组下所有工作负载,workspace为空表示组下所有工作区
+func List(group, workspace string, kinds ...string) ([]Workload, error) {
+	if len(kinds) == 0 {
+		kinds = Kinds
+	}
+
+	ws := make([]Workload, 0)
+	for _, kind := range kinds {
+		if !IsWorkloadKind(kind) {
+			return nil, fmt.Errorf("kind '%v' is not workload", kind)
+		}
+		rc, err := resource.GetResourceController(kind)
+		if err != nil {
+			return nil, log.DebugPrint(err)
+		}
+
+		var objs []resource.Object
+		if workspace == "" {
+			objs, err = rc.ListGroupObject(group)
+		} else {
+			objs, err = rc.ListGroupWorkspaceObject(group, workspace)
+		}
+		if err != nil {
+			return nil, log.DebugPrint(err)
+		}
+
+		for _, v := range objs {
+			m := v.Metadata()
+			ws = append(ws, Workload{
+				Kind:      kind,
+				Group:     m.Group,
+				Workspace: m.Workspace,
+				Name:      m.Name,
+				App:       m.App,
+				User:      m.User,
+			})
+		}
+	}
+	return ws, nil
+}
+
+//获取工作负载在集群中的对象,返回的是副本,可以直接修改
+func GetObject(kind, group, workspace, name string) (runtime.Object, error) {
+	switch kind {
+	case KindDeployment:
+		h, err := cluster.NewDeploymentHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindDaemonSet:
+		h, err := cluster.NewDaemonSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindStatefulSet:
+		h, err := cluster.NewStatefulSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindReplicaSet:
+		h, err := cluster.NewReplicaSetHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindReplicationController:
+		h, err := cluster.NewReplicationControllerHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindJob:
+		h, err := cluster.NewJobHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	case KindCronJob:
+		h, err := cluster.NewCronJobHandler(group, workspace)
+		if err != nil {
+			return nil, err
+		}
+		obj, err := h.Get(workspace, name)
+		if err != nil {
+			return nil, err
+		}
+		return obj.DeepCopy(), nil
+	}
+	return nil, fmt.Errorf("kind '%v' is not workload", kind)
+}
+
+//返回工作负载类型的空对象,用于解析模板
+func NewObject(kind string) (runtime.Object, error) {
+	switch kind {
+	case KindDeployment:
+		return &extensionsv1beta1.Deployment{}, nil
+	case KindDaemonSet:
+		return &extensionsv1beta1.DaemonSet{}, nil
+	case KindStatefulSet:
+		return &appv1beta2.StatefulSet{}, nil
+	case KindReplicaSet:
+		return &extensionsv1beta1.ReplicaSet{}, nil
+	case KindReplicationController:
+		return &corev1.ReplicationController{}, nil
+	case KindJob:
+		return &batchv1.Job{}, nil
+	case KindCronJob:
+		return &batchv2alpha1.CronJob{}, nil
+	}
+	return nil, fmt.Errorf("kind '%v' is not workload", kind)
+}
+
+//返回对象中Pod模板的引用,修改它即修改对象
+func PodTemplateOf(obj runtime.Object) (*corev1.PodTemplateSpec, error) {
+	switch v := obj.(type) {
+	case *extensionsv1beta1.Deployment:
+		return &v.Spec.Template, nil
+	case *extensionsv1beta1.DaemonSet:
+		return &v.Spec.Template, nil
+	case *appv1beta2.StatefulSet:
+		return &v.Spec.Template, nil
+	case *extensionsv1beta1.ReplicaSet:
+		return &v.Spec.Template, nil
+	case *corev1.ReplicationController:
+		if v.Spec.Template == nil {
+			v.Spec.Template = &corev1.PodTemplateSpec{}
+		}
+		return v.Spec.Template, nil
+	case *batchv1.Job:
+		return &v.Spec.Template, nil
+	case *batchv2alpha1.CronJob:
+		return &v.Spec.JobTemplate.Spec.Template, nil
+	}
+	return nil, fmt.Errorf("object doesn't have pod template")
+}
+
+//是否由其他资源控制(如由Deployment创建的ReplicaSet,由CronJob创建的Job)
+//这类资源的Pod模板应该通过其控制者修改
+func IsControlled(obj runtime.Object) bool {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return false
+	}
+	for _, v := range accessor.GetOwnerReferences() {
+		if v.Controller != nil && *v.Controller {
+			return true
+		}
+	}
+	return false
+}
+
+func GetPodTemplate(kind, group, workspace, name string) (*corev1.PodTemplateSpec, error) {
+	obj, err := GetObject(kind, group, workspace, name)
+	if err != nil {
+		return nil, err
+	}
+	return PodTemplateOf(obj)
+}
+
+//通过各资源的UpdateObject更新Pod模板,以保持etcd中的记录同步
+//Job的Pod模板创建后不可修改,apiserver会拒绝,因此直接返回错误
+func UpdatePodTemplate(kind, group, workspace, name string, fn func(*corev1.PodTemplateSpec) error, opt resource.UpdateOption) error {
+	if kind == KindJob {
+		return fmt.Errorf("pod template of Job '%v' is immutable, delete and recreate the Job to change it", name)
+	}
+
+	obj, err := GetObject(kind, group, workspace, name)
+	if err != nil {
+		return err
+	}
+
+	if IsControlled(obj) {
+		return fmt.Errorf("%v '%v' is controlled by other resource, don't support update", kind, name)
+	}
+
+	tpl, err := PodTemplateOf(obj)
+	if err != nil {
+		return err
+	}
+
+	err = fn(tpl)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	rc, err := resource.GetResourceController(kind)
+	if err != nil {
+		return log.DebugPrint(err)
+	}
+
+	return rc.UpdateObject(group, workspace, name, data, opt)
+}
+
+//路由中的资源类型不区分大小写,如deployment
+func ParseKind(s string) (string, error) {
+	for _, v := range Kinds {
+		if strings.EqualFold(v, s) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("kind '%v' is not workload", s)
+}
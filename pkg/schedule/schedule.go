@@ -0,0 +1,267 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/canary"
+	"ufleet-deploy/pkg/kv"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+	pk "ufleet-deploy/pkg/resource/deployment"
+	"ufleet-deploy/pkg/resource/workload"
+	"ufleet-deploy/util/cron"
+)
+
+const (
+	checkInterval = 20 * time.Second
+	leaderName    = "scaleschedule"
+)
+
+var (
+	//执行记录按组保存,追加时加锁
+	locker sync.Mutex
+	leader *kv.Leader
+)
+
+//定时检查所有组的定时伸缩策略
+//多副本部署时只有leader执行
+func Init() {
+	leader = kv.NewLeader(leaderName)
+	go loop()
+}
+
+func location(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+func validate(ss backend.ScaleSchedule) error {
+	if !workload.IsScalableKind(ss.Kind) {
+		return fmt.Errorf("kind '%v' doesn't support scheduled scaling", ss.Kind)
+	}
+	_, err := location(ss.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid timezone '%v': %v", ss.Timezone, err)
+	}
+	for _, r := range ss.Rules {
+		_, err := cron.Parse(r.Schedule)
+		if err != nil {
+			return err
+		}
+		if r.Replicas < 0 || r.MinReplicas < 0 || r.MaxReplicas < 0 {
+			return fmt.Errorf("replicas of rule '%v' must not be negative", r.Schedule)
+		}
+		if r.MinReplicas > 0 && r.MaxReplicas > 0 && r.MinReplicas > r.MaxReplicas {
+			return fmt.Errorf("minReplicas of rule '%v' is greater than maxReplicas", r.Schedule)
+		}
+	}
+	return nil
+}
+
+func same(ss backend.ScaleSchedule, kind, workspace, name string) bool {
+	return ss.Kind == kind && ss.Workspace == workspace && ss.Name == name
+}
+
+//没有设置时返回关闭的策略
+func Get(kind, group, workspace, name string) (*backend.ScaleSchedule, error) {
+	sss, err := backend.GetScaleSchedules(group)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range sss {
+		if same(v, kind, workspace, name) {
+			return &v, nil
+		}
+	}
+	return &backend.ScaleSchedule{Kind: kind, Workspace: workspace, Name: name, Rules: make([]backend.ScaleRule, 0)}, nil
+}
+
+func Set(group string, ss backend.ScaleSchedule) error {
+	err := validate(ss)
+	if err != nil {
+		return err
+	}
+	if ss.Rules == nil {
+		ss.Rules = make([]backend.ScaleRule, 0)
+	}
+
+	return backend.SetScaleSchedule(group, ss)
+}
+
+func Delete(kind, group, workspace, name string) error {
+	return backend.DeleteScaleSchedule(group, kind, workspace, name)
+}
+
+//工作负载的执行记录,最近的在前
+func History(kind, group, workspace, name string) ([]backend.ScaleExecution, error) {
+	es, err := backend.GetScaleHistory(group)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backend.ScaleExecution, 0)
+	for i := len(es) - 1; i >= 0; i-- {
+		v := es[i]
+		if v.Kind == kind && v.Workspace == workspace && v.Name == name {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+//所有可伸缩资源所在的组
+func listGroups() []string {
+	groups := make([]string, 0)
+	found := make(map[string]bool)
+	for _, kind := range workload.ScalableKinds {
+		rc, err := resource.GetResourceController(kind)
+		if err != nil {
+			continue
+		}
+		for _, g := range rc.ListGroups() {
+			if !found[g] {
+				found[g] = true
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+//每次检查执行(上次检查时间,当前时间]内到期的规则
+//不是leader时只更新检查时间,成为leader后不会补执行之前的规则
+func loop() {
+	last := time.Now()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if leader.IsLeader() {
+			for _, g := range listGroups() {
+				runGroup(g, last, now)
+			}
+		}
+		last = now
+	}
+}
+
+func runGroup(group string, last, now time.Time) {
+	sss, err := backend.GetScaleSchedules(group)
+	if err != nil {
+		log.ErrorPrint("get scale schedules of group '%v' fail: %v", group, err)
+		return
+	}
+
+	es := make([]backend.ScaleExecution, 0)
+	for _, ss := range sss {
+		if !ss.Enabled {
+			continue
+		}
+		loc, err := location(ss.Timezone)
+		if err != nil {
+			continue
+		}
+		//同一时间到期的多条规则按顺序执行,后面的生效
+		for _, r := range ss.Rules {
+			s, err := cron.Parse(r.Schedule)
+			if err != nil {
+				continue
+			}
+			next := s.Next(last.In(loc))
+			if next.IsZero() || next.After(now) {
+				continue
+			}
+			es = append(es, execute(group, ss, r, now))
+		}
+	}
+	if len(es) == 0 {
+		return
+	}
+
+	locker.Lock()
+	defer locker.Unlock()
+	err = backend.AppendScaleHistory(group, es...)
+	if err != nil {
+		log.ErrorPrint("append scale history of group '%v' fail: %v", group, err)
+	}
+}
+
+//Deployment开启了弹性伸缩时,规则中的min/max会修改弹性伸缩的范围,
+//副本数被限制在弹性伸缩的范围内,之后由弹性伸缩接管
+func execute(group string, ss backend.ScaleSchedule, r backend.ScaleRule, now time.Time) backend.ScaleExecution {
+	e := backend.ScaleExecution{
+		Time:      now.Unix(),
+		Kind:      ss.Kind,
+		Workspace: ss.Workspace,
+		Name:      ss.Name,
+		Schedule:  r.Schedule,
+		Replicas:  r.Replicas,
+	}
+
+	old, err := workload.GetReplicas(ss.Kind, group, ss.Workspace, ss.Name)
+	if err != nil {
+		e.Message = err.Error()
+		return e
+	}
+	e.OldReplicas = old
+	e.NewReplicas = old
+
+	replicas := r.Replicas
+	messages := make([]string, 0)
+	if ss.Kind == workload.KindDeployment {
+		err = canary.CheckScalable(group, ss.Workspace, ss.Name)
+		if err != nil {
+			e.Message = err.Error()
+			return e
+		}
+		replicas, messages, err = applyAutoScaleRange(group, ss, r)
+		if err != nil {
+			e.Message = err.Error()
+			return e
+		}
+	}
+
+	if replicas != old {
+		err = workload.Scale(ss.Kind, group, ss.Workspace, ss.Name, replicas)
+		if err != nil {
+			e.Message = err.Error()
+			return e
+		}
+	}
+	e.NewReplicas = replicas
+	e.Success = true
+	messages = append(messages, fmt.Sprintf("scale from %v to %v", old, replicas))
+	e.Message = strings.Join(messages, "; ")
+	log.DebugPrint("scale schedule '%v' of %v %v/%v: %v", r.Schedule, ss.Kind, ss.Workspace, ss.Name, e.Message)
+	return e
+}
+
+func applyAutoScaleRange(group string, ss backend.ScaleSchedule, r backend.ScaleRule) (int, []string, error) {
+	messages := make([]string, 0)
+	opt, err := pk.UpdateAutoScaleRange(group, ss.Workspace, ss.Name, r.MinReplicas, r.MaxReplicas)
+	if err != nil {
+		return 0, nil, fmt.Errorf("update autoscale range fail: %v", err)
+	}
+	if !opt.Deployed {
+		return r.Replicas, messages, nil
+	}
+
+	if r.MinReplicas > 0 || r.MaxReplicas > 0 {
+		messages = append(messages, fmt.Sprintf("autoscale range set to [%v,%v]", opt.MinReplicas, opt.MaxReplicas))
+	}
+
+	replicas := r.Replicas
+	if replicas < opt.MinReplicas {
+		replicas = opt.MinReplicas
+	}
+	if replicas > opt.MaxReplicas {
+		replicas = opt.MaxReplicas
+	}
+	if replicas != r.Replicas {
+		messages = append(messages, fmt.Sprintf("replicas limited to %v by autoscale range", replicas))
+	}
+	return replicas, messages, nil
+}
@@ -0,0 +1,372 @@
+package search
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/sign"
+
+	appv1beta1 "k8s.io/api/apps/v1beta1"
+	appv1beta2 "k8s.io/api/apps/v1beta2"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv2alpha1 "k8s.io/api/batch/v2alpha1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+var (
+	//backend中的资源类型到资源Kind的映射
+	backendKindToKind = map[string]string{
+		backend.ResourcePods:                     "Pod",
+		backend.ResourceServices:                 "Service",
+		backend.ResourceSecrets:                  "Secret",
+		backend.ResourceConfigMaps:               "ConfigMap",
+		backend.ResourceEndpoints:                "Endpoints",
+		backend.ResourceServiceAccounts:          "ServiceAccount",
+		backend.ResourceDeployments:              "Deployment",
+		backend.ResourceDaemonSets:               "DaemonSet",
+		backend.ResourceIngresss:                 "Ingress",
+		backend.ResourceStatefulSets:             "StatefulSet",
+		backend.ResourceJobs:                     "Job",
+		backend.ResourceCronJobs:                 "CronJob",
+		backend.ResourceReplicationControllers:   "ReplicationController",
+		backend.ResourceReplicaSets:              "ReplicaSet",
+		backend.ResourceHorizontalPodAutoscalers: "HorizontalPodAutoscaler",
+	}
+)
+
+//索引中的一条记录
+type Entry struct {
+	Kind        string            `json:"kind"`
+	Group       string            `json:"group"`
+	Workspace   string            `json:"workspace"`
+	Name        string            `json:"name"`
+	App         string            `json:"app"`
+	User        string            `json:"user"`
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations"`
+	Images      []string          `json:"images"`
+	ConfigMaps  []string          `json:"configmaps"`
+	Secrets     []string          `json:"secrets"`
+}
+
+type Index struct {
+	locker  sync.Mutex
+	entries map[string]*Entry
+}
+
+func entryKey(kind, group, workspace, name string) string {
+	return kind + "/" + group + "/" + workspace + "/" + name
+}
+
+func newIndex() *Index {
+	return &Index{entries: make(map[string]*Entry)}
+}
+
+//获取记录,不存在则创建.调用者需持有锁
+func (i *Index) getOrCreate(kind, group, workspace, name string) *Entry {
+	key := entryKey(kind, group, workspace, name)
+	e, ok := i.entries[key]
+	if !ok {
+		e = &Entry{Kind: kind, Group: group, Workspace: workspace, Name: name}
+		i.entries[key] = e
+	}
+	return e
+}
+
+//用集群对象的内容更新索引
+func (i *Index) updateFromObject(group, workspace, name string, obj interface{}) {
+	kind := objectKind(obj)
+	if kind == "" {
+		return
+	}
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return
+	}
+
+	i.locker.Lock()
+	defer i.locker.Unlock()
+
+	e := i.getOrCreate(kind, group, workspace, name)
+	e.Labels = copyMap(accessor.GetLabels())
+	e.Annotations = copyMap(accessor.GetAnnotations())
+	e.Images, e.ConfigMaps, e.Secrets = nil, nil, nil
+	//应用创建的资源带有应用标记,etcd中没有记录时以此为准
+	if app, ok := e.Annotations[sign.SignUfleetAppKey]; ok && e.App == "" {
+		e.App = app
+	}
+
+	if spec := podSpecOf(obj); spec != nil {
+		e.Images, e.ConfigMaps, e.Secrets = podSpecReferences(spec)
+	}
+	if ing, ok := obj.(*extensionsv1beta1.Ingress); ok {
+		for _, v := range ing.Spec.TLS {
+			e.Secrets = appendUnique(e.Secrets, v.SecretName)
+		}
+	}
+	if sa, ok := obj.(*corev1.ServiceAccount); ok {
+		for _, v := range sa.Secrets {
+			e.Secrets = appendUnique(e.Secrets, v.Name)
+		}
+		for _, v := range sa.ImagePullSecrets {
+			e.Secrets = appendUnique(e.Secrets, v.Name)
+		}
+	}
+}
+
+//用ufleet记录的元数据(app,user)更新索引
+func (i *Index) updateFromMeta(kind string, m resource.ObjectMeta) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+
+	e := i.getOrCreate(kind, m.Group, m.Workspace, m.Name)
+	e.App = m.App
+	e.User = m.User
+}
+
+func (i *Index) delete(kind, group, workspace, name string) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	delete(i.entries, entryKey(kind, group, workspace, name))
+}
+
+//删除组或工作区下的所有记录,workspace为空时删除整个组
+func (i *Index) deleteScope(kind, group, workspace string) {
+	i.locker.Lock()
+	defer i.locker.Unlock()
+	for k, v := range i.entries {
+		if v.Kind != kind || v.Group != group {
+			continue
+		}
+		if workspace != "" && v.Workspace != workspace {
+			continue
+		}
+		delete(i.entries, k)
+	}
+}
+
+//查找满足查询条件,并且visible返回true的工作区中的记录
+func (i *Index) Search(q *Query, visible func(group, workspace string) bool) []Entry {
+	i.locker.Lock()
+	matched := make([]Entry, 0)
+	for _, v := range i.entries {
+		if q.Match(v) {
+			matched = append(matched, *v)
+		}
+	}
+	i.locker.Unlock()
+
+	//权限检查可能需要请求其他模块,不在锁内进行
+	result := make([]Entry, 0, len(matched))
+	for _, v := range matched {
+		if visible(v.Group, v.Workspace) {
+			result = append(result, v)
+		}
+	}
+
+	sort.Sort(sortableEntries(result))
+	return result
+}
+
+type sortableEntries []Entry
+
+func (s sortableEntries) Len() int      { return len(s) }
+func (s sortableEntries) Swap(a, b int) { s[a], s[b] = s[b], s[a] }
+func (s sortableEntries) Less(a, b int) bool {
+	return entryKey(s[a].Kind, s[a].Group, s[a].Workspace, s[a].Name) <
+		entryKey(s[b].Kind, s[b].Group, s[b].Workspace, s[b].Name)
+}
+
+//处理集群资源事件
+func (i *Index) handleClusterEvent(e cluster.Event) {
+	if e.Action == cluster.ActionDelete {
+		kind := objectKind(e.Object)
+		if kind != "" {
+			i.delete(kind, e.Group, e.Workspace, e.Name)
+		}
+		return
+	}
+	i.updateFromObject(e.Group, e.Workspace, e.Name, e.Object)
+}
+
+//处理etcd中资源事件,只关心ufleet记录的元数据
+func (i *Index) ObserveEvent(backendKind string, re backend.ResourceEvent) {
+	kind, ok := backendKindToKind[backendKind]
+	if !ok {
+		return
+	}
+
+	if re.Workspace == nil || re.Resource == nil {
+		if re.Action == backend.ActionDelete {
+			var ws string
+			if re.Workspace != nil {
+				ws = *re.Workspace
+			}
+			i.deleteScope(kind, re.Group, ws)
+		}
+		return
+	}
+
+	//etcd中的记录被删除时删除已有的记录,不创建新记录;
+	//集群中的资源仍然存在时,下一次集群事件会重新加入索引
+	if re.Action == backend.ActionDelete {
+		i.delete(kind, re.Group, *re.Workspace, *re.Resource)
+		return
+	}
+
+	var m resource.ObjectMeta
+	err := json.Unmarshal([]byte(re.Value), &m)
+	if err != nil {
+		return
+	}
+	m.Group = re.Group
+	m.Workspace = *re.Workspace
+	m.Name = *re.Resource
+	i.updateFromMeta(kind, m)
+}
+
+func objectKind(obj interface{}) string {
+	switch obj.(type) {
+	case *corev1.Pod:
+		return "Pod"
+	case *corev1.Service:
+		return "Service"
+	case *corev1.ConfigMap:
+		return "ConfigMap"
+	case *corev1.ReplicationController:
+		return "ReplicationController"
+	case *corev1.Endpoints:
+		return "Endpoints"
+	case *corev1.ServiceAccount:
+		return "ServiceAccount"
+	case *corev1.Secret:
+		return "Secret"
+	case *extensionsv1beta1.Deployment:
+		return "Deployment"
+	case *extensionsv1beta1.ReplicaSet:
+		return "ReplicaSet"
+	case *extensionsv1beta1.DaemonSet:
+		return "DaemonSet"
+	case *extensionsv1beta1.Ingress:
+		return "Ingress"
+	case *appv1beta1.StatefulSet, *appv1beta2.StatefulSet:
+		return "StatefulSet"
+	case *batchv1.Job:
+		return "Job"
+	case *batchv2alpha1.CronJob:
+		return "CronJob"
+	case *autoscalingv1.HorizontalPodAutoscaler:
+		return "HorizontalPodAutoscaler"
+	}
+	return ""
+}
+
+func podSpecOf(obj interface{}) *corev1.PodSpec {
+	switch v := obj.(type) {
+	case *corev1.Pod:
+		return &v.Spec
+	case *corev1.ReplicationController:
+		if v.Spec.Template != nil {
+			return &v.Spec.Template.Spec
+		}
+	case *extensionsv1beta1.Deployment:
+		return &v.Spec.Template.Spec
+	case *extensionsv1beta1.ReplicaSet:
+		return &v.Spec.Template.Spec
+	case *extensionsv1beta1.DaemonSet:
+		return &v.Spec.Template.Spec
+	case *appv1beta1.StatefulSet:
+		return &v.Spec.Template.Spec
+	case *appv1beta2.StatefulSet:
+		return &v.Spec.Template.Spec
+	case *batchv1.Job:
+		return &v.Spec.Template.Spec
+	case *batchv2alpha1.CronJob:
+		return &v.Spec.JobTemplate.Spec.Template.Spec
+	}
+	return nil
+}
+
+//返回podSpec中引用的镜像,配置表,保密字典
+func podSpecReferences(spec *corev1.PodSpec) ([]string, []string, []string) {
+	images := make([]string, 0)
+	cms := make([]string, 0)
+	secrets := make([]string, 0)
+
+	containers := make([]corev1.Container, 0)
+	containers = append(containers, spec.InitContainers...)
+	containers = append(containers, spec.Containers...)
+	for _, c := range containers {
+		images = appendUnique(images, c.Image)
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				cms = appendUnique(cms, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				secrets = appendUnique(secrets, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, ef := range c.EnvFrom {
+			if ef.ConfigMapRef != nil {
+				cms = appendUnique(cms, ef.ConfigMapRef.Name)
+			}
+			if ef.SecretRef != nil {
+				secrets = appendUnique(secrets, ef.SecretRef.Name)
+			}
+		}
+	}
+
+	for _, v := range spec.Volumes {
+		if v.ConfigMap != nil {
+			cms = appendUnique(cms, v.ConfigMap.Name)
+		}
+		if v.Secret != nil {
+			secrets = appendUnique(secrets, v.Secret.SecretName)
+		}
+		if v.Projected != nil {
+			for _, s := range v.Projected.Sources {
+				if s.ConfigMap != nil {
+					cms = appendUnique(cms, s.ConfigMap.Name)
+				}
+				if s.Secret != nil {
+					secrets = appendUnique(secrets, s.Secret.Name)
+				}
+			}
+		}
+	}
+
+	for _, v := range spec.ImagePullSecrets {
+		secrets = appendUnique(secrets, v.Name)
+	}
+	return images, cms, secrets
+}
+
+func appendUnique(s []string, v string) []string {
+	if strings.TrimSpace(v) == "" {
+		return s
+	}
+	for _, j := range s {
+		if j == v {
+			return s
+		}
+	}
+	return append(s, v)
+}
+
+func copyMap(m map[string]string) map[string]string {
+	n := make(map[string]string, len(m))
+	for k, v := range m {
+		n[k] = v
+	}
+	return n
+}
@@ -0,0 +1,49 @@
+package search
+
+import (
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/cluster"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/resource"
+)
+
+const (
+	observerName = "search"
+)
+
+var (
+	index = newIndex()
+)
+
+//必须在各资源控制器初始化之后,集群informers启动之前调用
+func Init() {
+	for _, kind := range backendKindToKind {
+		rc, err := resource.GetResourceController(kind)
+		if err != nil {
+			log.DebugPrint(err)
+			continue
+		}
+		for _, g := range rc.ListGroups() {
+			objs, err := rc.ListGroupObject(g)
+			if err != nil {
+				log.DebugPrint(err)
+				continue
+			}
+			for _, v := range objs {
+				index.updateFromMeta(kind, v.Metadata())
+			}
+		}
+	}
+
+	backend.RegisterEventObserver(observerName, index)
+	cluster.RegisterEventObserver(observerName, index.handleClusterEvent)
+}
+
+//查找满足查询条件的资源,只返回visible返回true的工作区中的资源
+func Search(query string, visible func(group, workspace string) bool) ([]Entry, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	return index.Search(q, visible), nil
+}
@@ -0,0 +1,200 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//查询语法:
+//  以空格分隔多个条件,所有条件同时满足才算匹配
+//  field:value 按字段匹配,不带字段的条件匹配名字
+//  -field:value 取反
+//  value中可以使用通配符'*'(任意字符,包括'/'),'?'(单个字符)和'[...]',否则名字和镜像按子串匹配,其他字段按全等匹配
+//  label和annotation可以写成 label:key 或 label:key=value
+//
+//  例: image:nginx* kind:Deployment label:env=prod -app:demo
+const (
+	FieldName       = "name"
+	FieldKind       = "kind"
+	FieldGroup      = "group"
+	FieldWorkspace  = "workspace"
+	FieldApp        = "app"
+	FieldUser       = "user"
+	FieldImage      = "image"
+	FieldLabel      = "label"
+	FieldAnnotation = "annotation"
+	FieldConfigMap  = "configmap"
+	FieldSecret     = "secret"
+)
+
+var (
+	validFields = map[string]bool{
+		FieldName:       true,
+		FieldKind:       true,
+		FieldGroup:      true,
+		FieldWorkspace:  true,
+		FieldApp:        true,
+		FieldUser:       true,
+		FieldImage:      true,
+		FieldLabel:      true,
+		FieldAnnotation: true,
+		FieldConfigMap:  true,
+		FieldSecret:     true,
+	}
+)
+
+type Term struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Negate bool   `json:"negate"`
+
+	//value中带通配符时编译的正则,label和annotation只编译'='后的部分
+	pattern *regexp.Regexp
+}
+
+type Query struct {
+	Terms []Term `json:"terms"`
+}
+
+func ParseQuery(s string) (*Query, error) {
+	var q Query
+	q.Terms = make([]Term, 0)
+	for _, v := range strings.Fields(s) {
+		var t Term
+		if strings.HasPrefix(v, "-") {
+			t.Negate = true
+			v = v[1:]
+		}
+
+		t.Field = FieldName
+		t.Value = v
+		if i := strings.Index(v, ":"); i >= 0 {
+			t.Field = strings.ToLower(v[:i])
+			t.Value = v[i+1:]
+		}
+
+		if !validFields[t.Field] {
+			return nil, fmt.Errorf("unsupported search field '%v'", t.Field)
+		}
+		if t.Value == "" {
+			return nil, fmt.Errorf("search field '%v' must have value", t.Field)
+		}
+		p := t.Value
+		if t.Field == FieldLabel || t.Field == FieldAnnotation {
+			kv := strings.SplitN(p, "=", 2)
+			p = ""
+			if len(kv) == 2 {
+				p = kv[1]
+			}
+		}
+		if isPattern(p) {
+			re, err := compilePattern(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid search pattern '%v': %v", t.Value, err)
+			}
+			t.pattern = re
+		}
+		q.Terms = append(q.Terms, t)
+	}
+	return &q, nil
+}
+
+func (q *Query) Match(e *Entry) bool {
+	for _, t := range q.Terms {
+		if t.match(e) == t.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (t Term) match(e *Entry) bool {
+	switch t.Field {
+	case FieldName:
+		return t.matchValue(t.Value, e.Name, true)
+	case FieldKind:
+		return strings.EqualFold(t.Value, e.Kind)
+	case FieldGroup:
+		return t.matchValue(t.Value, e.Group, false)
+	case FieldWorkspace:
+		return t.matchValue(t.Value, e.Workspace, false)
+	case FieldApp:
+		return t.matchValue(t.Value, e.App, false)
+	case FieldUser:
+		return t.matchValue(t.Value, e.User, false)
+	case FieldImage:
+		return t.matchAny(e.Images, true)
+	case FieldConfigMap:
+		return t.matchAny(e.ConfigMaps, false)
+	case FieldSecret:
+		return t.matchAny(e.Secrets, false)
+	case FieldLabel:
+		return t.matchMap(e.Labels)
+	case FieldAnnotation:
+		return t.matchMap(e.Annotations)
+	}
+	return false
+}
+
+func isPattern(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
+
+//把通配符转换为整体匹配的正则,'*'匹配包括'/'在内的任意字符
+func compilePattern(p string) (*regexp.Regexp, error) {
+	expr := "^"
+	for i := 0; i < len(p); i++ {
+		switch c := p[i]; c {
+		case '*':
+			expr += ".*"
+		case '?':
+			expr += "."
+		case '[':
+			j := strings.IndexByte(p[i+1:], ']')
+			if j < 0 {
+				return nil, fmt.Errorf("missing ']'")
+			}
+			class := p[i+1 : i+1+j]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			expr += "[" + class + "]"
+			i += j + 1
+		default:
+			expr += regexp.QuoteMeta(string(c))
+		}
+	}
+	return regexp.Compile(expr + "$")
+}
+
+func (t Term) matchValue(pattern, value string, substring bool) bool {
+	if t.pattern != nil {
+		return t.pattern.MatchString(value)
+	}
+	if substring {
+		return strings.Contains(value, pattern)
+	}
+	return pattern == value
+}
+
+func (t Term) matchAny(values []string, substring bool) bool {
+	for _, v := range values {
+		if t.matchValue(t.Value, v, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t Term) matchMap(m map[string]string) bool {
+	kv := strings.SplitN(t.Value, "=", 2)
+	v, ok := m[kv[0]]
+	if !ok {
+		return false
+	}
+	if len(kv) == 1 {
+		return true
+	}
+	return t.matchValue(kv[1], v, false)
+}
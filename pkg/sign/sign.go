@@ -8,4 +8,19 @@ const (
 	SignUfleetAppKey             = "com.appsoar.ufleet.app"
 	SignUfleetAutoScaleSupported = "com.appsoar.ufleet.autoscale" //指定哪些deploymnet支持他行伸缩
 	SignUfleetDeployment         = "com.appsoar.ufleet.deploy"    //在pod指定哪些pod属于它
+	SignUfleetReload             = "com.appsoar.ufleet.reload"    //ConfigMap/Secret更新时是否重启引用它的工作负载,"true"/"false";也可以加在工作负载上
+	SignUfleetConfigHashPrefix   = "confighash.ufleet.appsoar.com/"
+	SignUfleetRegistry           = "com.appsoar.ufleet.registry"    //dockercfg类型的Secret由哪个镜像仓库生成,值为仓库名,用于显示
+	SignUfleetRegistryID         = "com.appsoar.ufleet.registry-id" //同上,值为仓库ID,仓库改名后仍能关联
+
+	//版本的修改记录,kubectl --record也会写change-cause
+	SignChangeCause         = "kubernetes.io/change-cause"
+	SignUfleetChangeUser    = "com.appsoar.ufleet.change-user"
+	SignUfleetChangeComment = "com.appsoar.ufleet.change-comment"
+
+	//Pod模板中的重启时间,修改它触发滚动重启,与kubectl rollout restart一致
+	SignRestartedAt = "kubectl.kubernetes.io/restartedAt"
+
+	//金丝雀发布创建的Deployment,Pod,Service和Ingress,值为稳定版本的Deployment名
+	SignUfleetCanary = "com.appsoar.ufleet.canary"
 )
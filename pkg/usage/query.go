@@ -0,0 +1,197 @@
+package usage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+	"ufleet-deploy/pkg/backend"
+)
+
+const (
+	AggregationAvg = "avg"
+	AggregationMax = "max"
+	AggregationMin = "min"
+)
+
+type QueryOption struct {
+	Start       time.Time
+	End         time.Time
+	Step        time.Duration //小于存储精度时使用存储精度
+	Aggregation string        //同一区间内的聚合方式,默认avg
+}
+
+//查询结果中的一个时间点
+type UsagePoint struct {
+	Time      time.Time `json:"time"`
+	CPU       float64   `json:"cpu"`
+	Memory    float64   `json:"memory"`
+	RxRate    float64   `json:"rxrate"`
+	TxRate    float64   `json:"txrate"`
+	DiskRead  float64   `json:"diskread"`
+	DiskWrite float64   `json:"diskwrite"`
+}
+
+type UsageSeries struct {
+	Name        string       `json:"name"`
+	Resolution  string       `json:"resolution"` //使用的存储精度
+	Step        int64        `json:"step"`       //秒
+	Aggregation string       `json:"aggregation"`
+	Points      []UsagePoint `json:"points"`
+}
+
+func (opt *QueryOption) validate() error {
+	switch opt.Aggregation {
+	case "":
+		opt.Aggregation = AggregationAvg
+	case AggregationAvg, AggregationMax, AggregationMin:
+	default:
+		return fmt.Errorf("unknown aggregation '%v'", opt.Aggregation)
+	}
+	if opt.End.IsZero() {
+		opt.End = time.Now()
+	}
+	if opt.Start.IsZero() {
+		opt.Start = opt.End.Add(-time.Hour)
+	}
+	if !opt.Start.Before(opt.End) {
+		return fmt.Errorf("start must be before end")
+	}
+	if opt.Step < 0 {
+		return fmt.Errorf("invalid step '%v'", opt.Step)
+	}
+	return nil
+}
+
+//选择保留时间能覆盖查询起点的最细精度
+func chooseTier(start time.Time) (tier, time.Duration, error) {
+	ur, err := backend.GetUsageRetention()
+	if err != nil {
+		return tier{}, 0, err
+	}
+	retentions := []int64{ur.Raw, ur.TenMinutes, ur.Hourly}
+	for i, t := range tiers {
+		if time.Since(start) <= time.Duration(retentions[i])*time.Hour {
+			return t, t.resolution, nil
+		}
+	}
+	return tierHourly, tierHourly.resolution, nil
+}
+
+//按step把各点聚合成一个值
+type bucket struct {
+	count int64
+	sum   [6]float64
+	min   [6]float64
+	max   [6]float64
+}
+
+func values(p point) [6]Value {
+	return [6]Value{p.CPU, p.Memory, p.RxRate, p.TxRate, p.DiskRead, p.DiskWrite}
+}
+
+func (b *bucket) add(p point) {
+	vs := values(p)
+	for i, v := range vs {
+		b.sum[i] += v.Sum
+		if b.count == 0 || v.Min < b.min[i] {
+			b.min[i] = v.Min
+		}
+		if b.count == 0 || v.Max > b.max[i] {
+			b.max[i] = v.Max
+		}
+	}
+	b.count += p.Count
+}
+
+func (b *bucket) result(agg string) [6]float64 {
+	switch agg {
+	case AggregationMax:
+		return b.max
+	case AggregationMin:
+		return b.min
+	}
+	var r [6]float64
+	if b.count == 0 {
+		return r
+	}
+	for i, v := range b.sum {
+		r[i] = v / float64(b.count)
+	}
+	return r
+}
+
+type sortableUsagePoints []UsagePoint
+
+func (s sortableUsagePoints) Len() int           { return len(s) }
+func (s sortableUsagePoints) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortableUsagePoints) Less(i, j int) bool { return s[i].Time.Before(s[j].Time) }
+
+//查询多个序列,按step聚合后相加,用于应用等由多个序列组成的对象
+func Query(name string, keys []string, opt QueryOption) (*UsageSeries, error) {
+	if db == nil {
+		return nil, fmt.Errorf("usage store is disabled")
+	}
+	err := opt.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	t, resolution, err := chooseTier(opt.Start)
+	if err != nil {
+		return nil, err
+	}
+	if resolution < sampleInterval {
+		resolution = sampleInterval
+	}
+	step := opt.Step
+	if step < resolution {
+		step = resolution
+	}
+
+	sums := make(map[int64]*UsagePoint)
+	for _, key := range keys {
+		ps, err := db.read(t, key, opt.Start.Unix(), opt.End.Unix())
+		if err != nil {
+			return nil, err
+		}
+
+		buckets := make(map[int64]*bucket)
+		for _, p := range ps {
+			k := time.Unix(p.Time, 0).Truncate(step).Unix()
+			b, ok := buckets[k]
+			if !ok {
+				b = &bucket{}
+				buckets[k] = b
+			}
+			b.add(p)
+		}
+
+		for k, b := range buckets {
+			s, ok := sums[k]
+			if !ok {
+				s = &UsagePoint{Time: time.Unix(k, 0)}
+				sums[k] = s
+			}
+			r := b.result(opt.Aggregation)
+			s.CPU += r[0]
+			s.Memory += r[1]
+			s.RxRate += r[2]
+			s.TxRate += r[3]
+			s.DiskRead += r[4]
+			s.DiskWrite += r[5]
+		}
+	}
+
+	us := UsageSeries{
+		Name:        name,
+		Resolution:  t.name,
+		Step:        int64(step / time.Second),
+		Aggregation: opt.Aggregation,
+	}
+	us.Points = make([]UsagePoint, 0, len(sums))
+	for _, v := range sums {
+		us.Points = append(us.Points, *v)
+	}
+	sort.Sort(sortableUsagePoints(us.Points))
+	return &us, nil
+}
@@ -0,0 +1,187 @@
+package usage
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+	"ufleet-deploy/pkg/backend"
+	"ufleet-deploy/pkg/log"
+	"ufleet-deploy/pkg/metrics"
+	"ufleet-deploy/pkg/resource"
+	"ufleet-deploy/pkg/resource/workload"
+)
+
+const (
+	dbPathEnvKey         = "USAGE_DB_PATH"
+	sampleIntervalEnvKey = "USAGE_SAMPLE_INTERVAL"
+
+	defaultDBPath         = "/var/lib/ufleet-deploy/usage.db"
+	defaultSampleInterval = time.Minute
+	minSampleInterval     = 10 * time.Second
+
+	pruneInterval = time.Hour
+)
+
+var (
+	db             *store
+	sampleInterval = defaultSampleInterval
+)
+
+//打开本地的存储,并定时采样所有工作负载和Pod的资源使用
+//存储打开失败时不影响其他功能,只是没有历史数据
+func Init() {
+	path := os.Getenv(dbPathEnvKey)
+	if path == "" {
+		path = defaultDBPath
+	}
+	if v := os.Getenv(sampleIntervalEnvKey); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < minSampleInterval {
+			log.ErrorPrint("invalid %v '%v', use default %v", sampleIntervalEnvKey, v, defaultSampleInterval)
+		} else {
+			sampleInterval = d
+		}
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		log.ErrorPrint("usage store disabled: %v", err)
+		return
+	}
+	s, err := openStore(path)
+	if err != nil {
+		log.ErrorPrint("usage store disabled: %v", err)
+		return
+	}
+	db = s
+
+	go sampleLoop()
+	go pruneLoop()
+	log.DebugPrint("usage store: %v, sample interval: %v", path, sampleInterval)
+}
+
+func sampleLoop() {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		err := db.write(sample(time.Now()))
+		if err != nil {
+			log.ErrorPrint("write usage fail: %v", err)
+		}
+	}
+}
+
+func pruneLoop() {
+	ticker := time.NewTicker(pruneInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		ur, err := backend.GetUsageRetention()
+		if err != nil {
+			log.ErrorPrint("get usage retention fail: %v", err)
+			continue
+		}
+		now := time.Now()
+		cutoffs := map[string]int64{
+			tierRaw.name:        now.Add(-time.Duration(ur.Raw) * time.Hour).Unix(),
+			tierTenMinutes.name: now.Add(-time.Duration(ur.TenMinutes) * time.Hour).Unix(),
+			tierHourly.name:     now.Add(-time.Duration(ur.Hourly) * time.Hour).Unix(),
+		}
+		n, err := db.prune(cutoffs)
+		if err != nil {
+			log.ErrorPrint("prune usage fail: %v", err)
+			continue
+		}
+		log.DebugPrint("pruned %v usage points", n)
+	}
+}
+
+//序列名为<group>/<workspace>/<kind>/<name>
+func SeriesKey(kind, group, workspace, name string) string {
+	return group + "/" + workspace + "/" + kind + "/" + name
+}
+
+//一个序列在一个采样周期内的平均值
+func pointOf(t time.Time, s metrics.Series) (point, bool) {
+	if len(s.Samples) == 0 {
+		return point{}, false
+	}
+	var cpu, mem, rx, tx, dr, dw float64
+	for _, v := range s.Samples {
+		cpu += v.CPU
+		mem += float64(v.Memory)
+		rx += v.RxRate
+		tx += v.TxRate
+		dr += v.DiskRead
+		dw += v.DiskWrite
+	}
+	n := float64(len(s.Samples))
+	return point{
+		Time:      t.Unix(),
+		Count:     1,
+		CPU:       newValue(cpu / n),
+		Memory:    newValue(mem / n),
+		RxRate:    newValue(rx / n),
+		TxRate:    newValue(tx / n),
+		DiskRead:  newValue(dr / n),
+		DiskWrite: newValue(dw / n),
+	}, true
+}
+
+//采样所有工作负载及其Pod,再采样不属于已采样工作负载的Pod
+func sample(now time.Time) map[string]point {
+	points := make(map[string]point)
+
+	for _, kind := range workload.Kinds {
+		rc, err := resource.GetResourceController(kind)
+		if err != nil {
+			continue
+		}
+		for _, g := range rc.ListGroups() {
+			objs, err := rc.ListGroupObject(g)
+			if err != nil {
+				continue
+			}
+			for _, o := range objs {
+				m := o.Metadata()
+				ws, err := metrics.WorkloadSeriesOf(kind, g, m.Workspace, m.Name, sampleInterval)
+				if err != nil {
+					continue
+				}
+				if p, ok := pointOf(now, ws.Total); ok {
+					points[SeriesKey(kind, g, m.Workspace, m.Name)] = p
+				}
+				for _, ps := range ws.Pods {
+					if p, ok := pointOf(now, ps.Total); ok {
+						points[SeriesKey(workload.KindPod, g, m.Workspace, ps.Pod)] = p
+					}
+				}
+			}
+		}
+	}
+
+	rc, err := resource.GetResourceController(workload.KindPod)
+	if err != nil {
+		return points
+	}
+	for _, g := range rc.ListGroups() {
+		objs, err := rc.ListGroupObject(g)
+		if err != nil {
+			continue
+		}
+		for _, o := range objs {
+			m := o.Metadata()
+			key := SeriesKey(workload.KindPod, g, m.Workspace, m.Name)
+			if _, ok := points[key]; ok {
+				continue
+			}
+			ws, err := metrics.WorkloadSeriesOf(workload.KindPod, g, m.Workspace, m.Name, sampleInterval)
+			if err != nil {
+				continue
+			}
+			if p, ok := pointOf(now, ws.Total); ok {
+				points[key] = p
+			}
+		}
+	}
+	return points
+}
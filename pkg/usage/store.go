@@ -0,0 +1,208 @@
+package usage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+//存储的精度,每个精度一个bucket
+type tier struct {
+	name       string
+	resolution time.Duration
+}
+
+var (
+	tierRaw        = tier{name: "raw"}
+	tierTenMinutes = tier{name: "10m", resolution: 10 * time.Minute}
+	tierHourly     = tier{name: "1h", resolution: time.Hour}
+
+	//从细到粗
+	tiers = []tier{tierRaw, tierTenMinutes, tierHourly}
+)
+
+//一个指标在一个时间点上的统计,用于降采样后仍能计算平均值和最值
+type Value struct {
+	Sum float64 `json:"sum"`
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+func newValue(v float64) Value {
+	return Value{Sum: v, Min: v, Max: v}
+}
+
+func (v *Value) merge(o Value) {
+	v.Sum += o.Sum
+	if o.Min < v.Min {
+		v.Min = o.Min
+	}
+	if o.Max > v.Max {
+		v.Max = o.Max
+	}
+}
+
+type point struct {
+	Time      int64 `json:"time"`
+	Count     int64 `json:"count"`
+	CPU       Value `json:"cpu"`
+	Memory    Value `json:"memory"`
+	RxRate    Value `json:"rxrate"`
+	TxRate    Value `json:"txrate"`
+	DiskRead  Value `json:"diskread"`
+	DiskWrite Value `json:"diskwrite"`
+}
+
+func (p *point) merge(o point) {
+	if p.Count == 0 {
+		t := p.Time
+		*p = o
+		p.Time = t
+		return
+	}
+	p.Count += o.Count
+	p.CPU.merge(o.CPU)
+	p.Memory.merge(o.Memory)
+	p.RxRate.merge(o.RxRate)
+	p.TxRate.merge(o.TxRate)
+	p.DiskRead.merge(o.DiskRead)
+	p.DiskWrite.merge(o.DiskWrite)
+}
+
+type store struct {
+	db *bolt.DB
+}
+
+func openStore(path string) (*store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open usage store '%v' fail for %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, t := range tiers {
+			_, err := tx.CreateBucketIfNotExists([]byte(t.name))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &store{db: db}, nil
+}
+
+//key为<序列名>\x00<秒级时间戳>,同一序列的数据按时间排列
+func pointKey(series string, t int64) []byte {
+	k := make([]byte, len(series)+9)
+	copy(k, series)
+	binary.BigEndian.PutUint64(k[len(series)+1:], uint64(t))
+	return k
+}
+
+func seriesPrefix(series string) []byte {
+	return append([]byte(series), 0)
+}
+
+func keyTime(k []byte) int64 {
+	if len(k) < 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(k[len(k)-8:]))
+}
+
+//写入原始采样,同时合并到各个降采样的精度中
+func (s *store) write(points map[string]point) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for series, p := range points {
+			for _, t := range tiers {
+				b := tx.Bucket([]byte(t.name))
+				ts := p.Time
+				if t.resolution > 0 {
+					ts = time.Unix(p.Time, 0).Truncate(t.resolution).Unix()
+				}
+				k := pointKey(series, ts)
+
+				merged := point{Time: ts}
+				if data := b.Get(k); data != nil {
+					err := json.Unmarshal(data, &merged)
+					if err != nil {
+						return err
+					}
+				}
+				merged.merge(p)
+				data, err := json.Marshal(merged)
+				if err != nil {
+					return err
+				}
+				err = b.Put(k, data)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+//读取序列在[start,end]内的数据
+func (s *store) read(t tier, series string, start, end int64) ([]point, error) {
+	ps := make([]point, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket([]byte(t.name)).Cursor()
+		prefix := seriesPrefix(series)
+		for k, v := c.Seek(pointKey(series, start)); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if keyTime(k) > end {
+				break
+			}
+			var p point
+			err := json.Unmarshal(v, &p)
+			if err != nil {
+				return err
+			}
+			ps = append(ps, p)
+		}
+		return nil
+	})
+	return ps, err
+}
+
+//删除各精度中超过保留时间的数据,返回删除的条数
+func (s *store) prune(cutoffs map[string]int64) (int, error) {
+	deleted := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for _, t := range tiers {
+			cutoff, ok := cutoffs[t.name]
+			if !ok {
+				continue
+			}
+			c := tx.Bucket([]byte(t.name)).Cursor()
+			for k, _ := c.First(); k != nil; {
+				if keyTime(k) < cutoff {
+					k = append([]byte(nil), k...)
+					err := c.Delete()
+					if err != nil {
+						return err
+					}
+					deleted++
+					//删除后游标指向下一个元素
+					k, _ = c.Seek(k)
+					continue
+				}
+				k, _ = c.Next()
+			}
+		}
+		return nil
+	})
+	return deleted, err
+}
+
+func (s *store) close() error {
+	return s.db.Close()
+}
@@ -16,6 +16,7 @@ var (
 )
 
 type Registry struct {
+	ID       string `json:"id"`
 	Name     string `json:"name"`
 	Address  string `json:"address"`
 	User     string `json:"user"`
@@ -6,6 +6,55 @@ import (
 
 func init() {
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "DeleteAppAutoRollback",
+			Router: `/:app/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Delete"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "DownloadAppLogs",
+			Router: `/:app/group/:group/workspace/:workspace/logs/download`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "GetAppAutoRollback",
+			Router: `/:app/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "GetAppAutoRollbackHistory",
+			Router: `/:app/group/:group/workspace/:workspace/autorollback/history`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "GetAppLogs",
+			Router: `/:app/group/:group/workspace/:workspace/logs`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "GetAppReloadPolicy",
+			Router: `/:app/group/:group/workspace/:workspace/reloadpolicy`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "GetAppUsage",
+			Router: `/:app/group/:group/workspace/:workspace/usage`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
 		beego.ControllerComments{
 			Method: "NewApp",
@@ -97,6 +146,34 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "UpdateAppAutoRollback",
+			Router: `/:app/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:AppController"],
+		beego.ControllerComments{
+			Method: "UpdateAppReloadPolicy",
+			Router: `/:app/group/:group/workspace/:workspace/reloadpolicy`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"],
+		beego.ControllerComments{
+			Method: "DiffConfigMapHistory",
+			Router: `/:configmap/group/:group/workspace/:workspace/history/diff`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"],
+		beego.ControllerComments{
+			Method: "ListConfigMapHistory",
+			Router: `/:configmap/group/:group/workspace/:workspace/history`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"],
 		beego.ControllerComments{
 			Method: "ListGroupWorkspaceConfigMaps",
@@ -132,6 +209,13 @@ func init() {
 			AllowHTTPMethods: []string{"Post"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"],
+		beego.ControllerComments{
+			Method: "RestoreConfigMapHistory",
+			Router: `/:configmap/group/:group/workspace/:workspace/history/:version/restore`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ConfigMapController"],
 		beego.ControllerComments{
 			Method: "UpdateConfigMapCustom",
@@ -244,6 +328,13 @@ func init() {
 			AllowHTTPMethods: []string{"Put"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DaemonSetController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DaemonSetController"],
+		beego.ControllerComments{
+			Method: "GetDaemonSetRevisionDiff",
+			Router: `/:daemonset/group/:group/workspace/:workspace/revisions/diff`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:DaemonSetController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DaemonSetController"],
 		beego.ControllerComments{
 			Method: "ListDaemonSets",
@@ -377,6 +468,41 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "AbortDeploymentCanary",
+			Router: `/:deployment/group/:group/workspace/:workspace/canary/abort`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "CreateDeploymentCanary",
+			Router: `/:deployment/group/:group/workspace/:workspace/canary`,
+			AllowHTTPMethods: []string{"Post"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "GetDeploymentCanary",
+			Router: `/:deployment/group/:group/workspace/:workspace/canary`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "GetDeploymentRevisionDiff",
+			Router: `/:deployment/group/:group/workspace/:workspace/revisions/diff`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "GetHPADecisions",
+			Router: `/:deployment/group/:group/workspace/:workspace/hpa/decisions`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
 		beego.ControllerComments{
 			Method: "ListGroupWorkspaceDeployments",
@@ -405,6 +531,20 @@ func init() {
 			AllowHTTPMethods: []string{"Post"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "NextDeploymentCanary",
+			Router: `/:deployment/group/:group/workspace/:workspace/canary/next`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
+		beego.ControllerComments{
+			Method: "PromoteDeploymentCanary",
+			Router: `/:deployment/group/:group/workspace/:workspace/canary/promote`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:DeploymentController"],
 		beego.ControllerComments{
 			Method: "UpdateDeployment",
@@ -664,6 +804,27 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"],
+		beego.ControllerComments{
+			Method: "ListGroupImages",
+			Router: `/group/:group`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"],
+		beego.ControllerComments{
+			Method: "ListGroupWorkspaceImages",
+			Router: `/group/:group/workspace/:workspace`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ImageController"],
+		beego.ControllerComments{
+			Method: "SetGroupImage",
+			Router: `/group/:group/setimage`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:IngressController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:IngressController"],
 		beego.ControllerComments{
 			Method: "ListIngresss",
@@ -797,6 +958,27 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "DownloadPodFile",
+			Router: `/:pod/group/:group/workspace/:workspace/container/:container/file`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "GetPodStats",
+			Router: `/:pod/group/:group/workspace/:workspace/stat`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "GetPodUsage",
+			Router: `/:pod/group/:group/workspace/:workspace/usage`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
 		beego.ControllerComments{
 			Method: "ListPods",
@@ -853,6 +1035,20 @@ func init() {
 			AllowHTTPMethods: []string{"Delete"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "PodPortForward",
+			Router: `/:pod/group/:group/workspace/:workspace/portforward/:port`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "StreamPodLog",
+			Router: `/:pod/group/:group/workspace/:workspace/container/:container/log/stream`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
 		beego.ControllerComments{
 			Method: "UpdatePod",
@@ -923,6 +1119,13 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:PodController"],
+		beego.ControllerComments{
+			Method: "UploadPodFile",
+			Router: `/:pod/group/:group/workspace/:workspace/container/:container/file`,
+			AllowHTTPMethods: []string{"Post"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:ProgramController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ProgramController"],
 		beego.ControllerComments{
 			Method: "GetVersion",
@@ -937,6 +1140,13 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:QuotaController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:QuotaController"],
+		beego.ControllerComments{
+			Method: "GetWorkspaceQuotaUsage",
+			Router: `/group/:group/workspace/:workspace`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:ReplicaSetController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ReplicaSetController"],
 		beego.ControllerComments{
 			Method: "ListGroupWorkspaceReplicaSets",
@@ -1189,6 +1399,41 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SearchController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SearchController"],
+		beego.ControllerComments{
+			Method: "Search",
+			Router: `/query`,
+			AllowHTTPMethods: []string{"Post"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "CreateSecretFromFiles",
+			Router: `/group/:group/workspace/:workspace/files`,
+			AllowHTTPMethods: []string{"Post"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "DiffSecretHistory",
+			Router: `/:secret/group/:group/workspace/:workspace/history/diff`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "GetPullSecretPolicy",
+			Router: `/group/:group/pullsecretpolicy`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "ListSecretHistory",
+			Router: `/:secret/group/:group/workspace/:workspace/history`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
 		beego.ControllerComments{
 			Method: "ListSecrets",
@@ -1224,6 +1469,20 @@ func init() {
 			AllowHTTPMethods: []string{"Post"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "RestoreSecretHistory",
+			Router: `/:secret/group/:group/workspace/:workspace/history/:version/restore`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "UpdatePullSecretPolicy",
+			Router: `/group/:group/pullsecretpolicy`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
 		beego.ControllerComments{
 			Method: "UpdateSecret",
@@ -1259,6 +1518,20 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "UpdateSecretCustom",
+			Router: `/:secret/group/:group/workspace/:workspace/custom`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:SecretController"],
+		beego.ControllerComments{
+			Method: "UpdateSecretFromFiles",
+			Router: `/:secret/group/:group/workspace/:workspace/files`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 	beego.GlobalControllerRouter["ufleet-deploy/controllers:ServiceAccountController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:ServiceAccountController"],
 		beego.ControllerComments{
 			Method: "ListServiceAccounts",
@@ -1497,4 +1770,221 @@ func init() {
 			AllowHTTPMethods: []string{"Get"},
 			Params: nil})
 
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "AddWorkloadInitContainer",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/initcontainers`,
+			AllowHTTPMethods: []string{"Post"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "DeleteWorkloadAutoRollback",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Delete"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "DeleteWorkloadInitContainer",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/initcontainer/:container`,
+			AllowHTTPMethods: []string{"Delete"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "DeleteWorkloadScaleSchedule",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/scaleschedule`,
+			AllowHTTPMethods: []string{"Delete"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "DownloadWorkloadLogs",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/logs/download`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetUsageRetention",
+			Router: `/usage/retention`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadAffinity",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/affinity`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadAutoRollback",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadAutoRollbackHistory",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/autorollback/history`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadContainerProbe",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/container/:container/probe`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadContainerResources",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/container/:container/resources`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadInitContainers",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/initcontainers`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadLogs",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/logs`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadNodeSelector",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/nodeselector`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadRestart",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/restart`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadRolloutStatus",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/rollout/status`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadScaleSchedule",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/scaleschedule`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadScaleScheduleHistory",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/scaleschedule/history`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadStats",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/stat`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadTolerations",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/tolerations`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "GetWorkloadUsage",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/usage`,
+			AllowHTTPMethods: []string{"Get"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "RestartWorkload",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/restart`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateUsageRetention",
+			Router: `/usage/retention`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadAffinity",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/affinity`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadAutoRollback",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/autorollback`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadContainerProbe",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/container/:container/probe`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadContainerResources",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/container/:container/resources`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadInitContainer",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/initcontainer/:container`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadNodeSelector",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/nodeselector`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadScaleSchedule",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/scaleschedule`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
+	beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"] = append(beego.GlobalControllerRouter["ufleet-deploy/controllers:WorkloadController"],
+		beego.ControllerComments{
+			Method: "UpdateWorkloadTolerations",
+			Router: `/:kind/:name/group/:group/workspace/:workspace/tolerations`,
+			AllowHTTPMethods: []string{"Put"},
+			Params: nil})
+
 }
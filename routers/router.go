@@ -103,6 +103,26 @@ func init() {
 				&controllers.HpaController{},
 			),
 		),
+		beego.NSNamespace("/image",
+			beego.NSInclude(
+				&controllers.ImageController{},
+			),
+		),
+		beego.NSNamespace("/quota",
+			beego.NSInclude(
+				&controllers.QuotaController{},
+			),
+		),
+		beego.NSNamespace("/workload",
+			beego.NSInclude(
+				&controllers.WorkloadController{},
+			),
+		),
+		beego.NSNamespace("/search",
+			beego.NSInclude(
+				&controllers.SearchController{},
+			),
+		),
 	)
 	beego.AddNamespace(ns)
 }
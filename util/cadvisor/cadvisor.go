@@ -2,23 +2,35 @@ package cadvisor
 
 import (
 	"fmt"
+	"sort"
 
 	client "github.com/google/cadvisor/client/v2"
-	//	"github.com/google/cadvisor/info/v1"
+	"github.com/google/cadvisor/info/v1"
 	"github.com/google/cadvisor/info/v2"
 )
 
 const (
-	defaultCollectTime          = 3 * 60 //两分钟
-	defaultHostNetworkInterface = "cni0"
+	defaultStatCount = 60
+
+	IdTypeName   = v2.TypeName
+	IdTypeDocker = v2.TypeDocker
 )
 
 type resourceClient struct {
 	*client.Client
 }
 
+type StatOption struct {
+	IdType    string //IdTypeName或IdTypeDocker
+	Count     int    //采样数,为0时取默认值
+	Recursive bool   //是否包含子容器
+}
+
 type Manager interface {
+	//获取容器的统计,name为cadvisor中的容器名(cgroup路径)
 	GetContainerStats(name string) ([]ContainerStat, error)
+	//返回容器名到统计的映射,Recursive时包含所有子容器
+	GetStats(name string, opt StatOption) (map[string][]ContainerStat, error)
 }
 
 func NewManager(url string) (Manager, error) {
@@ -36,70 +48,133 @@ func NewManager(url string) (Manager, error) {
 }
 
 func (rc *resourceClient) GetContainerStats(name string) ([]ContainerStat, error) {
+	cis, err := rc.GetStats(name, StatOption{IdType: IdTypeName})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range cis {
+		return v, nil
+	}
+	return nil, fmt.Errorf("container '%v' not found", name)
+}
 
+func (rc *resourceClient) GetStats(name string, opt StatOption) (map[string][]ContainerStat, error) {
 	req := v2.RequestOptions{
-		//默认采集距当前时间c分钟内的采样数据.
-		//	Start: time.Now().Add(-defaultCollectTime * time.Second),
-		IdType: name,
-		Count:  10,
+		IdType:    opt.IdType,
+		Count:     opt.Count,
+		Recursive: opt.Recursive,
+	}
+	if req.IdType == "" {
+		req.IdType = IdTypeName
+	}
+	if req.Count <= 0 {
+		req.Count = defaultStatCount
 	}
 
-	//获取主机的统计
-	/*
-		hi, err := rc.DockerContainer("/", &req)
-		if err != nil {
-			return nil, err
-		}
-	*/
-
-	//获取容器的统计
-	//	ci, err := rc.DockerContainer(name, &req)
-	ci, err := rc.Stats(name, &req)
+	cis, err := rc.Stats(name, &req)
 	if err != nil {
 		return nil, err
 	}
+
+	result := make(map[string][]ContainerStat)
+	for k, v := range cis {
+		result[k] = computeStats(k, v)
+	}
+	return result, nil
+}
+
+type sortableStats []*v2.ContainerStats
+
+func (s sortableStats) Len() int           { return len(s) }
+func (s sortableStats) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s sortableStats) Less(i, j int) bool { return s[i].Timestamp.Before(s[j].Timestamp) }
+
+//计数器可能因为容器重启而重置,此时忽略该区间的增量
+func counterDelta(start, end uint64) uint64 {
+	if end < start {
+		return 0
+	}
+	return end - start
+}
+
+func networkBytes(s *v2.ContainerStats) (uint64, uint64) {
+	var rx, tx uint64
+	if s.Network == nil {
+		return 0, 0
+	}
+	for _, v := range s.Network.Interfaces {
+		rx += v.RxBytes
+		tx += v.TxBytes
+	}
+	return rx, tx
+}
+
+func diskBytes(s *v2.ContainerStats) (uint64, uint64) {
+	var read, write uint64
+	if s.DiskIo == nil {
+		return 0, 0
+	}
+	for _, v := range s.DiskIo.IoServiceBytes {
+		read += v.Stats["Read"]
+		write += v.Stats["Write"]
+	}
+	return read, write
+}
+
+func memoryUsed(m *v1.MemoryStats) uint64 {
+	if m == nil {
+		return 0
+	}
+	//working set与kubelet驱逐及metrics API的口径一致
+	if m.WorkingSet > 0 {
+		return m.WorkingSet
+	}
+	return m.Usage
+}
+
+//相邻两次采样计算一个统计,CPU/网络/磁盘为区间内的增量
+func computeStats(name string, ci v2.ContainerInfo) []ContainerStat {
+	stats := make([]*v2.ContainerStats, 0, len(ci.Stats))
+	for _, v := range ci.Stats {
+		if v != nil {
+			stats = append(stats, v)
+		}
+	}
+	sort.Sort(sortableStats(stats))
+
 	css := make([]ContainerStat, 0)
-	for _, v := range ci {
-		fmt.Println(v)
-		fmt.Println("----------")
-
-		//		var cs ContainerStat
-
-	}
-	return css, nil
-
-	/*
-		for k := 0; k < len(ci.Stats)-1; k++ {
-			var cs ContainerStat
-			startStats := ci.Stats[k]
-			endStats := ci.Stats[k+1]
-			cs.Name = ci.Name
-			cs.Start = startStats.Timestamp
-			cs.End = endStats.Timestamp
-
-			if ci.Spec.HasCpu {
-				cs.Cpu.Usage = float64(endStats.Cpu.Usage.Total-startStats.Cpu.Usage.Total) / float64(cs.End.UnixNano()-cs.Start.UnixNano())
-			} else {
-				cs.Cpu.Usage = 0.0
-
-			}
-
-			if ci.Spec.HasNetwork {
-				cs.Network.RxBytes = endStats.Network.InterfaceStats.RxBytes - startStats.Network.InterfaceStats.RxBytes
-				cs.Network.TxBytes = endStats.Network.InterfaceStats.TxBytes - startStats.Network.InterfaceStats.TxBytes
-			} else {
-				cs.Network.RxBytes = 0
-				cs.Network.TxBytes = 0
-			}
-
-			if ci.Spec.HasMemory {
-				cs.Memory.Used = endStats.Memory.Usage - startStats.Memory.Usage
-			} else {
-				cs.Memory.Used = 0
-			}
-			css = append(css, cs)
+	for k := 0; k+1 < len(stats); k++ {
+		start := stats[k]
+		end := stats[k+1]
+		interval := end.Timestamp.Sub(start.Timestamp)
+		if interval <= 0 {
+			continue
 		}
 
-	*/
-	return css, nil
+		var cs ContainerStat
+		cs.Name = name
+		cs.Start = start.Timestamp
+		cs.End = end.Timestamp
+
+		if ci.Spec.HasCpu && start.Cpu != nil && end.Cpu != nil {
+			cs.Cpu.Usage = float64(counterDelta(start.Cpu.Usage.Total, end.Cpu.Usage.Total)) / float64(interval.Nanoseconds())
+		}
+		if ci.Spec.HasNetwork {
+			srx, stx := networkBytes(start)
+			erx, etx := networkBytes(end)
+			cs.Network.RxBytes = counterDelta(srx, erx)
+			cs.Network.TxBytes = counterDelta(stx, etx)
+		}
+		if ci.Spec.HasMemory {
+			cs.Memory.Used = memoryUsed(end.Memory)
+		}
+		if ci.Spec.HasDiskIo {
+			sr, sw := diskBytes(start)
+			er, ew := diskBytes(end)
+			cs.Disk.ReadBytes = counterDelta(sr, er)
+			cs.Disk.WriteBytes = counterDelta(sw, ew)
+		}
+		css = append(css, cs)
+	}
+	return css
 }
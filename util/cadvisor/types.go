@@ -3,16 +3,21 @@ package cadvisor
 import "time"
 
 type CpuStat struct {
-	Usage float64 `json:"usage"`
+	Usage float64 `json:"usage"` //Start到End之间平均使用的核数
 }
 
 type NetworkStat struct {
-	RxBytes uint64 `json:"rxbytes`
-	TxBytes uint64 `json:"txbytes"`
+	RxBytes uint64 `json:"rxbytes"` //Start到End之间接收的字节数
+	TxBytes uint64 `json:"txbytes"` //Start到End之间发送的字节数
 }
 
 type MemoryStat struct {
-	Used uint64 `json:"used"`
+	Used uint64 `json:"used"` //End时的working set
+}
+
+type DiskStat struct {
+	ReadBytes  uint64 `json:"readbytes"`  //Start到End之间读取的字节数
+	WriteBytes uint64 `json:"writebytes"` //Start到End之间写入的字节数
 }
 
 type ContainerStat struct {
@@ -22,4 +27,5 @@ type ContainerStat struct {
 	Cpu     CpuStat     `json:"cpu"`
 	Network NetworkStat `json:"network"`
 	Memory  MemoryStat  `json:"memory"`
+	Disk    DiskStat    `json:"disk"`
 }
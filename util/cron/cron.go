@@ -0,0 +1,182 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//标准的5段cron表达式: 分 时 日 月 周
+//每段支持*,数字,a-b范围,/n步长以及逗号分隔的列表,月和周支持英文缩写(JAN,MON等)
+//周的0和7都表示周日;日和周都不是*时,满足其一即可
+type Schedule struct {
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+
+	domStar bool
+	dowStar bool
+}
+
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{min: 0, max: 59}
+	hourField   = field{min: 0, max: 23}
+	domField    = field{min: 1, max: 31}
+	monthField  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	//查找下一次执行时间的上限,避免2月30日这类永远不会满足的表达式死循环
+	maxSearch = 5 * 366 * 24 * time.Hour
+)
+
+func Parse(spec string) (*Schedule, error) {
+	fs := strings.Fields(spec)
+	if len(fs) != 5 {
+		return nil, fmt.Errorf("cron expression '%v' must have 5 fields", spec)
+	}
+
+	var s Schedule
+	var err error
+	if s.minute, err = minuteField.parse(fs[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(fs[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(fs[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(fs[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(fs[4]); err != nil {
+		return nil, err
+	}
+	//7也表示周日
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	//与标准cron一致,以*开头(如*/2)的字段也是通配,日期和星期同时满足
+	s.domStar = isStar(fs[2])
+	s.dowStar = isStar(fs[4])
+	return &s, nil
+}
+
+func isStar(f string) bool {
+	return strings.HasPrefix(f, "*") || strings.HasPrefix(f, "?")
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value '%v'", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value '%v' out of range [%v,%v]", s, f.min, f.max)
+	}
+	return v, nil
+}
+
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step in '%v'", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case part == "*" || part == "?":
+		case strings.Contains(part, "-"):
+			r := strings.SplitN(part, "-", 2)
+			var err error
+			if start, err = f.value(r[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(r[1]); err != nil {
+				return 0, err
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range '%v'", part)
+			}
+		default:
+			v, err := f.value(part)
+			if err != nil {
+				return 0, err
+			}
+			start = v
+			//a/n表示从a开始到最大值
+			if step == 1 {
+				end = v
+			}
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+	return bits, nil
+}
+
+func has(bits uint64, v int) bool {
+	return bits&(1<<uint(v)) != 0
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := has(s.dom, t.Day())
+	dow := has(s.dow, int(t.Weekday()))
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+//t之后(不含t)的下一次执行时间,使用t的时区;没有时返回零值
+func (s *Schedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+
+	for t.Before(limit) {
+		if !has(s.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !has(s.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -155,3 +155,45 @@ func (e *Etcd3Client) GenerateWatcherChan(key string) (clientv3.WatchChan, error
 	return watcher, nil
 
 }
+
+// CreateWithLease key不存在时以ttl秒的租约创建,返回租约ID与是否创建成功
+// key已存在时返回当前的值
+func (e *Etcd3Client) CreateWithLease(key, value string, ttl int64) (clientv3.LeaseID, bool, string, error) {
+	lease, err := e.RawClient.Grant(context.Background(), ttl)
+	if err != nil {
+		return 0, false, "", err
+	}
+
+	resp, err := e.RawClient.Txn(context.Background()).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, value, clientv3.WithLease(lease.ID))).
+		Else(clientv3.OpGet(key)).
+		Commit()
+	if err != nil {
+		e.RawClient.Revoke(context.Background(), lease.ID)
+		return 0, false, "", err
+	}
+	if resp.Succeeded {
+		return lease.ID, true, value, nil
+	}
+
+	e.RawClient.Revoke(context.Background(), lease.ID)
+	current := ""
+	if len(resp.Responses) > 0 {
+		if r := resp.Responses[0].GetResponseRange(); r != nil && len(r.Kvs) > 0 {
+			current = string(r.Kvs[0].Value)
+		}
+	}
+	return 0, false, current, nil
+}
+
+// KeepAlive 保持租约,ctx取消或租约失效时返回的channel关闭
+func (e *Etcd3Client) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	return e.RawClient.KeepAlive(ctx, id)
+}
+
+// Revoke 撤销租约,关联的key随之删除
+func (e *Etcd3Client) Revoke(id clientv3.LeaseID) error {
+	_, err := e.RawClient.Revoke(context.Background(), id)
+	return err
+}